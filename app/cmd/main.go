@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"os"
 
 	"github.com/slava-911/URL-shortener/internal/app"
 	"github.com/slava-911/URL-shortener/internal/config"
+	"github.com/slava-911/URL-shortener/internal/controller/cli"
 	"github.com/slava-911/URL-shortener/pkg/logging"
 )
 
@@ -15,11 +20,10 @@ func main() {
 	log.Print("logger initializing")
 	logger := logging.GetLogger(cfg.AppConfig.LogLevel)
 
-	a, err := app.NewApp(cfg, logger)
-	if err != nil {
+	if err := app.Execute(context.Background(), cfg, logger, flag.Args()); err != nil {
+		if errors.Is(err, cli.ErrUsage) {
+			os.Exit(2)
+		}
 		logger.Fatal(err)
 	}
-
-	logger.Println("Running Application")
-	a.Run()
 }
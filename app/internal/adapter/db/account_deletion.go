@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/postgresql"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+type accountDeletionStorage struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func NewAccountDeletionStorage(client postgresql.Client, logger *logging.Logger) interf.AccountDeletionStorage {
+	return &accountDeletionStorage{
+		client: client,
+		logger: logger,
+	}
+}
+
+// Create requests deletion of the account, repeated request keeps the first one
+func (s *accountDeletionStorage) Create(ctx context.Context, userID string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		INSERT INTO account_deletions
+		    (user_id)
+		VALUES
+		    ($1)
+		ON CONFLICT (user_id) DO NOTHING
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	if _, err := s.client.Exec(ctx, q, userID); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+// FindOnePending locks the oldest pending request, requests locked by other instances are skipped
+func (s *accountDeletionStorage) FindOnePending(ctx context.Context) (d entity.AccountDeletion, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    d.user_id, d.requested_at, d.completed_at, d.links, d.clicks
+		FROM
+		    account_deletions d
+		WHERE
+		    d.completed_at IS NULL
+		ORDER BY
+		    d.requested_at
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	row := s.client.QueryRow(ctx, q)
+	if err = row.Scan(&d.UserID, &d.RequestedAt, &d.CompletedAt, &d.Links, &d.Clicks); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return d, apperror.ErrNotFound
+		}
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return d, detErr
+		}
+		return d, err
+	}
+	return d, nil
+}
+
+// Complete anonymizes clicks on links of the user, retires their short versions and saves their totals.
+// The user itself is deleted separately in the same transaction.
+func (s *accountDeletionStorage) Complete(ctx context.Context, userID string) (d entity.AccountDeletion, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	q := `
+		WITH owned AS (
+		    SELECT
+		        l.id, l.short_version, COALESCE(l.clicked, 0) AS clicked
+		    FROM
+		        links l
+		    WHERE
+		        l.user_id = $1
+		), anonymized AS (
+		    UPDATE
+		        click_events c
+		    SET
+		        link_id = NULL, referrer = '', user_agent = ''
+		    WHERE
+		        c.link_id IN (SELECT id FROM owned)
+		), retired AS (
+		    INSERT INTO retired_short_versions
+		        (short_version)
+		    SELECT
+		        short_version
+		    FROM
+		        owned
+		    ON CONFLICT (short_version) DO NOTHING
+		)
+		UPDATE
+		    account_deletions d
+		SET
+		    completed_at = (now() AT TIME ZONE 'utc'),
+		    links = (SELECT count(*) FROM owned),
+		    clicks = (SELECT COALESCE(sum(clicked), 0) FROM owned)
+		WHERE
+		    d.user_id = $1
+		RETURNING d.user_id, d.requested_at, d.completed_at, d.links, d.clicks
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	row := s.client.QueryRow(ctx, q, userID)
+	if err = row.Scan(&d.UserID, &d.RequestedAt, &d.CompletedAt, &d.Links, &d.Clicks); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return d, apperror.ErrNotFound
+		}
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return d, detErr
+		}
+		return d, err
+	}
+	return d, nil
+}
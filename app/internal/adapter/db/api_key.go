@@ -0,0 +1,178 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/postgresql"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+type apiKeyStorage struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func NewAPIKeyStorage(client postgresql.Client, logger *logging.Logger) interf.APIKeyStorage {
+	return &apiKeyStorage{
+		client: client,
+		logger: logger,
+	}
+}
+
+func (s *apiKeyStorage) Create(ctx context.Context, k entity.APIKey) (entity.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		INSERT INTO api_keys
+			(user_id, name, prefix, key_hash, scopes, expires_at)
+		VALUES
+			($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	row := s.client.QueryRow(ctx, q, k.UserID, k.Name, k.Prefix, k.KeyHash, k.Scopes, k.ExpiresAt)
+	if err := row.Scan(&k.ID, &k.CreatedAt); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return k, detErr
+		}
+		return k, err
+	}
+
+	return k, nil
+}
+
+func (s *apiKeyStorage) FindAllByUserID(ctx context.Context, userID string) (keys []entity.APIKey, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    k.id, k.user_id, k.name, k.prefix, k.key_hash, k.scopes, k.created_at, k.expires_at, k.last_used_at, k.revoked_at
+		FROM
+		    api_keys k
+		WHERE
+		    k.user_id = $1
+		ORDER BY
+		    k.created_at DESC
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	rows, err := s.client.Query(ctx, q, userID)
+	if err != nil {
+		return keys, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var k entity.APIKey
+		err = rows.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.KeyHash, &k.Scopes,
+			&k.CreatedAt, &k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt)
+		if err != nil {
+			if detErr := postgresql.DetailedPgError(err); detErr != nil {
+				return keys, detErr
+			}
+			return keys, err
+		}
+		keys = append(keys, k)
+	}
+
+	if err = rows.Err(); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return keys, detErr
+		}
+		return keys, err
+	}
+
+	return keys, nil
+}
+
+func (s *apiKeyStorage) FindOneByHash(ctx context.Context, keyHash string) (k entity.APIKey, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    k.id, k.user_id, k.name, k.prefix, k.key_hash, k.scopes, k.created_at, k.expires_at, k.last_used_at, k.revoked_at
+		FROM
+		    api_keys k
+		    JOIN users u ON u.id = k.user_id
+		WHERE
+		    k.key_hash = $1 AND u.disabled_at IS NULL
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	row := s.client.QueryRow(ctx, q, keyHash)
+	err = row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.KeyHash, &k.Scopes,
+		&k.CreatedAt, &k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return k, apperror.ErrNotFound
+		}
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return k, detErr
+		}
+		return k, err
+	}
+	return k, nil
+}
+
+func (s *apiKeyStorage) Revoke(ctx context.Context, id, userID string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		UPDATE
+		    api_keys k
+		SET
+		    revoked_at = (now() AT TIME ZONE 'utc')
+		WHERE
+		    k.id = $1 AND k.user_id = $2 AND k.revoked_at IS NULL
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	tag, err := s.client.Exec(ctx, q, id, userID)
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *apiKeyStorage) UpdateLastUsed(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		UPDATE
+		    api_keys k
+		SET
+		    last_used_at = (now() AT TIME ZONE 'utc')
+		WHERE
+		    k.id = $1
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	if _, err := s.client.Exec(ctx, q, id); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/postgresql"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+type auditStorage struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func NewAuditStorage(client postgresql.Client, logger *logging.Logger) interf.AuditStorage {
+	return &auditStorage{
+		client: client,
+		logger: logger,
+	}
+}
+
+func (s *auditStorage) Create(ctx context.Context, e entity.AuditEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	changes, err := json.Marshal(e.Changes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit changes, error: %w", err)
+	}
+
+	q := `
+		INSERT INTO audit_events
+			(actor_id, action, target_type, target_id, changes, ip, user_agent)
+		VALUES
+			(NULLIF($1, '')::uuid, $2, $3, $4, $5, $6, $7)
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	_, err = s.client.Exec(ctx, q, e.ActorID, e.Action, e.TargetType, e.TargetID, changes, e.IP, e.UserAgent)
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *auditStorage) FindAll(ctx context.Context, f entity.AuditFilter) (events []entity.AuditEvent, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    e.id, COALESCE(e.actor_id::text, ''), e.action, e.target_type, e.target_id, e.changes, e.ip, e.user_agent,
+		    e.created_at
+		FROM
+		    audit_events e
+		WHERE
+		    ($1 = '' OR e.actor_id::text = $1 OR (e.target_type = 'user' AND e.target_id = $1))
+		    AND ($2 = '' OR (e.target_type = 'link' AND e.target_id = $2))
+		    AND ($3::timestamp IS NULL OR e.created_at >= $3)
+		    AND ($4::timestamp IS NULL OR e.created_at < $4)
+		ORDER BY
+		    e.created_at DESC
+		LIMIT $5 OFFSET $6
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	rows, err := s.client.Query(ctx, q, f.UserID, f.LinkID, f.From, f.To, f.Limit, f.Offset)
+	if err != nil {
+		return events, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e entity.AuditEvent
+		var changes []byte
+		err = rows.Scan(&e.ID, &e.ActorID, &e.Action, &e.TargetType, &e.TargetID, &changes, &e.IP, &e.UserAgent,
+			&e.CreatedAt)
+		if err != nil {
+			if detErr := postgresql.DetailedPgError(err); detErr != nil {
+				return events, detErr
+			}
+			return events, err
+		}
+		if err = json.Unmarshal(changes, &e.Changes); err != nil {
+			return events, fmt.Errorf("failed to unmarshal audit changes, error: %w", err)
+		}
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return events, detErr
+		}
+		return events, err
+	}
+
+	return events, nil
+}
+
+// AnonymizeUser clears ip, user agent, name and email of events made by or about the user.
+// The append-only trigger lets only these fields change while audit.anonymize is set for the transaction.
+func (s *auditStorage) AnonymizeUser(ctx context.Context, userID string) (n int64, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	q := `
+		UPDATE
+		    audit_events e
+		SET
+		    ip = '', user_agent = '',
+		    changes = CASE WHEN e.target_type = 'user' AND e.target_id = $1 THEN e.changes - 'name' - 'email'
+		        ELSE e.changes END
+		WHERE
+		    e.actor_id::text = $1 OR (e.target_type = 'user' AND e.target_id = $1)
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	err = s.client.BeginFunc(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT set_config('audit.anonymize', 'on', true)`); err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx, q, userID)
+		if err != nil {
+			return err
+		}
+		n = tag.RowsAffected()
+		_, err = tx.Exec(ctx, `SELECT set_config('audit.anonymize', 'off', true)`)
+		return err
+	})
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return 0, detErr
+		}
+		return 0, err
+	}
+
+	return n, nil
+}
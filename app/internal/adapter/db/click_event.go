@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/postgresql"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+type clickEventStorage struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func NewClickEventStorage(client postgresql.Client, logger *logging.Logger) interf.ClickEventStorage {
+	return &clickEventStorage{
+		client: client,
+		logger: logger,
+	}
+}
+
+// FindAllByUserID returns clicks on links of the user including links in trash with id greater than afterID,
+// oldest first, so that pages are read by the primary key instead of skipping rows with offset
+func (s *clickEventStorage) FindAllByUserID(ctx context.Context, userID string, afterID int64,
+	limit int) (events []entity.ClickEvent, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    c.id, c.link_id, c.referrer, c.user_agent, c.clicked_at
+		FROM
+		    click_events c
+		    JOIN links l ON l.id = c.link_id
+		WHERE
+		    l.user_id = $1
+		    AND c.id > $2
+		ORDER BY
+		    c.id
+		LIMIT $3
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	rows, err := s.client.Query(ctx, q, userID, afterID, limit)
+	if err != nil {
+		return events, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e entity.ClickEvent
+		if err = rows.Scan(&e.ID, &e.LinkID, &e.Referrer, &e.UserAgent, &e.ClickedAt); err != nil {
+			if detErr := postgresql.DetailedPgError(err); detErr != nil {
+				return events, detErr
+			}
+			return events, err
+		}
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return events, detErr
+		}
+		return events, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/postgresql"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+type identityStorage struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func NewIdentityStorage(client postgresql.Client, logger *logging.Logger) interf.IdentityStorage {
+	return &identityStorage{
+		client: client,
+		logger: logger,
+	}
+}
+
+func (s *identityStorage) Create(ctx context.Context, i entity.Identity) (entity.Identity, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		INSERT INTO user_identities
+			(user_id, provider, subject, email)
+		VALUES
+			($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	row := s.client.QueryRow(ctx, q, i.UserID, i.Provider, i.Subject, i.Email)
+	if err := row.Scan(&i.ID, &i.CreatedAt); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return i, detErr
+		}
+		return i, err
+	}
+
+	return i, nil
+}
+
+func (s *identityStorage) FindOne(ctx context.Context, provider, subject string) (i entity.Identity, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    i.id, i.user_id, i.provider, i.subject, i.email, i.created_at
+		FROM
+		    user_identities i
+		WHERE
+		    i.provider = $1 AND i.subject = $2
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	row := s.client.QueryRow(ctx, q, provider, subject)
+	if err = row.Scan(&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Email, &i.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return i, apperror.ErrNotFound
+		}
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return i, detErr
+		}
+		return i, err
+	}
+	return i, nil
+}
@@ -13,9 +13,14 @@ import (
 	"github.com/slava-911/URL-shortener/internal/interf"
 	"github.com/slava-911/URL-shortener/pkg/logging"
 	"github.com/slava-911/URL-shortener/pkg/postgresql"
+	"github.com/slava-911/URL-shortener/pkg/tracing"
 	"github.com/slava-911/URL-shortener/pkg/utils"
+	"go.opentelemetry.io/otel"
 )
 
+// tracer starts spans of link and user storage methods
+var tracer = otel.Tracer("github.com/slava-911/URL-shortener/internal/adapter/db")
+
 type linkStorage struct {
 	client postgresql.Client
 	logger *logging.Logger
@@ -28,21 +33,71 @@ func NewLinkStorage(client postgresql.Client, logger *logging.Logger) interf.Lin
 	}
 }
 
+// Create returns apperror.ErrShortTaken if the short version is used by another link or retired
 func (s *linkStorage) Create(ctx context.Context, l entity.Link) (linkID string, err error) {
+	ctx, span := tracer.Start(ctx, "linkStorage.Create")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	q := `
 		INSERT INTO links
 			(full_version, short_version, description, clicked, user_id)
-		VALUES
-			($1, $2, $3, $4, $5)
+		SELECT
+			$1, $2, $3, $4, $5
+		WHERE
+		    NOT EXISTS (SELECT 1 FROM retired_short_versions r WHERE r.short_version = $2)
+		ON CONFLICT (short_version) DO NOTHING
 		RETURNING id
 	`
 	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
 
 	row := s.client.QueryRow(ctx, q, l.FullVersion, l.ShortVersion, l.Description, 0, l.UserID)
 	if err = row.Scan(&linkID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return linkID, apperror.ErrShortTaken
+		}
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return linkID, detErr
+		}
+		return linkID, err
+	}
+
+	return linkID, nil
+}
+
+// CreateImported keeps short version, creation time and clicks of the link,
+// apperror.ErrShortTaken is returned if the short version is used by another link or retired
+func (s *linkStorage) CreateImported(ctx context.Context, l entity.Link) (linkID string, err error) {
+	ctx, span := tracer.Start(ctx, "linkStorage.CreateImported")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var createdAt *time.Time
+	if !l.CreatedAt.IsZero() {
+		createdAt = &l.CreatedAt
+	}
+
+	q := `
+		INSERT INTO links
+			(full_version, short_version, description, clicked, user_id, created_at)
+		SELECT
+			$1, $2, $3, $4, $5, COALESCE($6, now() AT TIME ZONE 'utc')
+		WHERE
+		    NOT EXISTS (SELECT 1 FROM retired_short_versions r WHERE r.short_version = $2)
+		ON CONFLICT (short_version) DO NOTHING
+		RETURNING id
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	row := s.client.QueryRow(ctx, q, l.FullVersion, l.ShortVersion, l.Description, l.Clicked, l.UserID, createdAt)
+	if err = row.Scan(&linkID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return linkID, apperror.ErrShortTaken
+		}
 		if detErr := postgresql.DetailedPgError(err); detErr != nil {
 			return linkID, detErr
 		}
@@ -53,16 +108,19 @@ func (s *linkStorage) Create(ctx context.Context, l entity.Link) (linkID string,
 }
 
 func (s *linkStorage) FindAllByUserID(ctx context.Context, userID string) (links []entity.Link, err error) {
+	ctx, span := tracer.Start(ctx, "linkStorage.FindAllByUserID")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	q := `
 		SELECT
-		    l.id, l.full_version, l.short_version, COALESCE(l.description, ''), l.created_at, COALESCE(l.clicked, 0), l.user_id
+		    l.id, l.full_version, l.short_version, COALESCE(l.description, ''), l.created_at, COALESCE(l.clicked, 0), l.user_id, l.status
 		FROM
 		    links l
 		WHERE
-		    l.user_id = $1
+		    l.user_id = $1 AND l.deleted_at IS NULL
 	`
 	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
 
@@ -70,10 +128,11 @@ func (s *linkStorage) FindAllByUserID(ctx context.Context, userID string) (links
 	if err != nil {
 		return links, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var l entity.Link
-		err = rows.Scan(&l.ID, &l.FullVersion, &l.ShortVersion, &l.Description, &l.CreatedAt, &l.Clicked, &l.UserID)
+		err = rows.Scan(&l.ID, &l.FullVersion, &l.ShortVersion, &l.Description, &l.CreatedAt, &l.Clicked, &l.UserID, &l.Status)
 		if err != nil {
 			if detErr := postgresql.DetailedPgError(err); detErr != nil {
 				return links, detErr
@@ -94,21 +153,24 @@ func (s *linkStorage) FindAllByUserID(ctx context.Context, userID string) (links
 }
 
 func (s *linkStorage) FindOneByID(ctx context.Context, id string) (l entity.Link, err error) {
+	ctx, span := tracer.Start(ctx, "linkStorage.FindOneByID")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	q := `
 		SELECT
-		    l.id, l.full_version, l.short_version, COALESCE(l.description, ''), l.created_at, COALESCE(l.clicked, 0), l.user_id
+		    l.id, l.full_version, l.short_version, COALESCE(l.description, ''), l.created_at, COALESCE(l.clicked, 0), l.user_id, l.status
 		FROM
 		    links l
 		WHERE
-		    l.id = $1
+		    l.id = $1 AND l.deleted_at IS NULL
 	`
 	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
 
 	row := s.client.QueryRow(ctx, q, id)
-	err = row.Scan(&l.ID, &l.FullVersion, &l.ShortVersion, &l.Description, &l.CreatedAt, &l.Clicked, &l.UserID)
+	err = row.Scan(&l.ID, &l.FullVersion, &l.ShortVersion, &l.Description, &l.CreatedAt, &l.Clicked, &l.UserID, &l.Status)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return l, apperror.ErrNotFound
@@ -121,7 +183,10 @@ func (s *linkStorage) FindOneByID(ctx context.Context, id string) (l entity.Link
 	return l, nil
 }
 
-func (s *linkStorage) Update(ctx context.Context, id string, chFields map[string]string) error {
+func (s *linkStorage) Update(ctx context.Context, id string, chFields map[string]string) (err error) {
+	ctx, span := tracer.Start(ctx, "linkStorage.Update")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
@@ -143,7 +208,7 @@ func (s *linkStorage) Update(ctx context.Context, id string, chFields map[string
 		SET
 		    %s
 		WHERE
-		    l.id = $%d
+		    l.id = $%d AND l.deleted_at IS NULL
 	`
 	q = fmt.Sprintf(q, fieldsToSet, paramNum)
 	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
@@ -151,63 +216,359 @@ func (s *linkStorage) Update(ctx context.Context, id string, chFields map[string
 	params = append(params, id)
 	s.logger.Tracef("params: %s", params)
 
-	if _, err := s.client.Exec(ctx, q, params...); err != nil {
+	tag, err := s.client.Exec(ctx, q, params...)
+	if err != nil {
 		if detErr := postgresql.DetailedPgError(err); detErr != nil {
 			return detErr
 		}
 		return err
 	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
 
 	return nil
 }
 
-func (s *linkStorage) Delete(ctx context.Context, id string) error {
+// Delete moves the link to trash, it keeps its short version until purged
+func (s *linkStorage) Delete(ctx context.Context, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "linkStorage.Delete")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	q := `
-		DELETE FROM
+		UPDATE
 		    links l
+		SET
+		    deleted_at = (now() AT TIME ZONE 'utc')
 		WHERE
-		    l.id = $1
+		    l.id = $1 AND l.deleted_at IS NULL
 	`
 	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
 
-	if _, err := s.client.Exec(ctx, q, id); err != nil {
+	tag, err := s.client.Exec(ctx, q, id)
+	if err != nil {
 		if detErr := postgresql.DetailedPgError(err); detErr != nil {
 			return detErr
 		}
 		return err
 	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
 
 	return nil
 }
 
-func (s *linkStorage) FindFullVersionByShortVersion(ctx context.Context, sv string) (fv string, err error) {
+func (s *linkStorage) FindAllDeletedByUserID(ctx context.Context, userID string) (links []entity.Link, err error) {
+	ctx, span := tracer.Start(ctx, "linkStorage.FindAllDeletedByUserID")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    l.id, l.full_version, l.short_version, COALESCE(l.description, ''), l.created_at, COALESCE(l.clicked, 0), l.user_id, l.status,
+		    l.deleted_at
+		FROM
+		    links l
+		WHERE
+		    l.user_id = $1 AND l.deleted_at IS NOT NULL
+		ORDER BY
+		    l.deleted_at DESC
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	rows, err := s.client.Query(ctx, q, userID)
+	if err != nil {
+		return links, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var l entity.Link
+		err = rows.Scan(&l.ID, &l.FullVersion, &l.ShortVersion, &l.Description, &l.CreatedAt, &l.Clicked, &l.UserID, &l.Status,
+			&l.DeletedAt)
+		if err != nil {
+			if detErr := postgresql.DetailedPgError(err); detErr != nil {
+				return links, detErr
+			}
+			return links, err
+		}
+		links = append(links, l)
+	}
+
+	if err = rows.Err(); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return links, detErr
+		}
+		return links, err
+	}
+
+	return links, nil
+}
+
+// Restore takes the link of the user out of trash
+func (s *linkStorage) Restore(ctx context.Context, id, userID string) (err error) {
+	ctx, span := tracer.Start(ctx, "linkStorage.Restore")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	q := `
 		UPDATE
-		    links
-   		SET
-   		    clicked = clicked + 1
-   		WHERE
-		    short_version = $1
-		RETURNING full_version
+		    links l
+		SET
+		    deleted_at = NULL
+		WHERE
+		    l.id = $1 AND l.user_id = $2 AND l.deleted_at IS NOT NULL
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	tag, err := s.client.Exec(ctx, q, id, userID)
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+
+	return nil
+}
+
+// PurgeDeleted permanently deletes links moved to trash before deletedBefore and retires their short versions,
+// returns the number of deleted links
+func (s *linkStorage) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (_ int64, err error) {
+	ctx, span := tracer.Start(ctx, "linkStorage.PurgeDeleted")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	q := `
+		WITH purged AS (
+		    DELETE FROM
+		        links l
+		    WHERE
+		        l.deleted_at < $1
+		    RETURNING l.short_version
+		), retired AS (
+		    INSERT INTO retired_short_versions
+			    (short_version)
+		    SELECT
+		        short_version
+		    FROM
+		        purged
+		    ON CONFLICT DO NOTHING
+		)
+		SELECT
+		    count(*)
+		FROM
+		    purged
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	var n int64
+	if err = s.client.QueryRow(ctx, q, deletedBefore).Scan(&n); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return 0, detErr
+		}
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// FindFullVersionByShortVersion counts a click, saves its event and returns the link with its status,
+// clicks on inactive links are not counted.
+// Scheduled destination which is already due wins over the stored one, so that a late scheduler never serves stale one,
+// unless the link was changed after the schedule was due, as DeleteStale cancels such schedules.
+func (s *linkStorage) FindFullVersionByShortVersion(ctx context.Context, sv string,
+	click entity.ClickEvent) (l entity.Link, err error) {
+	ctx, span := tracer.Start(ctx, "linkStorage.FindFullVersionByShortVersion")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		WITH clicked AS (
+		    UPDATE
+		        links l
+		    SET
+		        clicked = clicked + CASE WHEN status = 'active' THEN 1 ELSE 0 END
+		    WHERE
+		        l.short_version = $1 AND l.deleted_at IS NULL
+		    RETURNING
+		        l.id,
+		        l.user_id,
+		        l.short_version,
+		        COALESCE(l.description, '') AS description,
+		        l.created_at,
+		        COALESCE(l.clicked, 0) AS clicked,
+		        COALESCE((
+		            SELECT
+		                s.full_version
+		            FROM
+		                link_schedules s
+		            WHERE
+		                s.link_id = l.id AND s.applied_at IS NULL AND s.apply_at <= (now() AT TIME ZONE 'utc')
+		                AND s.apply_at >= COALESCE((
+		                    SELECT MAX(v.created_at) FROM link_versions v WHERE v.link_id = l.id
+		                ), s.apply_at)
+		            ORDER BY
+		                s.apply_at DESC
+		            LIMIT 1
+		        ), l.full_version) AS full_version,
+		        l.status
+		), event AS (
+		    INSERT INTO click_events
+		        (link_id, referrer, user_agent)
+		    SELECT
+		        c.id, $2, $3
+		    FROM
+		        clicked c
+		    WHERE
+		        c.status = 'active'
+		)
+		SELECT
+		    c.id, c.user_id, c.short_version, c.full_version, c.description, c.created_at, c.clicked, c.status
+		FROM
+		    clicked c
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	row := s.client.QueryRow(ctx, q, sv, click.Referrer, click.UserAgent)
+	err = row.Scan(&l.ID, &l.UserID, &l.ShortVersion, &l.FullVersion, &l.Description, &l.CreatedAt, &l.Clicked,
+		&l.Status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return l, apperror.ErrNotFound
+		}
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return l, detErr
+		}
+		return l, err
+	}
+
+	return l, nil
+}
+
+func (s *linkStorage) FindOneByShortVersion(ctx context.Context, sv string) (l entity.Link, err error) {
+	ctx, span := tracer.Start(ctx, "linkStorage.FindOneByShortVersion")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    l.id, l.full_version, l.short_version, COALESCE(l.description, ''), l.created_at, COALESCE(l.clicked, 0), l.user_id, l.status
+		FROM
+		    links l
+		WHERE
+		    l.short_version = $1 AND l.deleted_at IS NULL
 	`
 	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
 
 	row := s.client.QueryRow(ctx, q, sv)
-	if err = row.Scan(&fv); err != nil {
+	err = row.Scan(&l.ID, &l.FullVersion, &l.ShortVersion, &l.Description, &l.CreatedAt, &l.Clicked, &l.UserID, &l.Status)
+	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return fv, apperror.ErrNotFound
+			return l, apperror.ErrNotFound
+		}
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return l, detErr
+		}
+		return l, err
+	}
+	return l, nil
+}
+
+func (s *linkStorage) SetStatus(ctx context.Context, id, status string) (err error) {
+	ctx, span := tracer.Start(ctx, "linkStorage.SetStatus")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		UPDATE
+		    links l
+		SET
+		    status = $2
+		WHERE
+		    l.id = $1 AND l.deleted_at IS NULL
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	tag, err := s.client.Exec(ctx, q, id, status)
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *linkStorage) FindAll(ctx context.Context, f entity.LinkFilter) (links []entity.Link, err error) {
+	ctx, span := tracer.Start(ctx, "linkStorage.FindAll")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    l.id, l.full_version, l.short_version, COALESCE(l.description, ''), l.created_at, COALESCE(l.clicked, 0), l.user_id, l.status
+		FROM
+		    links l
+		WHERE
+		    ($1 = '' OR l.full_version ILIKE '%' || $1 || '%' OR l.short_version = $1 OR l.description ILIKE '%' || $1 || '%')
+		    AND ($2 = '' OR l.user_id::text = $2)
+		    AND l.deleted_at IS NULL
+		ORDER BY
+		    l.created_at DESC, l.id
+		LIMIT $3 OFFSET $4
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	rows, err := s.client.Query(ctx, q, f.Query, f.UserID, f.Limit, f.Offset)
+	if err != nil {
+		return links, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var l entity.Link
+		err = rows.Scan(&l.ID, &l.FullVersion, &l.ShortVersion, &l.Description, &l.CreatedAt, &l.Clicked, &l.UserID, &l.Status)
+		if err != nil {
+			if detErr := postgresql.DetailedPgError(err); detErr != nil {
+				return links, detErr
+			}
+			return links, err
 		}
+		links = append(links, l)
+	}
+
+	if err = rows.Err(); err != nil {
 		if detErr := postgresql.DetailedPgError(err); detErr != nil {
-			return fv, detErr
+			return links, detErr
 		}
-		return fv, err
+		return links, err
 	}
 
-	return fv, nil
+	return links, nil
 }
@@ -0,0 +1,155 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/postgresql"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+type linkReportStorage struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func NewLinkReportStorage(client postgresql.Client, logger *logging.Logger) interf.LinkReportStorage {
+	return &linkReportStorage{
+		client: client,
+		logger: logger,
+	}
+}
+
+func (s *linkReportStorage) Create(ctx context.Context, r entity.LinkReport) (entity.LinkReport, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		INSERT INTO link_reports
+			(link_id, reason, details, reporter_ip)
+		VALUES
+			($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	row := s.client.QueryRow(ctx, q, r.LinkID, r.Reason, r.Details, r.ReporterIP)
+	if err := row.Scan(&r.ID, &r.CreatedAt); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return r, detErr
+		}
+		return r, err
+	}
+
+	return r, nil
+}
+
+func (s *linkReportStorage) FindOneByID(ctx context.Context, id string) (r entity.LinkReport, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    r.id, r.link_id, l.short_version, r.reason, r.details, r.reporter_ip, r.created_at, r.resolved_at, r.resolution
+		FROM
+		    link_reports r
+		    JOIN links l ON l.id = r.link_id
+		WHERE
+		    r.id = $1
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	row := s.client.QueryRow(ctx, q, id)
+	err = row.Scan(&r.ID, &r.LinkID, &r.ShortVersion, &r.Reason, &r.Details, &r.ReporterIP, &r.CreatedAt,
+		&r.ResolvedAt, &r.Resolution)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return r, apperror.ErrNotFound
+		}
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return r, detErr
+		}
+		return r, err
+	}
+	return r, nil
+}
+
+func (s *linkReportStorage) FindAll(ctx context.Context, f entity.LinkReportFilter) (reports []entity.LinkReport, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    r.id, r.link_id, l.short_version, r.reason, r.details, r.reporter_ip, r.created_at, r.resolved_at, r.resolution
+		FROM
+		    link_reports r
+		    JOIN links l ON l.id = r.link_id
+		WHERE
+		    (r.resolved_at IS NOT NULL) = $1
+		    AND ($2 = '' OR r.link_id::text = $2)
+		ORDER BY
+		    r.created_at
+		LIMIT $3 OFFSET $4
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	rows, err := s.client.Query(ctx, q, f.Resolved, f.LinkID, f.Limit, f.Offset)
+	if err != nil {
+		return reports, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r entity.LinkReport
+		err = rows.Scan(&r.ID, &r.LinkID, &r.ShortVersion, &r.Reason, &r.Details, &r.ReporterIP, &r.CreatedAt,
+			&r.ResolvedAt, &r.Resolution)
+		if err != nil {
+			if detErr := postgresql.DetailedPgError(err); detErr != nil {
+				return reports, detErr
+			}
+			return reports, err
+		}
+		reports = append(reports, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return reports, detErr
+		}
+		return reports, err
+	}
+
+	return reports, nil
+}
+
+// ResolveAllByLinkID closes all open reports of the link, reports resolved earlier are kept as they are
+func (s *linkReportStorage) ResolveAllByLinkID(ctx context.Context, linkID, resolution string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		UPDATE
+		    link_reports r
+		SET
+		    resolved_at = (now() AT TIME ZONE 'utc'),
+		    resolution = $2
+		WHERE
+		    r.link_id = $1 AND r.resolved_at IS NULL
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	if _, err := s.client.Exec(ctx, q, linkID, resolution); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+
+	return nil
+}
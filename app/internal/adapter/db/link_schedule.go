@@ -0,0 +1,197 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/postgresql"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+type linkScheduleStorage struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func NewLinkScheduleStorage(client postgresql.Client, logger *logging.Logger) interf.LinkScheduleStorage {
+	return &linkScheduleStorage{
+		client: client,
+		logger: logger,
+	}
+}
+
+func (s *linkScheduleStorage) Create(ctx context.Context, ls entity.LinkSchedule) (entity.LinkSchedule, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		INSERT INTO link_schedules
+			(link_id, full_version, apply_at, created_by)
+		VALUES
+			($1, $2, $3, NULLIF($4, '')::uuid)
+		RETURNING id, created_at
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	row := s.client.QueryRow(ctx, q, ls.LinkID, ls.FullVersion, ls.ApplyAt, ls.CreatedBy)
+	if err := row.Scan(&ls.ID, &ls.CreatedAt); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return ls, detErr
+		}
+		return ls, err
+	}
+
+	return ls, nil
+}
+
+func (s *linkScheduleStorage) FindAllByLinkID(ctx context.Context, linkID string) ([]entity.LinkSchedule, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    s.id, s.link_id, s.full_version, s.apply_at, s.applied_at, COALESCE(s.created_by::text, ''), s.created_at
+		FROM
+		    link_schedules s
+		WHERE
+		    s.link_id = $1
+		ORDER BY
+		    s.apply_at
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	return s.query(ctx, q, linkID)
+}
+
+// FindDue locks pending schedules due by now, schedules locked by other instances are skipped.
+// It must be called in a transaction.
+func (s *linkScheduleStorage) FindDue(ctx context.Context, now time.Time, limit int) ([]entity.LinkSchedule, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    s.id, s.link_id, s.full_version, s.apply_at, s.applied_at, COALESCE(s.created_by::text, ''), s.created_at
+		FROM
+		    link_schedules s
+		WHERE
+		    s.applied_at IS NULL AND s.apply_at <= $1
+		ORDER BY
+		    s.apply_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	return s.query(ctx, q, now, limit)
+}
+
+func (s *linkScheduleStorage) query(ctx context.Context, q string, args ...interface{}) (schedules []entity.LinkSchedule, err error) {
+	rows, err := s.client.Query(ctx, q, args...)
+	if err != nil {
+		return schedules, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ls entity.LinkSchedule
+		err = rows.Scan(&ls.ID, &ls.LinkID, &ls.FullVersion, &ls.ApplyAt, &ls.AppliedAt, &ls.CreatedBy, &ls.CreatedAt)
+		if err != nil {
+			if detErr := postgresql.DetailedPgError(err); detErr != nil {
+				return schedules, detErr
+			}
+			return schedules, err
+		}
+		schedules = append(schedules, ls)
+	}
+
+	if err = rows.Err(); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return schedules, detErr
+		}
+		return schedules, err
+	}
+
+	return schedules, nil
+}
+
+func (s *linkScheduleStorage) MarkApplied(ctx context.Context, ids []string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		UPDATE
+		    link_schedules s
+		SET
+		    applied_at = (now() AT TIME ZONE 'utc')
+		WHERE
+		    s.id::text = ANY($1)
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	if _, err := s.client.Exec(ctx, q, ids); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+// DeleteStale cancels pending schedules due by now of links changed after the schedules were due,
+// so that a late run of the scheduler does not overwrite newer changes
+func (s *linkScheduleStorage) DeleteStale(ctx context.Context, now time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		DELETE FROM
+		    link_schedules s
+		WHERE
+		    s.applied_at IS NULL AND s.apply_at <= $1
+		    AND s.apply_at < (SELECT MAX(v.created_at) FROM link_versions v WHERE v.link_id = s.link_id)
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	tag, err := s.client.Exec(ctx, q, now)
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return 0, detErr
+		}
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// Delete cancels pending schedule of the link, applied schedules are kept
+func (s *linkScheduleStorage) Delete(ctx context.Context, id, linkID string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		DELETE FROM
+		    link_schedules s
+		WHERE
+		    s.id = $1 AND s.link_id = $2 AND s.applied_at IS NULL
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	tag, err := s.client.Exec(ctx, q, id, linkID)
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/migrations"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/migrate"
+	"github.com/slava-911/URL-shortener/pkg/postgresql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var logger = logging.GetLogger("panic")
+
+// testClient connects to the database of TEST_DB_DSN with all migrations applied, tests are skipped without it
+func testClient(t *testing.T) postgresql.Client {
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN is not set")
+	}
+	ctx := context.Background()
+
+	conn, err := pgx.Connect(ctx, dsn)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+	m, err := migrate.New(conn, migrations.FS, logger)
+	require.NoError(t, err)
+	require.NoError(t, m.Up(ctx, 0))
+
+	cfg := conn.Config()
+	client, err := postgresql.NewClient(1, 5*time.Second, postgresql.NewDBConfig(cfg.User, cfg.Password, cfg.Host,
+		fmt.Sprint(cfg.Port), cfg.Database), logger)
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+	return client
+}
+
+// testLink creates user and link with the first version saved an hour ago
+func testLink(t *testing.T, client postgresql.Client, fullVersion string) entity.Link {
+	ctx := context.Background()
+	suffix := fmt.Sprint(time.Now().UnixNano())
+
+	u, err := NewUserStorage(client, logger).Create(ctx, entity.User{
+		Name: "test", Email: suffix + "@example.com", Password: "hash", Role: entity.RoleUser})
+	require.NoError(t, err)
+
+	l := entity.Link{UserID: u.ID, FullVersion: fullVersion, ShortVersion: "t" + suffix}
+	l.ID, err = NewLinkStorage(client, logger).Create(ctx, l)
+	require.NoError(t, err)
+	_, err = NewLinkVersionStorage(client, logger).Create(ctx, entity.LinkVersion{LinkID: l.ID, FullVersion: fullVersion})
+	require.NoError(t, err)
+	_, err = client.Exec(ctx, `UPDATE link_versions SET created_at = created_at - interval '1 hour' WHERE link_id = $1`,
+		l.ID)
+	require.NoError(t, err)
+	return l
+}
+
+// Test scenario:
+// 1. Schedule of the link is due, redirect must serve its destination before the scheduler applies it
+// 2. Link is changed after the schedule was due, redirect must serve the changed destination
+func TestFindFullVersionByShortVersionSchedules(t *testing.T) {
+	client := testClient(t)
+	ctx := context.Background()
+	links := NewLinkStorage(client, logger)
+	l := testLink(t, client, "https://example.com/stored")
+
+	_, err := NewLinkScheduleStorage(client, logger).Create(ctx, entity.LinkSchedule{
+		LinkID: l.ID, FullVersion: "https://example.com/scheduled", ApplyAt: time.Now().UTC().Add(-30 * time.Minute)})
+	require.NoError(t, err)
+
+	got, err := links.FindFullVersionByShortVersion(ctx, l.ShortVersion, entity.ClickEvent{})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/scheduled", got.FullVersion)
+
+	edited := "https://example.com/edited"
+	require.NoError(t, links.Update(ctx, l.ID, map[string]string{"full_version": edited}))
+	_, err = NewLinkVersionStorage(client, logger).Create(ctx, entity.LinkVersion{LinkID: l.ID, FullVersion: edited})
+	require.NoError(t, err)
+
+	got, err = links.FindFullVersionByShortVersion(ctx, l.ShortVersion, entity.ClickEvent{})
+	require.NoError(t, err)
+	assert.Equal(t, edited, got.FullVersion)
+}
+
+// Test scenario:
+// 1. Link is moved to trash, its short version is already retired
+// 2. Purge must delete the link and count it although no short version is retired by it
+func TestPurgeDeletedCountsLinks(t *testing.T) {
+	client := testClient(t)
+	ctx := context.Background()
+	links := NewLinkStorage(client, logger)
+	l := testLink(t, client, "https://example.com/deleted")
+
+	require.NoError(t, links.Delete(ctx, l.ID))
+	_, err := client.Exec(ctx, `INSERT INTO retired_short_versions (short_version) VALUES ($1)`, l.ShortVersion)
+	require.NoError(t, err)
+
+	n, err := links.PurgeDeleted(ctx, time.Now().UTC().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
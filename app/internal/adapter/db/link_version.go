@@ -0,0 +1,148 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/postgresql"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+type linkVersionStorage struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func NewLinkVersionStorage(client postgresql.Client, logger *logging.Logger) interf.LinkVersionStorage {
+	return &linkVersionStorage{
+		client: client,
+		logger: logger,
+	}
+}
+
+// Create saves current state of the link as the next version. The link row is locked till the end of
+// the outer transaction, so that concurrent changes of the link do not take the same version number.
+func (s *linkVersionStorage) Create(ctx context.Context, v entity.LinkVersion) (entity.LinkVersion, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	lq := `
+		SELECT
+		    l.id
+		FROM
+		    links l
+		WHERE
+		    l.id = $1
+		FOR UPDATE
+	`
+	q := `
+		INSERT INTO link_versions
+			(link_id, version, full_version, description, created_by)
+		SELECT
+			$1, COALESCE(MAX(v.version), 0) + 1, $2, $3, NULLIF($4, '')::uuid
+		FROM
+		    link_versions v
+		WHERE
+		    v.link_id = $1
+		RETURNING version, created_at
+	`
+
+	err := s.client.BeginFunc(ctx, func(tx pgx.Tx) error {
+		s.logger.Tracef("SQL Query: %s", utils.FormatQuery(lq))
+		var linkID string
+		if err := tx.QueryRow(ctx, lq, v.LinkID).Scan(&linkID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return apperror.ErrNotFound
+			}
+			return err
+		}
+
+		// the version is read after the lock, a statement sees rows committed before it started
+		s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+		return tx.QueryRow(ctx, q, v.LinkID, v.FullVersion, v.Description, v.CreatedBy).Scan(&v.Version, &v.CreatedAt)
+	})
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return v, detErr
+		}
+		return v, err
+	}
+
+	return v, nil
+}
+
+func (s *linkVersionStorage) FindAllByLinkID(ctx context.Context, linkID string) (versions []entity.LinkVersion, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    v.link_id, v.version, v.full_version, v.description, COALESCE(v.created_by::text, ''), v.created_at
+		FROM
+		    link_versions v
+		WHERE
+		    v.link_id = $1
+		ORDER BY
+		    v.version DESC
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	rows, err := s.client.Query(ctx, q, linkID)
+	if err != nil {
+		return versions, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var v entity.LinkVersion
+		if err = rows.Scan(&v.LinkID, &v.Version, &v.FullVersion, &v.Description, &v.CreatedBy, &v.CreatedAt); err != nil {
+			if detErr := postgresql.DetailedPgError(err); detErr != nil {
+				return versions, detErr
+			}
+			return versions, err
+		}
+		versions = append(versions, v)
+	}
+
+	if err = rows.Err(); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return versions, detErr
+		}
+		return versions, err
+	}
+
+	return versions, nil
+}
+
+func (s *linkVersionStorage) FindOne(ctx context.Context, linkID string, version int) (v entity.LinkVersion, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    v.link_id, v.version, v.full_version, v.description, COALESCE(v.created_by::text, ''), v.created_at
+		FROM
+		    link_versions v
+		WHERE
+		    v.link_id = $1 AND v.version = $2
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	row := s.client.QueryRow(ctx, q, linkID, version)
+	if err = row.Scan(&v.LinkID, &v.Version, &v.FullVersion, &v.Description, &v.CreatedBy, &v.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return v, apperror.ErrNotFound
+		}
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return v, detErr
+		}
+		return v, err
+	}
+	return v, nil
+}
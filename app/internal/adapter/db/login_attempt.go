@@ -0,0 +1,200 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/postgresql"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+type loginAttemptStorage struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func NewLoginAttemptStorage(client postgresql.Client, logger *logging.Logger) interf.LoginAttemptStorage {
+	return &loginAttemptStorage{
+		client: client,
+		logger: logger,
+	}
+}
+
+func (s *loginAttemptStorage) FindOne(ctx context.Context, kind, value string) (a entity.LoginAttempt, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    a.kind, a.value, a.failures, a.last_failure_at, a.locked_until
+		FROM
+		    login_attempts a
+		WHERE
+		    a.kind = $1 AND a.value = $2
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	row := s.client.QueryRow(ctx, q, kind, value)
+	if err = row.Scan(&a.Kind, &a.Value, &a.Failures, &a.LastFailureAt, &a.LockedUntil); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return a, apperror.ErrNotFound
+		}
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return a, detErr
+		}
+		return a, err
+	}
+	return a, nil
+}
+
+func (s *loginAttemptStorage) FindAllLocked(ctx context.Context) (attempts []entity.LoginAttempt, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    a.kind, a.value, a.failures, a.last_failure_at, a.locked_until
+		FROM
+		    login_attempts a
+		WHERE
+		    a.locked_until > (now() AT TIME ZONE 'utc')
+		ORDER BY
+		    a.locked_until DESC
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	rows, err := s.client.Query(ctx, q)
+	if err != nil {
+		return attempts, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a entity.LoginAttempt
+		if err = rows.Scan(&a.Kind, &a.Value, &a.Failures, &a.LastFailureAt, &a.LockedUntil); err != nil {
+			if detErr := postgresql.DetailedPgError(err); detErr != nil {
+				return attempts, detErr
+			}
+			return attempts, err
+		}
+		attempts = append(attempts, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return attempts, detErr
+		}
+		return attempts, err
+	}
+
+	return attempts, nil
+}
+
+// IncrementFailures atomically counts one more failure, counter starts over if the last failure happened before resetBefore
+func (s *loginAttemptStorage) IncrementFailures(ctx context.Context, kind, value string,
+	resetBefore time.Time) (a entity.LoginAttempt, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		INSERT INTO login_attempts AS a
+			(kind, value, failures)
+		VALUES
+			($1, $2, 1)
+		ON CONFLICT (kind, value) DO UPDATE
+		SET
+		    failures = CASE WHEN a.last_failure_at < $3 THEN 1 ELSE a.failures + 1 END,
+		    last_failure_at = (now() AT TIME ZONE 'utc')
+		RETURNING a.kind, a.value, a.failures, a.last_failure_at, a.locked_until
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	row := s.client.QueryRow(ctx, q, kind, value, resetBefore)
+	if err = row.Scan(&a.Kind, &a.Value, &a.Failures, &a.LastFailureAt, &a.LockedUntil); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return a, detErr
+		}
+		return a, err
+	}
+	return a, nil
+}
+
+func (s *loginAttemptStorage) Lock(ctx context.Context, kind, value string, until time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		UPDATE
+		    login_attempts a
+		SET
+		    locked_until = $3
+		WHERE
+		    a.kind = $1 AND a.value = $2
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	if _, err := s.client.Exec(ctx, q, kind, value, until); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *loginAttemptStorage) Delete(ctx context.Context, kind, value string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		DELETE FROM
+		    login_attempts a
+		WHERE
+		    a.kind = $1 AND a.value = $2
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	tag, err := s.client.Exec(ctx, q, kind, value)
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+
+	return nil
+}
+
+// PurgeExpired deletes counters without failures since lastFailureBefore which are not locked at now
+func (s *loginAttemptStorage) PurgeExpired(ctx context.Context, lastFailureBefore, now time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		DELETE FROM
+		    login_attempts a
+		WHERE
+		    a.last_failure_at < $1 AND (a.locked_until IS NULL OR a.locked_until <= $2)
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	tag, err := s.client.Exec(ctx, q, lastFailureBefore, now)
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return 0, detErr
+		}
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
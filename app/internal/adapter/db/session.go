@@ -0,0 +1,277 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/postgresql"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+type sessionStorage struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func NewSessionStorage(client postgresql.Client, logger *logging.Logger) interf.SessionStorage {
+	return &sessionStorage{
+		client: client,
+		logger: logger,
+	}
+}
+
+func (s *sessionStorage) Create(ctx context.Context, sess entity.Session, t entity.RefreshToken) (entity.Session, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	qs := `
+		INSERT INTO sessions
+			(user_id, user_agent, ip, expires_at)
+		VALUES
+			($1, $2, $3, $4)
+		RETURNING id, created_at, last_used_at
+	`
+	qt := `
+		INSERT INTO refresh_tokens
+			(session_id, token_hash, expires_at)
+		VALUES
+			($1, $2, $3)
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(qs))
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(qt))
+
+	err := s.client.BeginFunc(ctx, func(tx pgx.Tx) error {
+		row := tx.QueryRow(ctx, qs, sess.UserID, sess.UserAgent, sess.IP, sess.ExpiresAt)
+		if err := row.Scan(&sess.ID, &sess.CreatedAt, &sess.LastUsedAt); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, qt, sess.ID, t.TokenHash, t.ExpiresAt)
+		return err
+	})
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return sess, detErr
+		}
+		return sess, err
+	}
+
+	return sess, nil
+}
+
+func (s *sessionStorage) FindOneByID(ctx context.Context, id string) (sess entity.Session, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    s.id, s.user_id, s.user_agent, s.ip, s.created_at, s.last_used_at, s.expires_at, s.revoked_at
+		FROM
+		    sessions s
+		WHERE
+		    s.id = $1
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	row := s.client.QueryRow(ctx, q, id)
+	err = row.Scan(&sess.ID, &sess.UserID, &sess.UserAgent, &sess.IP,
+		&sess.CreatedAt, &sess.LastUsedAt, &sess.ExpiresAt, &sess.RevokedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return sess, apperror.ErrNotFound
+		}
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return sess, detErr
+		}
+		return sess, err
+	}
+	return sess, nil
+}
+
+func (s *sessionStorage) FindAllActiveByUserID(ctx context.Context, userID string) (sessions []entity.Session, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    s.id, s.user_id, s.user_agent, s.ip, s.created_at, s.last_used_at, s.expires_at, s.revoked_at
+		FROM
+		    sessions s
+		WHERE
+		    s.user_id = $1 AND s.revoked_at IS NULL AND s.expires_at > (now() AT TIME ZONE 'utc')
+		ORDER BY
+		    s.last_used_at DESC
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	rows, err := s.client.Query(ctx, q, userID)
+	if err != nil {
+		return sessions, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sess entity.Session
+		err = rows.Scan(&sess.ID, &sess.UserID, &sess.UserAgent, &sess.IP,
+			&sess.CreatedAt, &sess.LastUsedAt, &sess.ExpiresAt, &sess.RevokedAt)
+		if err != nil {
+			if detErr := postgresql.DetailedPgError(err); detErr != nil {
+				return sessions, detErr
+			}
+			return sessions, err
+		}
+		sessions = append(sessions, sess)
+	}
+
+	if err = rows.Err(); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return sessions, detErr
+		}
+		return sessions, err
+	}
+
+	return sessions, nil
+}
+
+func (s *sessionStorage) FindRefreshTokenByHash(ctx context.Context, tokenHash string) (t entity.RefreshToken, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    t.id, t.session_id, t.token_hash, t.created_at, t.expires_at, t.used_at
+		FROM
+		    refresh_tokens t
+		WHERE
+		    t.token_hash = $1
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	row := s.client.QueryRow(ctx, q, tokenHash)
+	if err = row.Scan(&t.ID, &t.SessionID, &t.TokenHash, &t.CreatedAt, &t.ExpiresAt, &t.UsedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return t, apperror.ErrNotFound
+		}
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return t, detErr
+		}
+		return t, err
+	}
+	return t, nil
+}
+
+// RotateRefreshToken marks old token as used and stores new token of the same session.
+// apperror.ErrNotFound is returned if the old token has already been used.
+func (s *sessionStorage) RotateRefreshToken(ctx context.Context, oldTokenID string, t entity.RefreshToken) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	qu := `
+		UPDATE
+		    refresh_tokens t
+		SET
+		    used_at = (now() AT TIME ZONE 'utc')
+		WHERE
+		    t.id = $1 AND t.used_at IS NULL
+		RETURNING t.session_id
+	`
+	qi := `
+		INSERT INTO refresh_tokens
+			(session_id, token_hash, expires_at)
+		VALUES
+			($1, $2, $3)
+	`
+	qs := `
+		UPDATE
+		    sessions s
+		SET
+		    last_used_at = (now() AT TIME ZONE 'utc'), expires_at = $2
+		WHERE
+		    s.id = $1
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(qu))
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(qi))
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(qs))
+
+	err := s.client.BeginFunc(ctx, func(tx pgx.Tx) error {
+		if err := tx.QueryRow(ctx, qu, oldTokenID).Scan(&t.SessionID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return apperror.ErrNotFound
+			}
+			return err
+		}
+		if _, err := tx.Exec(ctx, qi, t.SessionID, t.TokenHash, t.ExpiresAt); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, qs, t.SessionID, t.ExpiresAt)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return err
+		}
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *sessionStorage) Revoke(ctx context.Context, id, userID string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		UPDATE
+		    sessions s
+		SET
+		    revoked_at = (now() AT TIME ZONE 'utc')
+		WHERE
+		    s.id = $1 AND s.user_id = $2 AND s.revoked_at IS NULL
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	tag, err := s.client.Exec(ctx, q, id, userID)
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *sessionStorage) RevokeAllByUserID(ctx context.Context, userID string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		UPDATE
+		    sessions s
+		SET
+		    revoked_at = (now() AT TIME ZONE 'utc')
+		WHERE
+		    s.user_id = $1 AND s.revoked_at IS NULL
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	if _, err := s.client.Exec(ctx, q, userID); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+
+	return nil
+}
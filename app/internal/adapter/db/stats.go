@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/postgresql"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+type statsStorage struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func NewStatsStorage(client postgresql.Client, logger *logging.Logger) interf.StatsStorage {
+	return &statsStorage{
+		client: client,
+		logger: logger,
+	}
+}
+
+func (s *statsStorage) Get(ctx context.Context) (st entity.Stats, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    (SELECT count(*) FROM users),
+		    (SELECT count(*) FROM users WHERE disabled_at IS NOT NULL),
+		    (SELECT count(*) FROM account_deletions WHERE completed_at IS NOT NULL),
+		    (SELECT count(*) FROM users WHERE role = 'admin'),
+		    (SELECT count(*) FROM links WHERE deleted_at IS NULL),
+		    (SELECT COALESCE(sum(clicked), 0) FROM links) +
+		        (SELECT COALESCE(sum(clicks), 0) FROM account_deletions WHERE completed_at IS NOT NULL),
+		    (SELECT count(*) FROM links WHERE created_at > (now() AT TIME ZONE 'utc') - INTERVAL '1 day'),
+		    (SELECT count(*) FROM links WHERE status <> 'active' AND deleted_at IS NULL),
+		    (SELECT count(*) FROM link_reports WHERE resolved_at IS NULL)
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	row := s.client.QueryRow(ctx, q)
+	if err = row.Scan(&st.Users, &st.DisabledUsers, &st.DeletedUsers, &st.Admins, &st.Links, &st.Clicks, &st.LinksLastDay,
+		&st.InactiveLinks, &st.OpenReports); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return st, detErr
+		}
+		return st, err
+	}
+
+	return st, nil
+}
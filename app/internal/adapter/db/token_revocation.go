@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/postgresql"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+type tokenRevocationStorage struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func NewTokenRevocationStorage(client postgresql.Client, logger *logging.Logger) interf.TokenRevocationStorage {
+	return &tokenRevocationStorage{
+		client: client,
+		logger: logger,
+	}
+}
+
+func (s *tokenRevocationStorage) RevokeUser(ctx context.Context, userID string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		UPDATE
+		    users u
+		SET
+		    tokens_revoked_at = $2
+		WHERE
+		    u.id = $1
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	tag, err := s.client.Exec(ctx, q, userID, at)
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+
+	return nil
+}
+
+// FindUserRevokedAt returns nil if tokens of the user have never been revoked
+func (s *tokenRevocationStorage) FindUserRevokedAt(ctx context.Context, userID string) (*time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    u.tokens_revoked_at
+		FROM
+		    users u
+		WHERE
+		    u.id = $1
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	var revokedAt *time.Time
+	if err := s.client.QueryRow(ctx, q, userID).Scan(&revokedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperror.ErrNotFound
+		}
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return nil, detErr
+		}
+		return nil, err
+	}
+
+	return revokedAt, nil
+}
@@ -0,0 +1,261 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/postgresql"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+type twoFactorStorage struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func NewTwoFactorStorage(client postgresql.Client, logger *logging.Logger) interf.TwoFactorStorage {
+	return &twoFactorStorage{
+		client: client,
+		logger: logger,
+	}
+}
+
+// SaveSecret stores pending secret, replacing the previous one if enrollment has not been confirmed yet.
+// apperror.ErrNotFound is returned if two-factor authentication is already enabled.
+func (s *twoFactorStorage) SaveSecret(ctx context.Context, userID, secret string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		INSERT INTO user_totp AS t
+			(user_id, secret)
+		VALUES
+			($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET
+		    secret = excluded.secret, created_at = (now() AT TIME ZONE 'utc'), last_used_step = 0
+		WHERE
+		    t.enabled_at IS NULL
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	tag, err := s.client.Exec(ctx, q, userID, secret)
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *twoFactorStorage) FindOneByUserID(ctx context.Context, userID string) (t entity.TOTP, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    t.user_id, t.secret, t.created_at, t.enabled_at, t.last_used_step
+		FROM
+		    user_totp t
+		WHERE
+		    t.user_id = $1
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	row := s.client.QueryRow(ctx, q, userID)
+	if err = row.Scan(&t.UserID, &t.Secret, &t.CreatedAt, &t.EnabledAt, &t.LastUsedStep); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return t, apperror.ErrNotFound
+		}
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return t, detErr
+		}
+		return t, err
+	}
+	return t, nil
+}
+
+// Enable confirms enrollment and replaces recovery codes
+func (s *twoFactorStorage) Enable(ctx context.Context, userID string, step int64, codeHashes []string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		UPDATE
+		    user_totp t
+		SET
+		    enabled_at = (now() AT TIME ZONE 'utc'), last_used_step = $2
+		WHERE
+		    t.user_id = $1 AND t.enabled_at IS NULL
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	err := s.client.BeginFunc(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, q, userID, step)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return apperror.ErrNotFound
+		}
+		return s.replaceRecoveryCodes(ctx, tx, userID, codeHashes)
+	})
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return err
+		}
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+// UseStep saves the time step of accepted code.
+// apperror.ErrNotFound is returned if a code of this or later step has already been used.
+func (s *twoFactorStorage) UseStep(ctx context.Context, userID string, step int64) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		UPDATE
+		    user_totp t
+		SET
+		    last_used_step = $2
+		WHERE
+		    t.user_id = $1 AND t.last_used_step < $2
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	tag, err := s.client.Exec(ctx, q, userID, step)
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks code as used, apperror.ErrNotFound is returned for unknown or already used code
+func (s *twoFactorStorage) UseRecoveryCode(ctx context.Context, userID, codeHash string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		UPDATE
+		    recovery_codes c
+		SET
+		    used_at = (now() AT TIME ZONE 'utc')
+		WHERE
+		    c.user_id = $1 AND c.code_hash = $2 AND c.used_at IS NULL
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	tag, err := s.client.Exec(ctx, q, userID, codeHash)
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *twoFactorStorage) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	err := s.client.BeginFunc(ctx, func(tx pgx.Tx) error {
+		return s.replaceRecoveryCodes(ctx, tx, userID, codeHashes)
+	})
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *twoFactorStorage) replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID string, codeHashes []string) error {
+	qd := `
+		DELETE FROM
+		    recovery_codes c
+		WHERE
+		    c.user_id = $1
+	`
+	qi := `
+		INSERT INTO recovery_codes
+			(user_id, code_hash)
+		SELECT
+		    $1, unnest($2::TEXT[])
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(qd))
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(qi))
+
+	if _, err := tx.Exec(ctx, qd, userID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, qi, userID, codeHashes)
+	return err
+}
+
+// Delete disables two-factor authentication and removes recovery codes
+func (s *twoFactorStorage) Delete(ctx context.Context, userID string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	qt := `
+		DELETE FROM
+		    user_totp t
+		WHERE
+		    t.user_id = $1
+	`
+	qc := `
+		DELETE FROM
+		    recovery_codes c
+		WHERE
+		    c.user_id = $1
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(qt))
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(qc))
+
+	err := s.client.BeginFunc(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, qt, userID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, qc, userID)
+		return err
+	})
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+
+	return nil
+}
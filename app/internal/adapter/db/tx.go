@@ -0,0 +1,22 @@
+package db
+
+import (
+	"context"
+
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/postgresql"
+)
+
+type transactor struct {
+	client postgresql.Client
+}
+
+func NewTransactor(client postgresql.Client) interf.Transactor {
+	return &transactor{
+		client: client,
+	}
+}
+
+func (t *transactor) WithinTx(ctx context.Context, f func(ctx context.Context) error) error {
+	return postgresql.WithinTx(ctx, t.client, f)
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/slava-911/URL-shortener/internal/interf"
 	"github.com/slava-911/URL-shortener/pkg/logging"
 	"github.com/slava-911/URL-shortener/pkg/postgresql"
+	"github.com/slava-911/URL-shortener/pkg/tracing"
 	"github.com/slava-911/URL-shortener/pkg/utils"
 )
 
@@ -29,19 +30,25 @@ func NewUserStorage(client postgresql.Client, logger *logging.Logger) interf.Use
 }
 
 func (s *userStorage) Create(ctx context.Context, u entity.User) (user entity.User, err error) {
+	ctx, span := tracer.Start(ctx, "userStorage.Create")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	q := `
 		INSERT INTO users
-			(name, email, password)
+			(name, email, password, role)
 		VALUES
-			($1, $2, $3)
+			($1, $2, $3, $4)
 		RETURNING id
 	`
 	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
 
-	row := s.client.QueryRow(ctx, q, u.Name, u.Email, u.Password)
+	if u.Role == "" {
+		u.Role = entity.RoleUser
+	}
+	row := s.client.QueryRow(ctx, q, u.Name, u.Email, u.Password, u.Role)
 	if err = row.Scan(&u.ID); err != nil {
 		if detErr := postgresql.DetailedPgError(err); detErr != nil {
 			return u, detErr
@@ -53,12 +60,15 @@ func (s *userStorage) Create(ctx context.Context, u entity.User) (user entity.Us
 }
 
 func (s *userStorage) FindOneByID(ctx context.Context, id string) (u entity.User, err error) {
+	ctx, span := tracer.Start(ctx, "userStorage.FindOneByID")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	q := `
 		SELECT
-		    u.id, u.name, u.email, u.password
+		    u.id, u.name, u.email, u.password, u.email_verified_at, u.role, u.disabled_at
 		FROM
 		    users u
 		WHERE
@@ -67,7 +77,7 @@ func (s *userStorage) FindOneByID(ctx context.Context, id string) (u entity.User
 	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
 
 	row := s.client.QueryRow(ctx, q, id)
-	if err = row.Scan(&u.ID, &u.Name, &u.Email, &u.Password); err != nil {
+	if err = row.Scan(&u.ID, &u.Name, &u.Email, &u.Password, &u.EmailVerifiedAt, &u.Role, &u.DisabledAt); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return u, apperror.ErrNotFound
 		}
@@ -80,12 +90,15 @@ func (s *userStorage) FindOneByID(ctx context.Context, id string) (u entity.User
 }
 
 func (s *userStorage) FindOneByEmail(ctx context.Context, email string) (u entity.User, err error) {
+	ctx, span := tracer.Start(ctx, "userStorage.FindOneByEmail")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	q := `
 		SELECT
-		    u.id, u.name, u.email, u.password
+		    u.id, u.name, u.email, u.password, u.email_verified_at, u.role, u.disabled_at
 		FROM
 		    users u
 		WHERE
@@ -94,7 +107,7 @@ func (s *userStorage) FindOneByEmail(ctx context.Context, email string) (u entit
 	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
 
 	row := s.client.QueryRow(ctx, q, email)
-	if err = row.Scan(&u.ID, &u.Name, &u.Email, &u.Password); err != nil {
+	if err = row.Scan(&u.ID, &u.Name, &u.Email, &u.Password, &u.EmailVerifiedAt, &u.Role, &u.DisabledAt); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return u, apperror.ErrNotFound
 		}
@@ -106,7 +119,10 @@ func (s *userStorage) FindOneByEmail(ctx context.Context, email string) (u entit
 	return u, nil
 }
 
-func (s *userStorage) Update(ctx context.Context, id string, chFields map[string]string) error {
+func (s *userStorage) Update(ctx context.Context, id string, chFields map[string]string) (err error) {
+	ctx, span := tracer.Start(ctx, "userStorage.Update")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
@@ -120,6 +136,11 @@ func (s *userStorage) Update(ctx context.Context, id string, chFields map[string
 		paramNum++
 	}
 
+	if _, ok := chFields["email"]; ok {
+		// new email has to be verified again
+		fields = append(fields, "email_verified_at=NULL")
+	}
+
 	fieldsToSet := strings.Join(fields, ", ")
 
 	q := `
@@ -146,7 +167,10 @@ func (s *userStorage) Update(ctx context.Context, id string, chFields map[string
 	return nil
 }
 
-func (s *userStorage) Delete(ctx context.Context, id string) error {
+func (s *userStorage) Delete(ctx context.Context, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "userStorage.Delete")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
@@ -167,3 +191,139 @@ func (s *userStorage) Delete(ctx context.Context, id string) error {
 
 	return nil
 }
+
+func (s *userStorage) VerifyEmail(ctx context.Context, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "userStorage.VerifyEmail")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		UPDATE
+		    users u
+		SET
+		    email_verified_at = (now() AT TIME ZONE 'utc')
+		WHERE
+		    u.id = $1 AND u.email_verified_at IS NULL
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	if _, err := s.client.Exec(ctx, q, id); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *userStorage) FindAll(ctx context.Context, f entity.UserFilter) (users []entity.User, err error) {
+	ctx, span := tracer.Start(ctx, "userStorage.FindAll")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    u.id, u.name, u.email, u.password, u.email_verified_at, u.role, u.disabled_at
+		FROM
+		    users u
+		WHERE
+		    $1 = '' OR u.email ILIKE '%' || $1 || '%' OR u.name ILIKE '%' || $1 || '%'
+		ORDER BY
+		    u.email
+		LIMIT $2 OFFSET $3
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	rows, err := s.client.Query(ctx, q, f.Query, f.Limit, f.Offset)
+	if err != nil {
+		return users, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var u entity.User
+		if err = rows.Scan(&u.ID, &u.Name, &u.Email, &u.Password, &u.EmailVerifiedAt, &u.Role, &u.DisabledAt); err != nil {
+			if detErr := postgresql.DetailedPgError(err); detErr != nil {
+				return users, detErr
+			}
+			return users, err
+		}
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return users, detErr
+		}
+		return users, err
+	}
+
+	return users, nil
+}
+
+func (s *userStorage) SetRole(ctx context.Context, id, role string) (err error) {
+	ctx, span := tracer.Start(ctx, "userStorage.SetRole")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		UPDATE
+		    users u
+		SET
+		    role = $2
+		WHERE
+		    u.id = $1
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	tag, err := s.client.Exec(ctx, q, id, role)
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *userStorage) SetDisabled(ctx context.Context, id string, disabled bool) (err error) {
+	ctx, span := tracer.Start(ctx, "userStorage.SetDisabled")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		UPDATE
+		    users u
+		SET
+		    disabled_at = CASE WHEN $2 THEN COALESCE(u.disabled_at, (now() AT TIME ZONE 'utc')) END
+		WHERE
+		    u.id = $1
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	tag, err := s.client.Exec(ctx, q, id, disabled)
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+
+	return nil
+}
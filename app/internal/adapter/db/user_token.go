@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/postgresql"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+type userTokenStorage struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func NewUserTokenStorage(client postgresql.Client, logger *logging.Logger) interf.UserTokenStorage {
+	return &userTokenStorage{
+		client: client,
+		logger: logger,
+	}
+}
+
+// Create stores new token, unused tokens of the same user and purpose stop working
+func (s *userTokenStorage) Create(ctx context.Context, t entity.UserToken) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	qu := `
+		UPDATE
+		    user_tokens t
+		SET
+		    used_at = (now() AT TIME ZONE 'utc')
+		WHERE
+		    t.user_id = $1 AND t.purpose = $2 AND t.used_at IS NULL
+	`
+	qi := `
+		INSERT INTO user_tokens
+			(user_id, purpose, token_hash, expires_at)
+		VALUES
+			($1, $2, $3, $4)
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(qu))
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(qi))
+
+	err := s.client.BeginFunc(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, qu, t.UserID, t.Purpose); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, qi, t.UserID, t.Purpose, t.TokenHash, t.ExpiresAt)
+		return err
+	})
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Use marks active token as used and returns it.
+// apperror.ErrNotFound is returned if there is no such token, or it is expired or already used.
+func (s *userTokenStorage) Use(ctx context.Context, tokenHash, purpose string) (t entity.UserToken, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		UPDATE
+		    user_tokens t
+		SET
+		    used_at = (now() AT TIME ZONE 'utc')
+		WHERE
+		    t.token_hash = $1 AND t.purpose = $2 AND t.used_at IS NULL AND t.expires_at > (now() AT TIME ZONE 'utc')
+		RETURNING t.id, t.user_id, t.purpose, t.token_hash, t.created_at, t.expires_at, t.used_at
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	row := s.client.QueryRow(ctx, q, tokenHash, purpose)
+	if err = row.Scan(&t.ID, &t.UserID, &t.Purpose, &t.TokenHash, &t.CreatedAt, &t.ExpiresAt, &t.UsedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return t, apperror.ErrNotFound
+		}
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return t, detErr
+		}
+		return t, err
+	}
+	return t, nil
+}
@@ -0,0 +1,178 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/postgresql"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+type webhookStorage struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func NewWebhookStorage(client postgresql.Client, logger *logging.Logger) interf.WebhookStorage {
+	return &webhookStorage{
+		client: client,
+		logger: logger,
+	}
+}
+
+func (s *webhookStorage) Create(ctx context.Context, w entity.Webhook) (entity.Webhook, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		INSERT INTO webhooks
+			(user_id, url, secret, events, description, active)
+		VALUES
+			($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	row := s.client.QueryRow(ctx, q, w.UserID, w.URL, w.Secret, w.Events, w.Description, w.Active)
+	if err := row.Scan(&w.ID, &w.CreatedAt); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return w, detErr
+		}
+		return w, err
+	}
+
+	return w, nil
+}
+
+func (s *webhookStorage) FindAllByUserID(ctx context.Context, userID string) (webhooks []entity.Webhook, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    w.id, w.user_id, w.url, w.secret, w.events, w.description, w.active, w.created_at
+		FROM
+		    webhooks w
+		WHERE
+		    w.user_id = $1
+		ORDER BY
+		    w.created_at DESC
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	rows, err := s.client.Query(ctx, q, userID)
+	if err != nil {
+		return webhooks, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var w entity.Webhook
+		err = rows.Scan(&w.ID, &w.UserID, &w.URL, &w.Secret, &w.Events, &w.Description, &w.Active, &w.CreatedAt)
+		if err != nil {
+			if detErr := postgresql.DetailedPgError(err); detErr != nil {
+				return webhooks, detErr
+			}
+			return webhooks, err
+		}
+		webhooks = append(webhooks, w)
+	}
+
+	if err = rows.Err(); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return webhooks, detErr
+		}
+		return webhooks, err
+	}
+
+	return webhooks, nil
+}
+
+func (s *webhookStorage) FindOne(ctx context.Context, id, userID string) (w entity.Webhook, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT
+		    w.id, w.user_id, w.url, w.secret, w.events, w.description, w.active, w.created_at
+		FROM
+		    webhooks w
+		WHERE
+		    w.id = $1 AND w.user_id = $2
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	row := s.client.QueryRow(ctx, q, id, userID)
+	err = row.Scan(&w.ID, &w.UserID, &w.URL, &w.Secret, &w.Events, &w.Description, &w.Active, &w.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return w, apperror.ErrNotFound
+		}
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return w, detErr
+		}
+		return w, err
+	}
+
+	return w, nil
+}
+
+func (s *webhookStorage) Update(ctx context.Context, w entity.Webhook) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		UPDATE
+		    webhooks w
+		SET
+		    url = $3, events = $4, description = $5, active = $6
+		WHERE
+		    w.id = $1 AND w.user_id = $2
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	tag, err := s.client.Exec(ctx, q, w.ID, w.UserID, w.URL, w.Events, w.Description, w.Active)
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *webhookStorage) Delete(ctx context.Context, id, userID string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		DELETE FROM
+		    webhooks w
+		WHERE
+		    w.id = $1 AND w.user_id = $2
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	tag, err := s.client.Exec(ctx, q, id, userID)
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,251 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/postgresql"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+const webhookDeliveryFields = `
+	d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.next_attempt_at, d.last_attempt_at,
+	d.response_status, d.last_error, d.created_at
+`
+
+type webhookDeliveryStorage struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func NewWebhookDeliveryStorage(client postgresql.Client, logger *logging.Logger) interf.WebhookDeliveryStorage {
+	return &webhookDeliveryStorage{
+		client: client,
+		logger: logger,
+	}
+}
+
+func (s *webhookDeliveryStorage) Create(ctx context.Context, d entity.WebhookDelivery) (entity.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		INSERT INTO webhook_deliveries
+			(webhook_id, event, payload, status, next_attempt_at)
+		VALUES
+			($1, $2, $3::jsonb, $4, $5)
+		RETURNING id, created_at
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	row := s.client.QueryRow(ctx, q, d.WebhookID, d.Event, string(d.Payload), d.Status, d.NextAttemptAt)
+	if err := row.Scan(&d.ID, &d.CreatedAt); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return d, detErr
+		}
+		return d, err
+	}
+
+	return d, nil
+}
+
+// CreateForEvent queues the event for every active webhook of the user subscribed to it and returns their number
+func (s *webhookDeliveryStorage) CreateForEvent(ctx context.Context, userID, event string, payload []byte) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		INSERT INTO webhook_deliveries
+			(webhook_id, event, payload)
+		SELECT
+		    w.id, $2, $3::jsonb
+		FROM
+		    webhooks w
+		    JOIN users u ON u.id = w.user_id
+		WHERE
+		    w.user_id = $1 AND w.active AND $2 = ANY(w.events) AND u.disabled_at IS NULL
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	tag, err := s.client.Exec(ctx, q, userID, event, string(payload))
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return 0, detErr
+		}
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (s *webhookDeliveryStorage) FindAllByWebhookID(ctx context.Context, webhookID string,
+	limit, offset int) (deliveries []entity.WebhookDelivery, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		SELECT` + webhookDeliveryFields + `
+		FROM
+		    webhook_deliveries d
+		WHERE
+		    d.webhook_id = $1
+		ORDER BY
+		    d.created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	rows, err := s.client.Query(ctx, q, webhookID, limit, offset)
+	if err != nil {
+		return deliveries, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d entity.WebhookDelivery
+		if err = scanWebhookDelivery(rows, &d); err != nil {
+			if detErr := postgresql.DetailedPgError(err); detErr != nil {
+				return deliveries, detErr
+			}
+			return deliveries, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return deliveries, detErr
+		}
+		return deliveries, err
+	}
+
+	return deliveries, nil
+}
+
+// Claim takes up to limit due pending deliveries and postpones them to leaseUntil,
+// so that other instances do not send them while the request is in flight
+func (s *webhookDeliveryStorage) Claim(ctx context.Context, now, leaseUntil time.Time,
+	limit int) (tasks []entity.WebhookTask, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		UPDATE
+		    webhook_deliveries d
+		SET
+		    next_attempt_at = $2
+		FROM
+		    webhooks w
+		WHERE
+		    w.id = d.webhook_id AND d.id IN (
+		        SELECT
+		            p.id
+		        FROM
+		            webhook_deliveries p
+		        WHERE
+		            p.status = 'pending' AND p.next_attempt_at <= $1
+		        ORDER BY
+		            p.next_attempt_at
+		        LIMIT $3
+		        FOR UPDATE SKIP LOCKED
+		    )
+		RETURNING` + webhookDeliveryFields + `,
+		    w.id, w.user_id, w.url, w.secret, w.events, w.description, w.active, w.created_at
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	rows, err := s.client.Query(ctx, q, now, leaseUntil, limit)
+	if err != nil {
+		return tasks, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t entity.WebhookTask
+		var payload []byte
+		w, d := &t.Webhook, &t.Delivery
+		err = rows.Scan(&d.ID, &d.WebhookID, &d.Event, &payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.LastAttemptAt, &d.ResponseStatus, &d.LastError, &d.CreatedAt,
+			&w.ID, &w.UserID, &w.URL, &w.Secret, &w.Events, &w.Description, &w.Active, &w.CreatedAt)
+		if err != nil {
+			if detErr := postgresql.DetailedPgError(err); detErr != nil {
+				return tasks, detErr
+			}
+			return tasks, err
+		}
+		d.Payload = payload
+		tasks = append(tasks, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return tasks, detErr
+		}
+		return tasks, err
+	}
+
+	return tasks, nil
+}
+
+// SaveAttempt stores the result of the attempt: status, attempts, next attempt time, response status and error
+func (s *webhookDeliveryStorage) SaveAttempt(ctx context.Context, d entity.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	q := `
+		UPDATE
+		    webhook_deliveries d
+		SET
+		    status = $2, attempts = $3, next_attempt_at = $4, last_attempt_at = $5, response_status = $6, last_error = $7
+		WHERE
+		    d.id = $1
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	_, err := s.client.Exec(ctx, q, d.ID, d.Status, d.Attempts, d.NextAttemptAt, d.LastAttemptAt,
+		d.ResponseStatus, d.LastError)
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return detErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+// PurgeFinished deletes succeeded and failed deliveries created before the given time
+func (s *webhookDeliveryStorage) PurgeFinished(ctx context.Context, createdBefore time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	q := `
+		DELETE FROM
+		    webhook_deliveries d
+		WHERE
+		    d.status <> 'pending' AND d.created_at < $1
+	`
+	s.logger.Tracef("SQL Query: %s", utils.FormatQuery(q))
+
+	tag, err := s.client.Exec(ctx, q, createdBefore)
+	if err != nil {
+		if detErr := postgresql.DetailedPgError(err); detErr != nil {
+			return 0, detErr
+		}
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func scanWebhookDelivery(row pgx.Row, d *entity.WebhookDelivery) error {
+	var payload []byte
+	err := row.Scan(&d.ID, &d.WebhookID, &d.Event, &payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+		&d.LastAttemptAt, &d.ResponseStatus, &d.LastError, &d.CreatedAt)
+	d.Payload = payload
+	return err
+}
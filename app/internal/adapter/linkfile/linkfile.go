@@ -0,0 +1,284 @@
+// Package linkfile writes links to files users take away and reads them back, including exports of other shorteners
+package linkfile
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+)
+
+// Supported formats, bitly is read only
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+	FormatBitly = "bitly"
+)
+
+var header = []string{"short_version", "full_version", "description", "created_at", "clicked", "status"}
+
+// aliases map normalized csv header names to fields, they cover Bitly and similar exports
+var aliases = map[string]string{
+	"short_version":   "short_version",
+	"short_url":       "short_version",
+	"link":            "short_version",
+	"bitlink":         "short_version",
+	"full_version":    "full_version",
+	"long_url":        "full_version",
+	"destination":     "full_version",
+	"url":             "full_version",
+	"description":     "description",
+	"title":           "description",
+	"created_at":      "created_at",
+	"created":         "created_at",
+	"created_(utc)":   "created_at",
+	"date_created":    "created_at",
+	"clicked":         "clicked",
+	"clicks":          "clicked",
+	"total_clicks":    "clicked",
+	"engagements":     "clicked",
+	"custom_bitlinks": "",
+}
+
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05-0700", "2006-01-02 15:04:05", "2006-01-02"}
+
+// record is a line of json lines file
+type record struct {
+	ShortVersion string     `json:"short_version"`
+	FullVersion  string     `json:"full_version"`
+	Description  string     `json:"description,omitempty"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+	Clicked      int        `json:"clicked"`
+	Status       string     `json:"status,omitempty"`
+}
+
+type Writer interface {
+	Write(l entity.Link) error
+	// Flush writes buffered data, it must be called after the last link
+	Flush() error
+}
+
+// ContentType returns media type of the format
+func ContentType(format string) string {
+	if format == FormatJSONL {
+		return "application/x-ndjson"
+	}
+	return "text/csv"
+}
+
+func NewWriter(w io.Writer, format string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(header); err != nil {
+			return nil, err
+		}
+		return &csvWriter{w: cw}, nil
+	case FormatJSONL:
+		return &jsonlWriter{enc: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) Write(l entity.Link) error {
+	return c.w.Write([]string{l.ShortVersion, l.FullVersion, l.Description, l.CreatedAt.UTC().Format(time.RFC3339),
+		strconv.Itoa(l.Clicked), l.Status})
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+func (j *jsonlWriter) Write(l entity.Link) error {
+	createdAt := l.CreatedAt.UTC()
+	return j.enc.Encode(record{
+		ShortVersion: l.ShortVersion,
+		FullVersion:  l.FullVersion,
+		Description:  l.Description,
+		CreatedAt:    &createdAt,
+		Clicked:      l.Clicked,
+		Status:       l.Status,
+	})
+}
+
+func (j *jsonlWriter) Flush() error {
+	return nil
+}
+
+// Read parses up to max links, rows which can not be parsed or have no absolute destination are returned as issues.
+// Error is returned if the file itself is broken or has more than max rows.
+func Read(r io.Reader, format string, max int) (rows []entity.LinkImportRow, issues []entity.LinkImportIssue, err error) {
+	switch format {
+	case FormatCSV, FormatBitly:
+		return readCSV(r, max)
+	case FormatJSONL:
+		return readJSONL(r, max)
+	default:
+		return nil, nil, fmt.Errorf("unsupported import format %q", format)
+	}
+}
+
+func readCSV(r io.Reader, max int) (rows []entity.LinkImportRow, issues []entity.LinkImportIssue, err error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	names, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, errors.New("file is empty")
+		}
+		return nil, nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range names {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		name = strings.ReplaceAll(name, " ", "_")
+		if field, ok := aliases[name]; ok && field != "" {
+			if _, dup := columns[field]; !dup {
+				columns[field] = i
+			}
+		}
+	}
+	if _, ok := columns["full_version"]; !ok {
+		return nil, nil, errors.New("destination column is missing, expected full_version or long_url")
+	}
+
+	for line := 2; ; line++ {
+		values, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rows)+len(issues) >= max {
+			return nil, nil, fmt.Errorf("file has more than %d links", max)
+		}
+
+		get := func(field string) string {
+			i, ok := columns[field]
+			if !ok || i >= len(values) {
+				return ""
+			}
+			return strings.TrimSpace(values[i])
+		}
+
+		rec := record{
+			ShortVersion: shortCode(get("short_version")),
+			FullVersion:  get("full_version"),
+			Description:  get("description"),
+		}
+		if v := get("created_at"); v != "" {
+			t, err := parseTime(v)
+			if err != nil {
+				issues = append(issues, entity.LinkImportIssue{Line: line, Reason: err.Error()})
+				continue
+			}
+			rec.CreatedAt = &t
+		}
+		if v := get("clicked"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				issues = append(issues, entity.LinkImportIssue{Line: line, Reason: "clicked must be a non-negative number"})
+				continue
+			}
+			rec.Clicked = n
+		}
+		if reason := rec.issue(); reason != "" {
+			issues = append(issues, entity.LinkImportIssue{Line: line, ShortVersion: rec.ShortVersion, Reason: reason})
+			continue
+		}
+		rows = append(rows, rec.row(line))
+	}
+
+	return rows, issues, nil
+}
+
+func readJSONL(r io.Reader, max int) (rows []entity.LinkImportRow, issues []entity.LinkImportIssue, err error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		if len(rows)+len(issues) >= max {
+			return nil, nil, fmt.Errorf("file has more than %d links", max)
+		}
+
+		var rec record
+		if err = json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			issues = append(issues, entity.LinkImportIssue{Line: line, Reason: "invalid json"})
+			continue
+		}
+		rec.ShortVersion = shortCode(rec.ShortVersion)
+		if reason := rec.issue(); reason != "" {
+			issues = append(issues, entity.LinkImportIssue{Line: line, ShortVersion: rec.ShortVersion, Reason: reason})
+			continue
+		}
+		rows = append(rows, rec.row(line))
+	}
+
+	return rows, issues, sc.Err()
+}
+
+// issue returns why the record can not be imported, empty if it can
+func (r record) issue() string {
+	u, err := url.Parse(r.FullVersion)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "destination must be an absolute http or https url"
+	}
+	if r.Clicked < 0 {
+		return "clicked must be a non-negative number"
+	}
+	return ""
+}
+
+func (r record) row(line int) entity.LinkImportRow {
+	l := entity.Link{
+		ShortVersion: r.ShortVersion,
+		FullVersion:  r.FullVersion,
+		Description:  r.Description,
+		Clicked:      r.Clicked,
+	}
+	if r.CreatedAt != nil {
+		l.CreatedAt = r.CreatedAt.UTC()
+	}
+	return entity.LinkImportRow{Line: line, Link: l}
+}
+
+// shortCode takes the last path segment, other shorteners export full short links such as bit.ly/3xYz
+func shortCode(v string) string {
+	v = strings.TrimRight(strings.TrimSpace(v), "/")
+	if i := strings.LastIndex(v, "/"); i >= 0 {
+		v = v[i+1:]
+	}
+	return v
+}
+
+func parseTime(v string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown time format of %q", v)
+}
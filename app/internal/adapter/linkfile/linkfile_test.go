@@ -0,0 +1,86 @@
+package linkfile
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	links := []entity.Link{
+		{ShortVersion: "AbCdEfG", FullVersion: "https://example.com/a?b=1,2", Description: `say "hi"`,
+			CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), Clicked: 42, Status: entity.LinkStatusActive},
+		{ShortVersion: "xyz", FullVersion: "https://example.com/", CreatedAt: time.Date(2025, 5, 6, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, format := range []string{FormatCSV, FormatJSONL} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, format)
+			require.NoError(t, err)
+			for _, l := range links {
+				require.NoError(t, w.Write(l))
+			}
+			require.NoError(t, w.Flush())
+
+			rows, issues, err := Read(&buf, format, 10)
+			require.NoError(t, err)
+			assert.Empty(t, issues)
+			require.Len(t, rows, len(links))
+			for i, row := range rows {
+				assert.Equal(t, links[i].ShortVersion, row.Link.ShortVersion)
+				assert.Equal(t, links[i].FullVersion, row.Link.FullVersion)
+				assert.Equal(t, links[i].Description, row.Link.Description)
+				assert.Equal(t, links[i].Clicked, row.Link.Clicked)
+				assert.True(t, links[i].CreatedAt.Equal(row.Link.CreatedAt))
+			}
+		})
+	}
+}
+
+func TestReadBitly(t *testing.T) {
+	file := "\ufeffBitlink,Long URL,Title,Created (UTC),Clicks\n" +
+		"bit.ly/3xYzAbc,https://example.com/long,Promo,2024-03-20T16:29:46+0000,17\n" +
+		"https://bit.ly/custom/,https://example.com/custom,,2024-03-21 10:00:00,\n" +
+		"bit.ly/bad,https://example.com/bad,,yesterday,1\n"
+
+	rows, issues, err := Read(strings.NewReader(file), FormatBitly, 10)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "3xYzAbc", rows[0].Link.ShortVersion)
+	assert.Equal(t, "https://example.com/long", rows[0].Link.FullVersion)
+	assert.Equal(t, "Promo", rows[0].Link.Description)
+	assert.Equal(t, 17, rows[0].Link.Clicked)
+	assert.Equal(t, time.Date(2024, 3, 20, 16, 29, 46, 0, time.UTC), rows[0].Link.CreatedAt)
+	assert.Equal(t, "custom", rows[1].Link.ShortVersion)
+
+	require.Len(t, issues, 1)
+	assert.Equal(t, 4, issues[0].Line)
+}
+
+func TestReadLimits(t *testing.T) {
+	_, _, err := Read(strings.NewReader("title\nfoo\n"), FormatCSV, 10)
+	assert.Error(t, err, "destination column is required")
+
+	_, _, err = Read(strings.NewReader("long_url\nhttps://a.com\nhttps://b.com\n"), FormatCSV, 1)
+	assert.Error(t, err)
+}
+
+func TestReadInvalidDestination(t *testing.T) {
+	file := `{"short_version":"ok","full_version":"https://example.com"}
+{"short_version":"ftp","full_version":"ftp://example.com"}
+{"short_version":"rel","full_version":"/relative"}
+not json
+`
+	rows, issues, err := Read(strings.NewReader(file), FormatJSONL, 10)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.Len(t, issues, 3)
+	assert.Equal(t, "ftp", issues[0].ShortVersion)
+	assert.Equal(t, 4, issues[2].Line)
+}
@@ -0,0 +1,30 @@
+package notifier
+
+import (
+	"context"
+
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+)
+
+type logNotifier struct {
+	logger *logging.Logger
+}
+
+// NewLogNotifier creates notifier which only writes lockouts to the log
+func NewLogNotifier(logger *logging.Logger) interf.LockoutNotifier {
+	return &logNotifier{
+		logger: logger,
+	}
+}
+
+func (n *logNotifier) NotifyLockout(ctx context.Context, a entity.LoginAttempt) error {
+	n.logger.WithFields(map[string]interface{}{
+		"kind":         a.Kind,
+		"value":        a.Value,
+		"failures":     a.Failures,
+		"locked_until": a.LockedUntil,
+	}).Warn("login locked")
+	return nil
+}
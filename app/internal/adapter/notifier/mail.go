@@ -0,0 +1,58 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/mailer"
+)
+
+type mailNotifier struct {
+	users  interf.UserStorage
+	mailer mailer.Mailer
+	next   interf.LockoutNotifier
+	logger *logging.Logger
+}
+
+// NewMailNotifier creates notifier which emails account owner about email lockouts.
+// Every lockout is passed to next notifier as well.
+func NewMailNotifier(users interf.UserStorage, m mailer.Mailer, next interf.LockoutNotifier,
+	logger *logging.Logger) interf.LockoutNotifier {
+	return &mailNotifier{
+		users:  users,
+		mailer: m,
+		next:   next,
+		logger: logger,
+	}
+}
+
+func (n *mailNotifier) NotifyLockout(ctx context.Context, a entity.LoginAttempt) error {
+	if err := n.next.NotifyLockout(ctx, a); err != nil {
+		return err
+	}
+	if a.Kind != entity.LoginAttemptEmail && a.Kind != entity.LoginAttemptTwoFactor {
+		return nil
+	}
+
+	// never send mail to addresses which are not registered
+	u, err := n.users.FindOneByEmail(ctx, a.Value)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to find user by email, error: %w", err)
+	}
+
+	return n.mailer.Send(ctx, mailer.Message{
+		To:      u.Email,
+		Subject: "Sign in is temporarily locked",
+		Body: fmt.Sprintf("Hello, %s!\n\nThere were %d failed attempts to sign in to your account, "+
+			"so sign in is locked until %s UTC.\nIf it was not you, consider resetting your password.\n",
+			u.Name, a.Failures, a.LockedUntil.Format("2006-01-02 15:04:05")),
+	})
+}
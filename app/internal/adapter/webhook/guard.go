@@ -0,0 +1,68 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"syscall"
+)
+
+// errForbiddenAddress is returned by dial of an address in the internal network
+var errForbiddenAddress = errors.New("address is not allowed")
+
+// forbiddenNets are special purpose ranges not covered by net.IP methods
+var forbiddenNets = func() (nets []*net.IPNet) {
+	for _, cidr := range []string{"0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "198.18.0.0/15", "240.0.0.0/4"} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// forbiddenIP reports whether the ip is not a public unicast address, webhooks must not reach the internal network
+func forbiddenIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, n := range forbiddenNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// dialControl checks the address right before connecting, after DNS is resolved,
+// so that a host resolving to a public address on validation and to an internal one later is caught too
+func dialControl(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || forbiddenIP(ip) {
+		return errForbiddenAddress
+	}
+	return nil
+}
+
+// validateURL checks that the URL is http(s) and its host resolves to public addresses only
+func validateURL(ctx context.Context, resolver *net.Resolver, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("webhook url must be an absolute http or https url")
+	}
+
+	addrs, err := resolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return errors.New("webhook url host can not be resolved")
+	}
+	for _, a := range addrs {
+		if forbiddenIP(a.IP) {
+			return errors.New("webhook url must resolve to a public address")
+		}
+	}
+	return nil
+}
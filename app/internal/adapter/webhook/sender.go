@@ -0,0 +1,108 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+)
+
+const userAgent = "URL-shortener-Webhook/1.0"
+
+// errDelivery hides why the connection failed, so that the owner of the webhook can not probe the network
+var errDelivery = errors.New("failed to deliver the request")
+
+type httpSender struct {
+	client   *http.Client
+	resolver *net.Resolver
+	// guard forbids internal addresses, it is off only in tests against local servers
+	guard  bool
+	logger *logging.Logger
+}
+
+// NewHTTPSender creates sender which posts signed deliveries as JSON.
+// Redirects are not followed, receivers have to be configured with the final URL.
+// Addresses of the internal network are refused on validation and on every dial.
+func NewHTTPSender(timeout time.Duration, logger *logging.Logger) interf.WebhookSender {
+	return newHTTPSender(timeout, true, logger)
+}
+
+func newHTTPSender(timeout time.Duration, guard bool, logger *logging.Logger) *httpSender {
+	dialer := &net.Dialer{Timeout: timeout}
+	if guard {
+		dialer.Control = dialControl
+	}
+	return &httpSender{
+		client: &http.Client{
+			Timeout: timeout,
+			// no proxy, it would dial internal addresses on our behalf
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: timeout,
+				MaxIdleConns:        100,
+				IdleConnTimeout:     90 * time.Second,
+			},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		resolver: net.DefaultResolver,
+		guard:    guard,
+		logger:   logger,
+	}
+}
+
+func (s *httpSender) Validate(ctx context.Context, rawURL string) error {
+	if !s.guard {
+		return nil
+	}
+	if err := validateURL(ctx, s.resolver, rawURL); err != nil {
+		return apperror.BadRequestError(err.Error())
+	}
+	return nil
+}
+
+func (s *httpSender) Send(ctx context.Context, w entity.Webhook, d entity.WebhookDelivery) (int, error) {
+	body, err := d.Body()
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(entity.WebhookHeaderID, d.ID)
+	req.Header.Set(entity.WebhookHeaderEvent, d.Event)
+	req.Header.Set(entity.WebhookHeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(entity.WebhookHeaderSignature, entity.SignWebhook(w.Secret, now, body))
+
+	s.logger.Debugf("send %s delivery %s to %s", d.Event, d.ID, w.URL)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.logger.Warnf("failed to send delivery %s to %s due to error %v", d.ID, w.URL, err)
+		return 0, errDelivery
+	}
+	defer resp.Body.Close()
+	// drain a bit of the body, so that the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
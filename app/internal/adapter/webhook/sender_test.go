@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPSender(t *testing.T) {
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		assert.Equal(t, "d1", r.Header.Get(entity.WebhookHeaderID))
+		assert.Equal(t, entity.WebhookPing, r.Header.Get(entity.WebhookHeaderEvent))
+		ts, err := strconv.ParseInt(r.Header.Get(entity.WebhookHeaderTimestamp), 10, 64)
+		require.NoError(t, err)
+		assert.Equal(t, entity.SignWebhook("whsec_test", time.Unix(ts, 0), body),
+			r.Header.Get(entity.WebhookHeaderSignature))
+		assert.True(t, json.Valid(body))
+
+		if status == http.StatusFound {
+			w.Header().Set("Location", "/other")
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	s := newHTTPSender(time.Second, false, logging.GetLogger("panic"))
+	wh := entity.Webhook{URL: srv.URL, Secret: "whsec_test"}
+	d := entity.WebhookDelivery{ID: "d1", Event: entity.WebhookPing, Payload: json.RawMessage(`{}`)}
+
+	tests := []struct {
+		status  int
+		wantErr bool
+	}{
+		{http.StatusNoContent, false},
+		{http.StatusInternalServerError, true},
+		// redirects are not followed
+		{http.StatusFound, true},
+	}
+	for _, tt := range tests {
+		status = tt.status
+		code, err := s.Send(context.Background(), wh, d)
+		assert.Equal(t, tt.wantErr, err != nil, tt.status)
+		assert.Equal(t, tt.status, code)
+	}
+}
+
+func TestHTTPSenderGuard(t *testing.T) {
+	var called bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	s := newHTTPSender(time.Second, true, logging.GetLogger("panic"))
+	wh := entity.Webhook{URL: srv.URL, Secret: "whsec_test"}
+	d := entity.WebhookDelivery{ID: "d1", Event: entity.WebhookPing, Payload: json.RawMessage(`{}`)}
+
+	code, err := s.Send(context.Background(), wh, d)
+	assert.Equal(t, errDelivery, err)
+	assert.Zero(t, code)
+	assert.False(t, called)
+
+	assert.Error(t, s.Validate(context.Background(), srv.URL))
+	assert.Error(t, s.Validate(context.Background(), "ftp://example.com/hook"))
+}
+
+func TestForbiddenIP(t *testing.T) {
+	tests := []struct {
+		ip        string
+		forbidden bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"0.0.0.0", true},
+		{"::", true},
+		{"224.0.0.1", true},
+		{"100.64.0.1", true},
+		{"::ffff:127.0.0.1", true},
+		{"fd00::1", true},
+		{"fe80::1", true},
+		{"93.184.216.34", false},
+		{"2606:2800:220:1:248:1893:25c8:1946", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.forbidden, forbiddenIP(net.ParseIP(tt.ip)), tt.ip)
+	}
+}
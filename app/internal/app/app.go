@@ -8,21 +8,28 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 	"time"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/julienschmidt/httprouter"
 	"github.com/rs/cors"
 	"github.com/slava-911/URL-shortener/internal/adapter/db"
 	"github.com/slava-911/URL-shortener/internal/config"
 	"github.com/slava-911/URL-shortener/internal/controller/http/handler"
+	"github.com/slava-911/URL-shortener/internal/controller/http/middleware"
 	"github.com/slava-911/URL-shortener/internal/domain/service"
+	"github.com/slava-911/URL-shortener/internal/interf"
 	"github.com/slava-911/URL-shortener/internal/jwt"
 	"github.com/slava-911/URL-shortener/pkg/cache/freecache"
+	"github.com/slava-911/URL-shortener/pkg/health"
 	"github.com/slava-911/URL-shortener/pkg/logging"
 	"github.com/slava-911/URL-shortener/pkg/metric"
+	"github.com/slava-911/URL-shortener/pkg/oidc"
 	"github.com/slava-911/URL-shortener/pkg/postgresql"
+	"github.com/slava-911/URL-shortener/pkg/ratelimit"
+	"github.com/slava-911/URL-shortener/pkg/tracing"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 type App struct {
@@ -30,7 +37,20 @@ type App struct {
 	logger     *logging.Logger
 	router     *httprouter.Router
 	httpServer *http.Server
-	dbClient   postgresql.Client
+	// metricsServer serves scrapes apart from the API, nil if disabled
+	metricsServer *http.Server
+	dbClient      postgresql.Client
+	jwtKeys       jwt.KeySet
+	linkService   interf.LinkService
+	accountData   interf.AccountDataService
+	webhooks      interf.WebhookService
+	loginGuard    interf.LoginGuardService
+	metrics       *metric.Metrics
+	cancel        context.CancelFunc
+	// shuttingDown fails readiness once graceful shutdown begins
+	shuttingDown *atomic.Bool
+	// stopTracing flushes pending spans
+	stopTracing func(ctx context.Context) error
 }
 
 func NewApp(config *config.Config, logger *logging.Logger) (App, error) {
@@ -42,51 +62,215 @@ func NewApp(config *config.Config, logger *logging.Logger) (App, error) {
 	//router.Handler(http.MethodGet, "/swagger", http.RedirectHandler("/swagger/index.html", http.StatusMovedPermanently))
 	//router.Handler(http.MethodGet, "/swagger/*any", httpSwagger.WrapHandler)
 
-	dbConfig := postgresql.NewDBConfig(
-		config.PostgreSQL.Username, config.PostgreSQL.Password,
-		config.PostgreSQL.Host, config.PostgreSQL.Port, config.PostgreSQL.Database,
-	)
-	dbClient, err := postgresql.NewClient(5, 5*time.Second, dbConfig, logger)
+	if config.PostgreSQL.MigrateOnStart {
+		logger.Info("database migration")
+		if err := Migrate(context.Background(), config, logger, []string{"up"}); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	logger.Info("tracing initialization")
+	stopTracing, err := setupTracing(config)
 	if err != nil {
 		logger.Fatal(err)
 	}
 
-	validate := validator.New()
-
-	logger.Println("cache initialization")
-	refreshTokenCache := freecache.NewCacheRepo(104857600) // 100MB
-
-	logger.Println("helpers initialization")
-	jwtHelper := jwt.NewHelper(refreshTokenCache, logger)
+	svc, err := newServices(config, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
 
 	logger.Info("create and register handlers")
 
-	logger.Info("heartbeat metric initialization")
+	logger.Info("metrics initialization")
+	metrics := metric.New()
+	if err = metrics.RegisterPool(svc.dbClient.Stat); err != nil {
+		logger.Fatal(err)
+	}
 	metricHandler := metric.Handler{}
 	metricHandler.Register(router)
 
-	userStorage := db.NewUserStorage(dbClient, logger)
-	userService := service.NewUserService(userStorage, logger)
-	userHandler := handler.NewUserHandler(jwtHelper, userService, validate, logger)
+	logger.Println("jwt keys initialization")
+	jwtKeys, err := jwt.NewKeySet(config.JWT.Algorithm, config.JWT.Secret, config.JWT.KeysDir,
+		config.JWT.KeyRotationInterval, config.JWT.AccessTokenTTL, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	logger.Println("rate limiter initialization")
+	rateLimitCache := freecache.NewCacheRepo(config.RateLimit.CacheSize)
+	svc.caches["rate_limit"] = rateLimitCache
+	rateLimiter := middleware.NewRateLimiter(config.RateLimit.Enabled, ratelimit.NewCacheStore(rateLimitCache),
+		config.RateLimit.Policies, logger)
+
+	apiKeyStorage := db.NewAPIKeyStorage(svc.dbClient, logger)
+	apiKeyService := service.NewAPIKeyService(apiKeyStorage, logger)
+	authMiddleware := jwt.NewMiddleware(jwtKeys, apiKeyService, svc.tokenDenylist, logger)
+	apiKeyHandler := handler.NewAPIKeyHandler(authMiddleware, apiKeyService, svc.validate, logger)
+	apiKeyHandler.Register(router)
+
+	sessionHandler := handler.NewSessionHandler(authMiddleware, svc.sessions, logger)
+	sessionHandler.Register(router)
+
+	if config.AppConfig.AdminUser.Email != "" {
+		logger.Info("admin user initialization")
+		if err = svc.users.EnsureAdmin(context.Background(), config.AppConfig.AdminUser.Email,
+			config.AppConfig.AdminUser.Password); err != nil {
+			logger.Fatal(err)
+		}
+	}
+	roleGuard := middleware.NewRoleGuard(svc.users, logger)
+
+	lockoutHandler := handler.NewLockoutHandler(authMiddleware, roleGuard, svc.loginGuard, logger)
+	lockoutHandler.Register(router)
+
+	accountHandler := handler.NewAccountHandler(authMiddleware, rateLimiter, svc.accounts, svc.users, svc.validate, logger)
+	accountHandler.Register(router)
+
+	logger.Println("helpers initialization")
+	jwtHelper := jwt.NewHelper(jwtKeys, svc.sessions, svc.users, config.JWT.Issuer, config.JWT.AccessTokenTTL, logger)
+
+	jwksHandler := handler.NewJWKSHandler(jwtKeys, logger)
+	jwksHandler.Register(router)
+
+	twoFactorStorage := db.NewTwoFactorStorage(svc.dbClient, logger)
+	twoFactorChallengeCache := freecache.NewCacheRepo(10485760) // 10MB
+	svc.caches["two_factor_challenge"] = twoFactorChallengeCache
+	twoFactorService := service.NewTwoFactorService(twoFactorStorage, svc.userStorage, svc.hasher, svc.loginGuard,
+		twoFactorChallengeCache, config.TwoFactor.Issuer, config.TwoFactor.ChallengeTTL, logger)
+	twoFactorHandler := handler.NewTwoFactorHandler(authMiddleware, twoFactorService, svc.users, svc.validate, logger)
+	twoFactorHandler.Register(router)
+
+	if config.OIDC.Enabled {
+		logger.Println("oidc provider initialization")
+		oidcProvider, err := oidc.NewProvider(context.Background(), config.OIDC.Issuer, config.OIDC.ClientID,
+			config.OIDC.ClientSecret, config.OIDC.RedirectURL, config.OIDC.Scopes)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		identityStorage := db.NewIdentityStorage(svc.dbClient, logger)
+		identityService := service.NewIdentityService(identityStorage, svc.userStorage, logger)
+		oidcLoginCache := freecache.NewCacheRepo(10485760) // 10MB
+		svc.caches["oidc_login"] = oidcLoginCache
+		oidcHandler := handler.NewOIDCHandler(rateLimiter, config.OIDC.Provider, config.OIDC.SecureCookie, oidcProvider,
+			oidcLoginCache, jwtHelper, identityService, twoFactorService, logger)
+		oidcHandler.Register(router)
+	}
+
+	userHandler := handler.NewUserHandler(authMiddleware, rateLimiter, jwtHelper, svc.users, svc.accounts,
+		twoFactorService, svc.accountData, svc.validate, logger)
 	userHandler.Register(router)
 
-	linkStorage := db.NewLinkStorage(dbClient, logger)
-	linkService := service.NewLinkService(linkStorage, logger)
-	linkHandler := handler.NewLinkHandler(linkService, validate, logger)
+	linkHandler := handler.NewLinkHandler(authMiddleware, rateLimiter, svc.links, svc.linkReports, metrics,
+		svc.validate, logger)
 	linkHandler.Register(router)
 
+	webhookHandler := handler.NewWebhookHandler(authMiddleware, svc.webhooks, svc.validate, logger)
+	webhookHandler.Register(router)
+
+	auditHandler := handler.NewAuditHandler(authMiddleware, svc.audit, svc.users, svc.links, logger)
+	auditHandler.Register(router)
+
+	adminHandler := handler.NewAdminHandler(authMiddleware, roleGuard, svc.users, svc.links, svc.linkReports,
+		svc.stats, svc.validate, logger)
+	adminHandler.Register(router)
+
+	for name, c := range svc.caches {
+		if err = metrics.RegisterCache(name, c); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	logger.Info("health checks initialization")
+	shuttingDown := new(atomic.Bool)
+	healthHandler, err := newHealthHandler(svc.dbClient, svc.caches, shuttingDown.Load, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	healthHandler.Register(router)
+
 	return App{
-		cfg:      config,
-		logger:   logger,
-		router:   router,
-		dbClient: dbClient,
+		cfg:          config,
+		logger:       logger,
+		router:       router,
+		dbClient:     svc.dbClient,
+		jwtKeys:      jwtKeys,
+		linkService:  svc.links,
+		accountData:  svc.accountData,
+		webhooks:     svc.webhooks,
+		loginGuard:   svc.loginGuard,
+		metrics:      metrics,
+		shuttingDown: shuttingDown,
+		stopTracing:  stopTracing,
 	}, nil
 }
 
+// setupTracing installs the exporter from config, spans are dropped if it is none
+func setupTracing(config *config.Config) (func(ctx context.Context) error, error) {
+	var exp sdktrace.SpanExporter
+	var err error
+	switch config.Tracing.Exporter {
+	case "otlp":
+		exp, err = tracing.NewOTLPExporter(context.Background(), config.Tracing.Endpoint, config.Tracing.Insecure)
+	case "stdout":
+		exp, err = tracing.NewStdoutExporter(os.Stdout)
+	case "none", "":
+		return func(ctx context.Context) error { return nil }, nil
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", config.Tracing.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tracing.Setup(exp, config.Tracing.ServiceName, config.Tracing.SampleRatio,
+		config.Tracing.TrustParent), nil
+}
+
 func (a *App) Run() {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+	a.startBackgroundJobs(ctx)
+	a.startMetrics()
 	a.startHTTP()
 }
 
+// startMetrics serves metrics on their own listener, so that they are not reachable through the API
+func (a *App) startMetrics() {
+	if a.cfg.Metrics.Addr == "" {
+		a.logger.Info("metrics listener is disabled")
+		return
+	}
+	listener, err := net.Listen("tcp", a.cfg.Metrics.Addr)
+	if err != nil {
+		a.logger.WithError(err).Fatal("failed to create metrics listener")
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(metric.MetricsURL, a.metrics.Handler())
+	a.metricsServer = &http.Server{
+		Handler:     mux,
+		ReadTimeout: a.cfg.HTTP.ReadTimeout,
+	}
+
+	a.logger.Infof("metrics are served at %s%s", a.cfg.Metrics.Addr, metric.MetricsURL)
+	go func() {
+		if err := a.metricsServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.logger.Fatal(err)
+		}
+	}()
+}
+
+func (a *App) startBackgroundJobs(ctx context.Context) {
+	a.logger.Info("background jobs initializing")
+	go a.jwtKeys.StartRotation(ctx)
+	go a.purgeLinkTrash(ctx)
+	go a.applyLinkSchedules(ctx)
+	go a.processAccountDeletions(ctx)
+	go a.sendWebhooks(ctx)
+	go a.purgeWebhookDeliveries(ctx)
+	go a.purgeLoginAttempts(ctx)
+}
+
 func (a *App) startHTTP() {
 	a.logger.WithFields(map[string]interface{}{
 		"IP":   a.cfg.HTTP.IP,
@@ -108,10 +292,15 @@ func (a *App) startHTTP() {
 		Debug:              a.cfg.HTTP.CORS.Debug,
 	})
 
-	cHandler := c.Handler(a.router)
+	cHandler := c.Handler(middleware.RequestInfo(a.router))
+
+	// probes and scrapes are not traced
+	route := metric.RouterRoute(a.router)
+	tHandler := tracing.Middleware(a.metrics.Middleware(cHandler, route), route,
+		metric.URL, health.LivenessURL, health.ReadinessURL)
 
 	a.httpServer = &http.Server{
-		Handler:      cHandler,
+		Handler:      tHandler,
 		WriteTimeout: a.cfg.HTTP.WriteTimeout,
 		ReadTimeout:  a.cfg.HTTP.ReadTimeout,
 	}
@@ -137,8 +326,15 @@ func doGracefulShutdown(a *App) {
 	signal.Notify(sigch, signals...)
 	sig := <-sigch
 	a.logger.Infof("Caught signal %s. Shutting down...", sig)
+	a.shuttingDown.Store(true)
 
 	defer a.dbClient.Close()
+	defer a.cancel()
+
+	if a.cfg.HTTP.ShutdownDelay > 0 {
+		a.logger.Infof("waiting %s for traffic to drain", a.cfg.HTTP.ShutdownDelay)
+		time.Sleep(a.cfg.HTTP.ShutdownDelay)
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -146,4 +342,12 @@ func doGracefulShutdown(a *App) {
 	if err != nil {
 		a.logger.Fatal(err)
 	}
+	if a.metricsServer != nil {
+		if err = a.metricsServer.Shutdown(ctx); err != nil {
+			a.logger.Errorf("failed to shutdown metrics server due to error %v", err)
+		}
+	}
+	if err = a.stopTracing(ctx); err != nil {
+		a.logger.Errorf("failed to flush spans due to error %v", err)
+	}
 }
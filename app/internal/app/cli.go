@@ -0,0 +1,66 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/slava-911/URL-shortener/internal/config"
+	"github.com/slava-911/URL-shortener/internal/controller/cli"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+)
+
+const usage = `usage: app [-config FILE] <command>
+  serve       run http server, the default command
+  migrate     manage database schema, see app migrate
+  links       create, list, delete, export and import links
+  users       create, disable and enable users, reset passwords
+  stats       print global counters`
+
+// Execute runs the command named by the first argument, http server is started without arguments
+func Execute(ctx context.Context, cfg *config.Config, logger *logging.Logger, args []string) error {
+	cmd := "serve"
+	if len(args) > 0 {
+		cmd, args = args[0], args[1:]
+	}
+
+	if cmd != "serve" {
+		// stdout is the result of the command
+		logger.Logger.SetOutput(os.Stderr)
+	}
+
+	switch cmd {
+	case "serve":
+		a, err := NewApp(cfg, logger)
+		if err != nil {
+			return err
+		}
+		logger.Println("Running Application")
+		a.Run()
+		return nil
+	case "migrate":
+		return Migrate(ctx, cfg, logger, args)
+	case "links", "users", "stats":
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		return cli.ErrUsage
+	}
+
+	svc, err := newServices(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer svc.dbClient.Close()
+
+	var c cli.Command
+	switch cmd {
+	case "links":
+		c = cli.NewLinksCommand(svc.links, svc.users, os.Stdin, os.Stdout)
+	case "users":
+		c = cli.NewUsersCommand(svc.users, svc.validate, os.Stdin, os.Stdout)
+	case "stats":
+		c = cli.NewStatsCommand(svc.stats, os.Stdout)
+	}
+
+	return c.Run(ctx, args)
+}
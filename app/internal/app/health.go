@@ -0,0 +1,96 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/slava-911/URL-shortener/migrations"
+	"github.com/slava-911/URL-shortener/pkg/cache"
+	"github.com/slava-911/URL-shortener/pkg/health"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/migrate"
+	"github.com/slava-911/URL-shortener/pkg/postgresql"
+)
+
+var healthProbe = []byte("health-probe")
+
+// newHealthHandler builds liveness checks of in-process state and readiness checks of all dependencies.
+// Readiness fails as soon as shutting down reports true. Probes are public, so checks report generic
+// errors and log the detailed ones.
+func newHealthHandler(dbClient postgresql.Client, caches map[string]cache.Repository,
+	shuttingDown func() bool, logger *logging.Logger) (*health.Handler, error) {
+	mgs, err := migrate.Parse(migrations.FS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations, error: %w", err)
+	}
+	var latest int64
+	if len(mgs) > 0 {
+		latest = mgs[len(mgs)-1].Version
+	}
+
+	live, ready := health.NewChecker(), health.NewChecker()
+	for name, c := range caches {
+		live.Add("cache:"+name, cacheCheck(c, logger))
+		ready.Add("cache:"+name, cacheCheck(c, logger))
+	}
+	ready.Add("database", func(ctx context.Context) (string, error) {
+		if err := dbClient.Ping(ctx); err != nil {
+			logger.Errorf("database health check failed due to error %v", err)
+			return "", errors.New("database is unavailable")
+		}
+		return "", nil
+	})
+	ready.Add("migrations", migrationCheck(dbClient, latest, logger))
+	ready.Add("shutdown", func(ctx context.Context) (string, error) {
+		if shuttingDown() {
+			return "", errors.New("server is shutting down")
+		}
+		return "", nil
+	})
+
+	return &health.Handler{Liveness: live, Readiness: ready}, nil
+}
+
+// cacheCheck writes the probe entry and reads it back
+func cacheCheck(c cache.Repository, logger *logging.Logger) health.CheckFunc {
+	return func(ctx context.Context) (string, error) {
+		if err := c.Set(healthProbe, healthProbe, 60); err != nil {
+			logger.Errorf("failed to set cache probe entry due to error %v", err)
+			return "", errors.New("failed to set probe entry")
+		}
+		val, err := c.Get(healthProbe)
+		if err != nil {
+			logger.Errorf("failed to get cache probe entry due to error %v", err)
+			return "", errors.New("failed to get probe entry")
+		}
+		if !bytes.Equal(val, healthProbe) {
+			return "", errors.New("probe entry is corrupted")
+		}
+		return fmt.Sprintf("%d entries", c.EntryCount()), nil
+	}
+}
+
+// migrationCheck fails while the schema is behind the migrations of the binary, dirty or not tracked
+// by migrations at all, a newer schema is fine during rolling updates
+func migrationCheck(q migrate.RowQuerier, latest int64, logger *logging.Logger) health.CheckFunc {
+	return func(ctx context.Context) (string, error) {
+		version, dirty, err := migrate.Current(ctx, q)
+		if errors.Is(err, migrate.ErrUntracked) {
+			return fmt.Sprintf("expected version %d", latest), err
+		}
+		if err != nil {
+			logger.Errorf("failed to read schema version due to error %v", err)
+			return "", errors.New("failed to read schema version")
+		}
+		info := fmt.Sprintf("version %d, expected %d", version, latest)
+		switch {
+		case dirty:
+			return info, migrate.ErrDirty
+		case version < latest:
+			return info, errors.New("schema is not migrated")
+		}
+		return info, nil
+	}
+}
@@ -0,0 +1,171 @@
+package app
+
+import (
+	"context"
+	"time"
+)
+
+// purgeLinkTrash permanently deletes links which are in trash longer than configured retention
+func (a *App) purgeLinkTrash(ctx context.Context) {
+	if a.cfg.Trash.PurgeInterval <= 0 {
+		a.logger.Info("link trash purge is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(a.cfg.Trash.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := a.linkService.PurgeTrash(ctx, a.cfg.Trash.Retention)
+			if err != nil {
+				a.logger.Errorf("failed to purge link trash due to error %v", err)
+				continue
+			}
+			if n > 0 {
+				a.logger.Infof("%d links are purged from trash", n)
+			}
+		}
+	}
+}
+
+// applyLinkSchedules switches destinations of links when their schedules are due,
+// redirects do not wait for it and resolve due schedules themselves
+func (a *App) applyLinkSchedules(ctx context.Context) {
+	if a.cfg.Schedule.Interval <= 0 {
+		a.logger.Info("link schedules are applied on redirect only")
+		return
+	}
+
+	ticker := time.NewTicker(a.cfg.Schedule.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := a.linkService.ApplyDueSchedules(ctx)
+			if err != nil {
+				a.logger.Errorf("failed to apply link schedules due to error %v", err)
+				continue
+			}
+			if n > 0 {
+				a.logger.Infof("destinations of %d links are changed by schedule", n)
+			}
+		}
+	}
+}
+
+// processAccountDeletions deletes accounts of users who asked for it, anonymizing their analytics
+func (a *App) processAccountDeletions(ctx context.Context) {
+	if a.cfg.AccountDeletion.Interval <= 0 {
+		a.logger.Warn("account deletion is disabled, deleted accounts stay disabled")
+		return
+	}
+
+	ticker := time.NewTicker(a.cfg.AccountDeletion.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := a.accountData.ProcessDeletions(ctx)
+			if err != nil {
+				a.logger.Errorf("failed to delete accounts due to error %v", err)
+				continue
+			}
+			if n > 0 {
+				a.logger.Infof("%d accounts are deleted", n)
+			}
+		}
+	}
+}
+
+// sendWebhooks sends queued webhook deliveries until the queue has no due ones
+func (a *App) sendWebhooks(ctx context.Context) {
+	if a.cfg.Webhook.Interval <= 0 {
+		a.logger.Warn("webhook delivery is disabled, events are queued only")
+		return
+	}
+
+	ticker := time.NewTicker(a.cfg.Webhook.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for ctx.Err() == nil {
+				n, err := a.webhooks.ProcessDeliveries(ctx)
+				if err != nil {
+					a.logger.Errorf("failed to send webhooks due to error %v", err)
+				}
+				if err != nil || n == 0 {
+					break
+				}
+			}
+		}
+	}
+}
+
+// purgeWebhookDeliveries deletes finished deliveries older than configured retention from the delivery log
+func (a *App) purgeWebhookDeliveries(ctx context.Context) {
+	if a.cfg.Webhook.PurgeInterval <= 0 {
+		a.logger.Info("webhook delivery log purge is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(a.cfg.Webhook.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := a.webhooks.PurgeDeliveries(ctx, a.cfg.Webhook.Retention)
+			if err != nil {
+				a.logger.Errorf("failed to purge webhook deliveries due to error %v", err)
+				continue
+			}
+			if n > 0 {
+				a.logger.Infof("%d webhook deliveries are purged", n)
+			}
+		}
+	}
+}
+
+// purgeLoginAttempts deletes failed login counters which are reset anyway, so that the table does not grow
+// with every address which has ever mistyped a password
+func (a *App) purgeLoginAttempts(ctx context.Context) {
+	if a.cfg.Lockout.PurgeInterval <= 0 {
+		a.logger.Info("login attempts purge is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(a.cfg.Lockout.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := a.loginGuard.Purge(ctx)
+			if err != nil {
+				a.logger.Errorf("failed to purge login attempts due to error %v", err)
+				continue
+			}
+			if n > 0 {
+				a.logger.Infof("%d login attempt counters are purged", n)
+			}
+		}
+	}
+}
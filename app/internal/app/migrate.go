@@ -0,0 +1,106 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/slava-911/URL-shortener/internal/config"
+	"github.com/slava-911/URL-shortener/migrations"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/migrate"
+	"github.com/slava-911/URL-shortener/pkg/postgresql"
+)
+
+const migrateUsage = `usage: app migrate <command>
+  up [N]      apply N pending migrations, all of them by default
+  down [N]    revert N last migrations, one by default
+  goto V      apply or revert migrations up to version V, 0 reverts everything
+  status      list migrations and their state
+  force V     mark migrations up to version V applied without running them`
+
+// Migrate runs migrate subcommand against the database from config with migrations embedded into the binary
+func Migrate(ctx context.Context, cfg *config.Config, logger *logging.Logger, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	dbConfig := postgresql.NewDBConfig(
+		cfg.PostgreSQL.Username, cfg.PostgreSQL.Password,
+		cfg.PostgreSQL.Host, cfg.PostgreSQL.Port, cfg.PostgreSQL.Database,
+	)
+	conn, err := postgresql.NewConn(ctx, dbConfig)
+	if err != nil {
+		return fmt.Errorf("failed to connect to postgres, error: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	m, err := migrate.New(conn, migrations.FS, logger)
+	if err != nil {
+		return fmt.Errorf("failed to read migrations, error: %w", err)
+	}
+
+	switch cmd, arg := args[0], args[1:]; cmd {
+	case "up":
+		n, err := migrateArg(arg, 0)
+		if err != nil {
+			return err
+		}
+		return m.Up(ctx, int(n))
+	case "down":
+		n, err := migrateArg(arg, 1)
+		if err != nil {
+			return err
+		}
+		return m.Down(ctx, int(n))
+	case "goto", "force":
+		if len(arg) == 0 {
+			return fmt.Errorf("%s needs version\n%s", cmd, migrateUsage)
+		}
+		v, err := migrateArg(arg, 0)
+		if err != nil {
+			return err
+		}
+		if cmd == "goto" {
+			return m.Goto(ctx, v)
+		}
+		return m.Force(ctx, v)
+	case "status":
+		states, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+		for _, st := range states {
+			state, appliedAt := "pending", ""
+			if st.Applied {
+				state, appliedAt = "applied", st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if st.Dirty {
+				state = "dirty"
+			}
+			if st.Up == "" {
+				state += " (unknown to this build)"
+			}
+			fmt.Fprintf(w, "%06d\t%s\t%s\t%s\n", st.Version, st.Name, state, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", cmd, migrateUsage)
+	}
+}
+
+func migrateArg(args []string, def int64) (int64, error) {
+	if len(args) == 0 {
+		return def, nil
+	}
+	v, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid number %q\n%s", args[0], migrateUsage)
+	}
+	return v, nil
+}
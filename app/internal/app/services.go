@@ -0,0 +1,189 @@
+package app
+
+import (
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/slava-911/URL-shortener/internal/adapter/db"
+	"github.com/slava-911/URL-shortener/internal/adapter/notifier"
+	"github.com/slava-911/URL-shortener/internal/adapter/webhook"
+	"github.com/slava-911/URL-shortener/internal/config"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/domain/service"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/internal/jwt"
+	"github.com/slava-911/URL-shortener/pkg/cache"
+	"github.com/slava-911/URL-shortener/pkg/cache/freecache"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/mailer"
+	"github.com/slava-911/URL-shortener/pkg/password"
+	"github.com/slava-911/URL-shortener/pkg/postgresql"
+)
+
+// services are shared by the http server and the command line tools
+type services struct {
+	dbClient      postgresql.Client
+	validate      *validator.Validate
+	mail          mailer.Mailer
+	hasher        password.Hasher
+	tokenDenylist interf.TokenDenylist
+	transactor    interf.Transactor
+	audit         interf.AuditService
+	sessions      interf.SessionService
+	userStorage   interf.UserStorage
+	loginGuard    interf.LoginGuardService
+	users         interf.UserService
+	accounts      interf.AccountService
+	linkStorage   interf.LinkStorage
+	links         interf.LinkService
+	linkReports   interf.LinkReportService
+	stats         interf.StatsService
+	accountData   interf.AccountDataService
+	webhooks      interf.WebhookService
+	// caches are exposed in metrics by name
+	caches map[string]cache.Repository
+}
+
+func newServices(config *config.Config, logger *logging.Logger) (*services, error) {
+	dbConfig := postgresql.NewDBConfig(
+		config.PostgreSQL.Username, config.PostgreSQL.Password,
+		config.PostgreSQL.Host, config.PostgreSQL.Port, config.PostgreSQL.Database,
+	)
+	dbClient, err := postgresql.NewClient(5, 5*time.Second, dbConfig, logger)
+	if err != nil {
+		return nil, err
+	}
+	dbClient = postgresql.NewTracedClient(dbClient)
+
+	validate := validator.New()
+
+	logger.Info("password policy initialization")
+	passwordPolicy := password.Policy{
+		MinLength:      config.Password.Policy.MinLength,
+		MaxLength:      config.Password.Policy.MaxLength,
+		RequireUpper:   config.Password.Policy.RequireUpper,
+		RequireLower:   config.Password.Policy.RequireLower,
+		RequireDigit:   config.Password.Policy.RequireDigit,
+		RequireSymbol:  config.Password.Policy.RequireSymbol,
+		RejectPersonal: config.Password.Policy.RejectPersonal,
+	}
+	if err = passwordPolicy.LoadCommon(config.Password.Policy.CommonFile); err != nil {
+		return nil, err
+	}
+	if err = passwordPolicy.Register(validate); err != nil {
+		return nil, err
+	}
+
+	logger.Println("cache initialization")
+	tokenDenylistCache := freecache.NewCacheRepo(104857600) // 100MB
+	tokenDenylist := jwt.NewDenylist(tokenDenylistCache, db.NewTokenRevocationStorage(dbClient, logger),
+		config.JWT.AccessTokenTTL)
+
+	sessionStorage := db.NewSessionStorage(dbClient, logger)
+	sessionService := service.NewSessionService(sessionStorage, tokenDenylist, config.JWT.RefreshTokenTTL, logger)
+
+	logger.Println("mailer initialization")
+	var mail mailer.Mailer
+	switch config.Mail.Driver {
+	case "smtp":
+		mail = mailer.NewSMTPMailer(config.Mail.SMTP.Host, config.Mail.SMTP.Port, config.Mail.SMTP.Username,
+			config.Mail.SMTP.Password, config.Mail.From)
+	case "file":
+		if mail, err = mailer.NewFileMailer(config.Mail.Dir, config.Mail.From, logger); err != nil {
+			return nil, err
+		}
+	default:
+		mail = mailer.NewLogMailer(logger)
+	}
+
+	logger.Println("password hasher initialization")
+	passwordHasher, err := password.NewHasher(config.Password.HashAlgorithm, config.Password.BcryptCost,
+		password.Argon2Params{
+			Memory:      config.Password.Argon2.Memory,
+			Iterations:  config.Password.Argon2.Iterations,
+			Parallelism: config.Password.Argon2.Parallelism,
+			SaltLength:  config.Password.Argon2.SaltLength,
+			KeyLength:   config.Password.Argon2.KeyLength,
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	transactor := db.NewTransactor(dbClient)
+	auditStorage := db.NewAuditStorage(dbClient, logger)
+	auditService := service.NewAuditService(auditStorage, logger)
+
+	userStorage := db.NewUserStorage(dbClient, logger)
+
+	loginAttemptStorage := db.NewLoginAttemptStorage(dbClient, logger)
+	lockoutNotifier := notifier.NewMailNotifier(userStorage, mail, notifier.NewLogNotifier(logger), logger)
+	loginGuardService := service.NewLoginGuardService(loginAttemptStorage, lockoutNotifier,
+		entity.LockoutPolicy{
+			Threshold: config.Lockout.EmailThreshold,
+			BaseDelay: config.Lockout.BaseDelay,
+			MaxDelay:  config.Lockout.MaxDelay,
+		},
+		entity.LockoutPolicy{
+			Threshold: config.Lockout.IPThreshold,
+			BaseDelay: config.Lockout.BaseDelay,
+			MaxDelay:  config.Lockout.MaxDelay,
+		},
+		config.Lockout.ResetAfter, logger)
+
+	userService := service.NewUserService(userStorage, sessionService, loginGuardService, passwordHasher, transactor,
+		auditService, logger)
+
+	userTokenStorage := db.NewUserTokenStorage(dbClient, logger)
+	accountService := service.NewAccountService(userStorage, userTokenStorage, sessionService, loginGuardService, mail,
+		passwordHasher, transactor, auditService, config.Mail.VerifyURL, config.Mail.ResetPasswordURL,
+		config.Mail.VerifyTokenTTL, config.Mail.ResetTokenTTL, logger)
+
+	webhookStorage := db.NewWebhookStorage(dbClient, logger)
+	webhookDeliveryStorage := db.NewWebhookDeliveryStorage(dbClient, logger)
+	webhookService := service.NewWebhookService(webhookStorage, webhookDeliveryStorage,
+		webhook.NewHTTPSender(config.Webhook.Timeout, logger),
+		entity.WebhookRetryPolicy{
+			MaxAttempts: config.Webhook.MaxAttempts,
+			BaseDelay:   config.Webhook.BaseDelay,
+			MaxDelay:    config.Webhook.MaxDelay,
+		},
+		config.Webhook.Timeout+time.Minute, logger)
+
+	linkStorage := db.NewLinkStorage(dbClient, logger)
+	linkVersionStorage := db.NewLinkVersionStorage(dbClient, logger)
+	linkScheduleStorage := db.NewLinkScheduleStorage(dbClient, logger)
+	linkService := service.NewLinkService(linkStorage, linkVersionStorage, linkScheduleStorage, transactor,
+		auditService, webhookService, logger)
+	linkReportStorage := db.NewLinkReportStorage(dbClient, logger)
+	linkReportService := service.NewLinkReportService(linkReportStorage, linkStorage, linkService, transactor, logger)
+
+	clickEventStorage := db.NewClickEventStorage(dbClient, logger)
+	accountDeletionStorage := db.NewAccountDeletionStorage(dbClient, logger)
+	accountDataService := service.NewAccountDataService(userService, userStorage, linkService, clickEventStorage,
+		accountDeletionStorage, sessionService, transactor, auditService, logger)
+
+	statsStorage := db.NewStatsStorage(dbClient, logger)
+	statsService := service.NewStatsService(statsStorage, logger)
+
+	return &services{
+		dbClient:      dbClient,
+		validate:      validate,
+		mail:          mail,
+		hasher:        passwordHasher,
+		tokenDenylist: tokenDenylist,
+		caches:        map[string]cache.Repository{"token_denylist": tokenDenylistCache},
+		transactor:    transactor,
+		audit:         auditService,
+		sessions:      sessionService,
+		userStorage:   userStorage,
+		loginGuard:    loginGuardService,
+		users:         userService,
+		accounts:      accountService,
+		linkStorage:   linkStorage,
+		links:         linkService,
+		linkReports:   linkReportService,
+		stats:         statsService,
+		accountData:   accountDataService,
+		webhooks:      webhookService,
+	}, nil
+}
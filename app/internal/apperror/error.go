@@ -6,8 +6,13 @@ import (
 )
 
 var (
-	ErrNotFound     = NewAppError("not found", "US-010", "")
-	ErrUnauthorized = NewAppError("unauthorized", "US-003", "")
+	ErrNotFound        = NewAppError("not found", "US-010", "")
+	ErrUnauthorized    = NewAppError("unauthorized", "US-003", "")
+	ErrForbidden       = NewAppError("forbidden", "US-004", "")
+	ErrLinkDisabled    = NewAppError("link is disabled", "US-005", "")
+	ErrLinkTakenDown   = NewAppError("link is unavailable for legal reasons", "US-006", "")
+	ErrTooManyRequests = NewAppError("too many requests", "US-029", "")
+	ErrShortTaken      = NewAppError("short version is already taken", "US-007", "")
 )
 
 type AppError struct {
@@ -3,15 +3,33 @@ package apperror
 import (
 	"errors"
 	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
 )
 
+var tracer = otel.Tracer("github.com/slava-911/URL-shortener/internal/apperror")
+
 type appHandler func(http.ResponseWriter, *http.Request) error
 
+// Middleware writes errors of the handler as responses. The handler runs in its own span,
+// so that its time is told apart from time of the middlewares around it.
 func Middleware(h appHandler) http.HandlerFunc {
+	name := handlerName(h)
 	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.Start(r.Context(), name)
+		defer span.End()
+
 		var appErr *AppError
-		err := h(w, r)
+		err := h(w, r.WithContext(ctx))
 		if err != nil {
+			span.RecordError(err)
+			if !errors.As(err, &appErr) {
+				span.SetStatus(codes.Error, err.Error())
+			}
 			if errors.As(err, &appErr) {
 				if errors.Is(err, ErrNotFound) {
 					w.WriteHeader(http.StatusNotFound)
@@ -21,6 +39,26 @@ func Middleware(h appHandler) http.HandlerFunc {
 					w.WriteHeader(http.StatusUnauthorized)
 					w.Write(ErrUnauthorized.Marshal())
 					return
+				} else if errors.Is(err, ErrForbidden) {
+					w.WriteHeader(http.StatusForbidden)
+					w.Write(ErrForbidden.Marshal())
+					return
+				} else if errors.Is(err, ErrLinkDisabled) {
+					w.WriteHeader(http.StatusGone)
+					w.Write(ErrLinkDisabled.Marshal())
+					return
+				} else if errors.Is(err, ErrLinkTakenDown) {
+					w.WriteHeader(http.StatusUnavailableForLegalReasons)
+					w.Write(ErrLinkTakenDown.Marshal())
+					return
+				} else if errors.Is(err, ErrShortTaken) {
+					w.WriteHeader(http.StatusConflict)
+					w.Write(ErrShortTaken.Marshal())
+					return
+				} else if errors.Is(err, ErrTooManyRequests) {
+					w.WriteHeader(http.StatusTooManyRequests)
+					w.Write(ErrTooManyRequests.Marshal())
+					return
 				}
 				err := err.(*AppError)
 				w.WriteHeader(http.StatusBadRequest)
@@ -32,3 +70,15 @@ func Middleware(h appHandler) http.HandlerFunc {
 		}
 	}
 }
+
+// handlerName turns name of the handler method into span name, ex: handler.(*linkHandler).Create-fm
+// becomes linkHandler.Create
+func handlerName(h appHandler) string {
+	name := runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+	name = strings.TrimSuffix(name, "-fm")
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.NewReplacer("(", "", ")", "", "*", "").Replace(name)
+}
@@ -19,11 +19,12 @@ type Config struct {
 	IsDebug       bool `env:"IS_DEBUG" env-default:"false"`
 	IsDevelopment bool `env:"IS_DEV" env-default:"false"`
 	HTTP          struct {
-		IP           string        `yaml:"ip" env:"HTTP-IP"`
-		Port         int           `yaml:"port" env:"HTTP-PORT"`
-		ReadTimeout  time.Duration `yaml:"read_timeout" env:"HTTP-READ-TIMEOUT"`
-		WriteTimeout time.Duration `yaml:"write_timeout" env:"HTTP-WHITE-TIMEOUT"`
-		CORS         struct {
+		IP            string        `yaml:"ip" env:"HTTP-IP"`
+		Port          int           `yaml:"port" env:"HTTP-PORT"`
+		ReadTimeout   time.Duration `yaml:"read_timeout" env:"HTTP-READ-TIMEOUT"`
+		WriteTimeout  time.Duration `yaml:"write_timeout" env:"HTTP-WHITE-TIMEOUT"`
+		ShutdownDelay time.Duration `yaml:"shutdown_delay" env:"HTTP-SHUTDOWN-DELAY" env-default:"5s"`
+		CORS          struct {
 			AllowedMethods     []string `yaml:"allowed_methods" env:"HTTP-CORS-ALLOWED-METHODS"`
 			AllowedOrigins     []string `yaml:"allowed_origins" env:"HTTP-CORS-ALLOWED-ORIGINS"`
 			AllowCredentials   bool     `yaml:"allow_credentials" env:"HTTP-CORS-ALLOW-CREDENTIALS"`
@@ -33,15 +34,134 @@ type Config struct {
 			Debug              bool     `yaml:"debug" env:"HTTP-CORS-DEBUG"`
 		} `yaml:"cors"`
 	} `yaml:"http"`
+	RateLimit struct {
+		Enabled   bool                       `yaml:"enabled" env:"RATE_LIMIT_ENABLED" env-default:"true"`
+		CacheSize int                        `yaml:"cache_size" env:"RATE_LIMIT_CACHE_SIZE" env-default:"33554432"`
+		Policies  map[string]RateLimitPolicy `yaml:"policies"`
+	} `yaml:"rate_limit"`
+	Lockout struct {
+		EmailThreshold int           `env:"LOCKOUT_EMAIL_THRESHOLD" env-default:"5"`
+		IPThreshold    int           `env:"LOCKOUT_IP_THRESHOLD" env-default:"20"`
+		BaseDelay      time.Duration `env:"LOCKOUT_BASE_DELAY" env-default:"1m"`
+		MaxDelay       time.Duration `env:"LOCKOUT_MAX_DELAY" env-default:"24h"`
+		ResetAfter     time.Duration `env:"LOCKOUT_RESET_AFTER" env-default:"24h"`
+		// PurgeInterval is how often counters forgotten after ResetAfter are deleted, 0 disables the purge
+		PurgeInterval time.Duration `env:"LOCKOUT_PURGE_INTERVAL" env-default:"1h"`
+	}
+	OIDC struct {
+		Enabled bool `env:"OIDC_ENABLED" env-default:"false"`
+		// Provider is the name identities are linked under
+		Provider     string   `env:"OIDC_PROVIDER" env-default:"oidc"`
+		Issuer       string   `env:"OIDC_ISSUER"`
+		ClientID     string   `env:"OIDC_CLIENT_ID"`
+		ClientSecret string   `env:"OIDC_CLIENT_SECRET"`
+		RedirectURL  string   `env:"OIDC_REDIRECT_URL" env-default:"http://localhost:10001/auth/oidc/callback"`
+		Scopes       []string `env:"OIDC_SCOPES" env-default:"email,profile"`
+		// SecureCookie marks the state cookie Secure, disable only for plain http development setups
+		SecureCookie bool `env:"OIDC_SECURE_COOKIE" env-default:"true"`
+	}
+	TwoFactor struct {
+		// Issuer is the account name prefix shown in authenticator apps
+		Issuer       string        `env:"TWO_FACTOR_ISSUER" env-default:"URL-shortener"`
+		ChallengeTTL time.Duration `env:"TWO_FACTOR_CHALLENGE_TTL" env-default:"5m"`
+	}
+	Trash struct {
+		// Retention is how long deleted links can be restored before they are purged permanently
+		Retention     time.Duration `env:"TRASH_RETENTION" env-default:"720h"`
+		PurgeInterval time.Duration `env:"TRASH_PURGE_INTERVAL" env-default:"1h"`
+	}
+	Schedule struct {
+		// Interval is how often due destination changes are saved to links
+		Interval time.Duration `env:"LINK_SCHEDULE_INTERVAL" env-default:"30s"`
+	}
+	AccountDeletion struct {
+		// Interval is how often accounts waiting for deletion are deleted
+		Interval time.Duration `env:"ACCOUNT_DELETION_INTERVAL" env-default:"1m"`
+	}
+	Webhook struct {
+		// Interval is how often queued deliveries are sent
+		Interval time.Duration `env:"WEBHOOK_INTERVAL" env-default:"5s"`
+		Timeout  time.Duration `env:"WEBHOOK_TIMEOUT" env-default:"10s"`
+		// failed delivery is retried after BaseDelay doubled with every attempt up to MaxDelay
+		MaxAttempts int           `env:"WEBHOOK_MAX_ATTEMPTS" env-default:"8"`
+		BaseDelay   time.Duration `env:"WEBHOOK_RETRY_BASE_DELAY" env-default:"30s"`
+		MaxDelay    time.Duration `env:"WEBHOOK_RETRY_MAX_DELAY" env-default:"6h"`
+		// Retention is how long finished deliveries are kept in the delivery log
+		Retention     time.Duration `env:"WEBHOOK_DELIVERY_RETENTION" env-default:"720h"`
+		PurgeInterval time.Duration `env:"WEBHOOK_PURGE_INTERVAL" env-default:"1h"`
+	}
+	Metrics struct {
+		// Addr is the separate listener of MetricsURL, it is not published with the API, empty disables it
+		Addr string `env:"METRICS_ADDR" env-default:"127.0.0.1:9090"`
+	}
+	Tracing struct {
+		// Exporter is otlp (spans are sent to OTLP/HTTP collector at Endpoint), stdout or none
+		Exporter    string `env:"TRACING_EXPORTER" env-default:"none"`
+		Endpoint    string `env:"TRACING_OTLP_ENDPOINT" env-default:"localhost:4318"`
+		Insecure    bool   `env:"TRACING_OTLP_INSECURE" env-default:"true"`
+		ServiceName string `env:"TRACING_SERVICE_NAME" env-default:"url-shortener"`
+		// SampleRatio is the share of recorded traces, traces of sampled callers are always recorded
+		// only with TrustParent, otherwise any public client could force recording of its requests
+		SampleRatio float64 `env:"TRACING_SAMPLE_RATIO" env-default:"1"`
+		TrustParent bool    `env:"TRACING_TRUST_PARENT" env-default:"false"`
+	}
+	Password struct {
+		// HashAlgorithm is bcrypt or argon2id, hashes of other algorithm or parameters are rehashed on login
+		HashAlgorithm string `env:"PASSWORD_HASH_ALGORITHM" env-default:"argon2id"`
+		BcryptCost    int    `env:"PASSWORD_BCRYPT_COST" env-default:"12"`
+		Argon2        struct {
+			Memory      uint32 `env:"PASSWORD_ARGON2_MEMORY" env-default:"65536"`
+			Iterations  uint32 `env:"PASSWORD_ARGON2_ITERATIONS" env-default:"3"`
+			Parallelism uint8  `env:"PASSWORD_ARGON2_PARALLELISM" env-default:"2"`
+			SaltLength  uint32 `env:"PASSWORD_ARGON2_SALT_LENGTH" env-default:"16"`
+			KeyLength   uint32 `env:"PASSWORD_ARGON2_KEY_LENGTH" env-default:"32"`
+		}
+		Policy struct {
+			MinLength      int  `env:"PASSWORD_MIN_LENGTH" env-default:"8"`
+			MaxLength      int  `env:"PASSWORD_MAX_LENGTH" env-default:"128"`
+			RequireUpper   bool `env:"PASSWORD_REQUIRE_UPPER" env-default:"true"`
+			RequireLower   bool `env:"PASSWORD_REQUIRE_LOWER" env-default:"true"`
+			RequireDigit   bool `env:"PASSWORD_REQUIRE_DIGIT" env-default:"true"`
+			RequireSymbol  bool `env:"PASSWORD_REQUIRE_SYMBOL" env-default:"false"`
+			RejectPersonal bool `env:"PASSWORD_REJECT_PERSONAL" env-default:"true"`
+			// CommonFile is a list of breached or common passwords, one per line, the built-in list is used if empty
+			CommonFile string `env:"PASSWORD_COMMON_FILE"`
+		}
+	}
+	Mail struct {
+		// Driver is one of smtp, file (messages are saved to Dir) or log
+		Driver string `env:"MAIL_DRIVER" env-default:"log"`
+		From   string `env:"MAIL_FROM" env-default:"noreply@localhost"`
+		Dir    string `env:"MAIL_DIR" env-default:"mail"`
+		SMTP   struct {
+			Host     string `env:"SMTP_HOST" env-default:"localhost"`
+			Port     int    `env:"SMTP_PORT" env-default:"587"`
+			Username string `env:"SMTP_USERNAME"`
+			Password string `env:"SMTP_PASSWORD"`
+		}
+		// Tokens are appended to these URLs in emails
+		VerifyURL        string        `env:"MAIL_VERIFY_URL" env-default:"http://localhost:10001/auth/verify?token="`
+		ResetPasswordURL string        `env:"MAIL_RESET_PASSWORD_URL" env-default:"http://localhost:3000/password-reset?token="`
+		VerifyTokenTTL   time.Duration `env:"MAIL_VERIFY_TOKEN_TTL" env-default:"48h"`
+		ResetTokenTTL    time.Duration `env:"MAIL_RESET_TOKEN_TTL" env-default:"1h"`
+	}
 	AppConfig struct {
-		LogLevel  string `env:"LOG_LEVEL" env-default:"trace"`
+		LogLevel string `env:"LOG_LEVEL" env-default:"trace"`
+		// AdminUser is created on startup or promoted if the email is already registered, empty email skips it
 		AdminUser struct {
-			Email    string `env:"ADMIN_EMAIL" env-default:"admin"`
-			Password string `env:"ADMIN_PWD" env-default:"admin"`
+			Email    string `env:"ADMIN_EMAIL"`
+			Password string `env:"ADMIN_PWD"`
 		}
 	}
 	JWT struct {
-		Secret string `env:"JWT_SECRET" env-required:"true"`
+		// Algorithm is one of HS256 (signed with Secret), RS256 or EdDSA (signed with keys from KeysDir)
+		Algorithm           string        `env:"JWT_ALGORITHM" env-default:"HS256"`
+		Secret              string        `env:"JWT_SECRET"`
+		KeysDir             string        `env:"JWT_KEYS_DIR"`
+		KeyRotationInterval time.Duration `env:"JWT_KEY_ROTATION_INTERVAL" env-default:"0"`
+		Issuer              string        `env:"JWT_ISSUER" env-default:"url-shortener"`
+		AccessTokenTTL      time.Duration `env:"JWT_ACCESS_TOKEN_TTL" env-default:"60m"`
+		RefreshTokenTTL     time.Duration `env:"JWT_REFRESH_TOKEN_TTL" env-default:"720h"`
 	}
 	PostgreSQL struct {
 		Username string `env:"DB_USERNAME" env-required:"true"`
@@ -49,9 +169,20 @@ type Config struct {
 		Host     string `env:"DB_HOST" env-required:"true"`
 		Port     string `env:"DB_PORT" env-required:"true"`
 		Database string `env:"DB_DATABASE" env-required:"true"`
+		// MigrateOnStart applies pending migrations before the server starts
+		MigrateOnStart bool `env:"MIGRATE_ON_START" env-default:"false"`
 	}
 }
 
+// RateLimitPolicy allows Rate requests per Period with bursts up to Burst requests.
+// Key is what requests are counted by: ip, user or api_key.
+type RateLimitPolicy struct {
+	Rate   int           `yaml:"rate"`
+	Period time.Duration `yaml:"period"`
+	Burst  int           `yaml:"burst"`
+	Key    string        `yaml:"key"`
+}
+
 const (
 	EnvConfigPathName  = "CONFIG-PATH"
 	FlagConfigPathName = "config"
@@ -0,0 +1,90 @@
+// Package cli holds operator commands of the binary, they call the same services as http handlers
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+)
+
+// Command is a group of subcommands, args do not include the name of the group
+type Command interface {
+	Run(ctx context.Context, args []string) error
+}
+
+// ErrUsage is returned for wrong arguments, the usage text is already printed
+var ErrUsage = errors.New("invalid usage")
+
+// subcommand is a leaf command, run defines its flags on fs and parses args with them
+type subcommand struct {
+	usage string
+	run   func(ctx context.Context, fs *flag.FlagSet, args []string) error
+}
+
+// dispatch runs subcommand named by the first argument
+func dispatch(ctx context.Context, out io.Writer, group string, subs map[string]subcommand, args []string) error {
+	if len(args) == 0 {
+		printUsage(out, group, subs)
+		return ErrUsage
+	}
+
+	sub, ok := subs[args[0]]
+	if !ok {
+		fmt.Fprintf(out, "unknown command %q\n", args[0])
+		printUsage(out, group, subs)
+		return ErrUsage
+	}
+
+	fs := flag.NewFlagSet(group+" "+args[0], flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() {
+		fmt.Fprintf(out, "usage: app %s %s %s\n", group, args[0], sub.usage)
+		fs.PrintDefaults()
+	}
+
+	err := sub.run(ctx, fs, args[1:])
+	if err == ErrUsage {
+		fs.Usage()
+	}
+	return err
+}
+
+// parse parses flags and checks the number of positional arguments
+func parse(fs *flag.FlagSet, args []string, positional int) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		// flag package has printed the usage already
+		return nil, fmt.Errorf("%w: %v", ErrUsage, err)
+	}
+	if fs.NArg() != positional {
+		return nil, ErrUsage
+	}
+	return fs.Args(), nil
+}
+
+func printUsage(out io.Writer, group string, subs map[string]subcommand) {
+	names := make([]string, 0, len(subs))
+	for name := range subs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(out, "usage: app %s <command>\n", group)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %s %s\n", name, subs[name].usage)
+	}
+}
+
+// findUser accepts either email or id of the user
+func findUser(ctx context.Context, users interf.UserService, ref string) (entity.User, error) {
+	if strings.Contains(ref, "@") {
+		return users.GetOneByEmail(ctx, ref)
+	}
+	return users.GetOneByID(ctx, ref)
+}
@@ -0,0 +1,187 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/slava-911/URL-shortener/internal/adapter/linkfile"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+)
+
+// importMax limits the number of links in one imported file
+const importMax = 10000
+
+type linksCommand struct {
+	links interf.LinkService
+	users interf.UserService
+	in    io.Reader
+	out   io.Writer
+}
+
+// NewLinksCommand creates links commands, imported file is read from in
+func NewLinksCommand(ls interf.LinkService, us interf.UserService, in io.Reader, out io.Writer) Command {
+	return &linksCommand{
+		links: ls,
+		users: us,
+		in:    in,
+		out:   out,
+	}
+}
+
+func (c *linksCommand) Run(ctx context.Context, args []string) error {
+	return dispatch(ctx, c.out, "links", map[string]subcommand{
+		"create": {usage: "-user EMAIL|ID -url URL [-description TEXT]", run: c.create},
+		"list":   {usage: "[-user EMAIL|ID] [-query TEXT] [-limit N] [-offset N]", run: c.list},
+		"delete": {usage: "ID", run: c.delete},
+		"export": {usage: "[-user EMAIL|ID] [-format csv|jsonl]", run: c.export},
+		"import": {usage: "-user EMAIL|ID [-format csv|jsonl|bitly] [-on-conflict generate|skip] < FILE", run: c.importLinks},
+	}, args)
+}
+
+func (c *linksCommand) create(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	userRef := fs.String("user", "", "owner of the link")
+	fullVersion := fs.String("url", "", "absolute url the link redirects to")
+	description := fs.String("description", "", "description of the link")
+	if _, err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if *userRef == "" || *fullVersion == "" {
+		return ErrUsage
+	}
+
+	u, err := findUser(ctx, c.users, *userRef)
+	if err != nil {
+		return err
+	}
+
+	id, err := c.links.Create(ctx, entity.Link{FullVersion: *fullVersion, Description: *description, UserID: u.ID})
+	if err != nil {
+		return err
+	}
+	l, err := c.links.GetOneByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "%s\t%s\n", l.ID, l.ShortVersion)
+	return nil
+}
+
+func (c *linksCommand) list(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	userRef := fs.String("user", "", "show links of the user only")
+	query := fs.String("query", "", "search in urls, short versions and descriptions")
+	limit := fs.Int("limit", 50, "number of links")
+	offset := fs.Int("offset", 0, "number of links to skip")
+	if _, err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	f := entity.LinkFilter{Query: *query, Limit: *limit, Offset: *offset}
+	if *userRef != "" {
+		u, err := findUser(ctx, c.users, *userRef)
+		if err != nil {
+			return err
+		}
+		f.UserID = u.ID
+	}
+
+	links, err := c.links.GetAll(ctx, f)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSHORT\tSTATUS\tCLICKS\tCREATED AT\tURL")
+	for _, l := range links {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", l.ID, l.ShortVersion, l.Status, l.Clicked,
+			l.CreatedAt.Format("2006-01-02 15:04:05"), l.FullVersion)
+	}
+	return w.Flush()
+}
+
+// delete moves the link to the trash of its owner, it can be restored until the trash is purged
+func (c *linksCommand) delete(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	pos, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	return c.links.Delete(ctx, pos[0])
+}
+
+func (c *linksCommand) export(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	userRef := fs.String("user", "", "export links of the user only")
+	format := fs.String("format", linkfile.FormatCSV, "csv or jsonl")
+	if _, err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	var f entity.LinkFilter
+	if *userRef != "" {
+		u, err := findUser(ctx, c.users, *userRef)
+		if err != nil {
+			return err
+		}
+		f.UserID = u.ID
+	}
+
+	w, err := linkfile.NewWriter(c.out, *format)
+	if err != nil {
+		return err
+	}
+	if err = c.links.Export(ctx, f, w.Write); err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+// importLinks reads links of the user from stdin
+func (c *linksCommand) importLinks(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	userRef := fs.String("user", "", "owner of imported links")
+	format := fs.String("format", linkfile.FormatCSV, "csv, jsonl or bitly")
+	onConflict := fs.String("on-conflict", entity.LinkImportGenerate, "generate or skip links which short version is taken")
+	if _, err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if *userRef == "" {
+		return ErrUsage
+	}
+	if *onConflict != entity.LinkImportGenerate && *onConflict != entity.LinkImportSkip {
+		return errors.New("on-conflict must be generate or skip")
+	}
+
+	u, err := findUser(ctx, c.users, *userRef)
+	if err != nil {
+		return err
+	}
+
+	rows, issues, err := linkfile.Read(c.in, *format, importMax)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.links.Import(ctx, u.ID, rows, *onConflict)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "imported %d links\n", res.Imported)
+	for _, issue := range res.Conflicts {
+		fmt.Fprintf(c.out, "line %d: %s %s", issue.Line, issue.ShortVersion, issue.Reason)
+		if issue.AssignedShortVersion != "" {
+			fmt.Fprintf(c.out, ", imported as %s", issue.AssignedShortVersion)
+		}
+		fmt.Fprintln(c.out)
+	}
+	for _, issue := range append(issues, res.Errors...) {
+		fmt.Fprintf(c.out, "line %d: %s\n", issue.Line, issue.Reason)
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/slava-911/URL-shortener/internal/interf"
+)
+
+type statsCommand struct {
+	stats interf.StatsService
+	out   io.Writer
+}
+
+func NewStatsCommand(ss interf.StatsService, out io.Writer) Command {
+	return &statsCommand{
+		stats: ss,
+		out:   out,
+	}
+}
+
+func (c *statsCommand) Run(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		fmt.Fprintln(c.out, "usage: app stats")
+		return ErrUsage
+	}
+
+	s, err := c.stats.Get(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "users\t%d\n", s.Users)
+	fmt.Fprintf(w, "disabled users\t%d\n", s.DisabledUsers)
+	fmt.Fprintf(w, "deleted users\t%d\n", s.DeletedUsers)
+	fmt.Fprintf(w, "admins\t%d\n", s.Admins)
+	fmt.Fprintf(w, "links\t%d\n", s.Links)
+	fmt.Fprintf(w, "links created last day\t%d\n", s.LinksLastDay)
+	fmt.Fprintf(w, "inactive links\t%d\n", s.InactiveLinks)
+	fmt.Fprintf(w, "clicks\t%d\n", s.Clicks)
+	fmt.Fprintf(w, "open reports\t%d\n", s.OpenReports)
+	return w.Flush()
+}
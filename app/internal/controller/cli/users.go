@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/password"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+type usersCommand struct {
+	users    interf.UserService
+	validate *validator.Validate
+	in       io.Reader
+	out      io.Writer
+}
+
+// NewUsersCommand creates users commands, passwords which are not given in flags are read from in
+func NewUsersCommand(us interf.UserService, v *validator.Validate, in io.Reader, out io.Writer) Command {
+	return &usersCommand{
+		users:    us,
+		validate: v,
+		in:       in,
+		out:      out,
+	}
+}
+
+func (c *usersCommand) Run(ctx context.Context, args []string) error {
+	return dispatch(ctx, c.out, "users", map[string]subcommand{
+		"create":         {usage: "-email EMAIL -name NAME [-password PASSWORD] [-role user|admin]", run: c.create},
+		"disable":        {usage: "EMAIL|ID", run: c.setDisabled(true)},
+		"enable":         {usage: "EMAIL|ID", run: c.setDisabled(false)},
+		"reset-password": {usage: "[-password PASSWORD] EMAIL|ID", run: c.resetPassword},
+	}, args)
+}
+
+func (c *usersCommand) create(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	email := fs.String("email", "", "email of the user")
+	name := fs.String("name", "", "name of the user")
+	pass := fs.String("password", "", "password, read from stdin if empty")
+	role := fs.String("role", entity.RoleUser, "user or admin")
+	if _, err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if *email == "" || *name == "" {
+		return ErrUsage
+	}
+	if err := c.validate.Var(*email, "required,email"); err != nil {
+		return errors.New(utils.TranslateValidationError(err, "Email"))
+	}
+	if err := c.validate.Var(*name, "required,min=2,max=50"); err != nil {
+		return errors.New(utils.TranslateValidationError(err, "Name"))
+	}
+	if *role != entity.RoleUser && *role != entity.RoleAdmin {
+		return fmt.Errorf("unknown role %q", *role)
+	}
+
+	p, err := c.password(ctx, *pass, *name, *email)
+	if err != nil {
+		return err
+	}
+
+	u, err := c.users.Create(ctx, entity.User{Name: *name, Email: *email, Password: p, Role: *role})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.out, u.ID)
+	return nil
+}
+
+func (c *usersCommand) setDisabled(disabled bool) func(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	return func(ctx context.Context, fs *flag.FlagSet, args []string) error {
+		pos, err := parse(fs, args, 1)
+		if err != nil {
+			return err
+		}
+
+		u, err := findUser(ctx, c.users, pos[0])
+		if err != nil {
+			return err
+		}
+
+		return c.users.SetDisabled(ctx, u.ID, disabled)
+	}
+}
+
+// resetPassword sets new password of the user, sessions of the user are revoked
+func (c *usersCommand) resetPassword(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	pass := fs.String("password", "", "new password, read from stdin if empty")
+	pos, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	u, err := findUser(ctx, c.users, pos[0])
+	if err != nil {
+		return err
+	}
+
+	p, err := c.password(ctx, *pass, u.Name, u.Email)
+	if err != nil {
+		return err
+	}
+
+	return c.users.SetPassword(ctx, u.ID, p)
+}
+
+// password takes the flag value or the first line of input and checks it against the password policy
+func (c *usersCommand) password(ctx context.Context, p string, personal ...string) (string, error) {
+	if p == "" {
+		line, err := bufio.NewReader(c.in).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("failed to read password, error: %w", err)
+		}
+		p = strings.TrimRight(line, "\r\n")
+	}
+
+	ctx = password.WithPersonalInfo(ctx, personal...)
+	if err := c.validate.VarCtx(ctx, p, "required,password"); err != nil {
+		return "", errors.New(utils.TranslateValidationError(err, "Password"))
+	}
+
+	return p, nil
+}
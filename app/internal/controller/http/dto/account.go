@@ -0,0 +1,11 @@
+package dto
+
+type PasswordResetDTO struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type PasswordResetConfirmDTO struct {
+	Token          string `json:"token" validate:"required"`
+	Password       string `json:"password" validate:"required,password"`
+	RepeatPassword string `json:"repeat_password" validate:"required"`
+}
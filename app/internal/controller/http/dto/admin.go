@@ -0,0 +1,10 @@
+package dto
+
+type AdminUpdateUserDTO struct {
+	Disabled *bool   `json:"disabled"`
+	Role     *string `json:"role" validate:"omitempty,oneof=user admin"`
+}
+
+type AdminUpdateLinkDTO struct {
+	Status string `json:"status" validate:"required,oneof=active disabled taken_down"`
+}
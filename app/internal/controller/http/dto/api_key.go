@@ -0,0 +1,36 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+)
+
+type CreateAPIKeyDTO struct {
+	Name      string     `json:"name" validate:"required,min=1,max=100"`
+	Scopes    []string   `json:"scopes,omitempty" validate:"dive,oneof=links:read links:write profile:read profile:write"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	UserID    string     `json:"user_id" validate:"required"`
+}
+
+func NewAPIKey(d CreateAPIKeyDTO) entity.APIKey {
+	k := entity.APIKey{
+		UserID: d.UserID,
+		Name:   d.Name,
+		Scopes: d.Scopes,
+	}
+	if k.Scopes == nil {
+		k.Scopes = []string{}
+	}
+	if d.ExpiresAt != nil {
+		expiresAt := d.ExpiresAt.UTC()
+		k.ExpiresAt = &expiresAt
+	}
+	return k
+}
+
+// CreatedAPIKeyDTO is returned once after key creation, raw key can't be retrieved later
+type CreatedAPIKeyDTO struct {
+	entity.APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,10 @@
+package dto
+
+type CreateLinkReportDTO struct {
+	Reason  string `json:"reason" validate:"required,oneof=spam phishing malware copyright other"`
+	Details string `json:"details,omitempty" validate:"max=2000"`
+}
+
+type ResolveLinkReportDTO struct {
+	Resolution string `json:"resolution" validate:"required,oneof=dismissed disabled taken_down"`
+}
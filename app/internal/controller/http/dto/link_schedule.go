@@ -0,0 +1,20 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+)
+
+type CreateLinkScheduleDTO struct {
+	FullVersion string    `json:"full_version" validate:"required,min=3,max=2000"`
+	ApplyAt     time.Time `json:"apply_at" validate:"required"`
+}
+
+func NewLinkSchedule(linkID string, d CreateLinkScheduleDTO) entity.LinkSchedule {
+	return entity.LinkSchedule{
+		LinkID:      linkID,
+		FullVersion: d.FullVersion,
+		ApplyAt:     d.ApplyAt,
+	}
+}
@@ -0,0 +1,25 @@
+package dto
+
+type TwoFactorChallengeDTO struct {
+	ChallengeToken    string `json:"challenge_token"`
+	TwoFactorRequired bool   `json:"two_factor_required"`
+}
+
+type TwoFactorLoginDTO struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	// Code is TOTP code from authenticator app or one of recovery codes
+	Code string `json:"code" validate:"required"`
+}
+
+type TwoFactorCodeDTO struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type DisableTwoFactorDTO struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+type RecoveryCodesDTO struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
@@ -11,8 +11,8 @@ type SigninUserDTO struct {
 type CreateUserDTO struct {
 	Name           string `json:"name" validate:"required,min=2,max=50"`
 	Email          string `json:"email" validate:"required,email"`
-	Password       string `json:"password" validate:"required,min=6"`
-	RepeatPassword string `json:"repeat_password" validate:"required,min=6"`
+	Password       string `json:"password" validate:"required,password"`
+	RepeatPassword string `json:"repeat_password" validate:"required"`
 }
 
 func NewUser(d CreateUserDTO) entity.User {
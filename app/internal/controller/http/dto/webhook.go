@@ -0,0 +1,48 @@
+package dto
+
+import "github.com/slava-911/URL-shortener/internal/domain/entity"
+
+type CreateWebhookDTO struct {
+	URL         string   `json:"url" validate:"required,url,max=2000"`
+	Events      []string `json:"events" validate:"required,min=1,dive,oneof=link.created link.updated link.deleted link.clicked"`
+	Description string   `json:"description,omitempty" validate:"max=200"`
+	UserID      string   `json:"user_id" validate:"required"`
+}
+
+func NewWebhook(d CreateWebhookDTO) entity.Webhook {
+	return entity.Webhook{
+		UserID:      d.UserID,
+		URL:         d.URL,
+		Events:      d.Events,
+		Description: d.Description,
+	}
+}
+
+// CreatedWebhookDTO is returned once after webhook creation, secret can't be retrieved later
+type CreatedWebhookDTO struct {
+	entity.Webhook
+	Secret string `json:"secret"`
+}
+
+// UpdateWebhookDTO changes only passed fields, events replace the subscribed ones
+type UpdateWebhookDTO struct {
+	URL         *string  `json:"url,omitempty" validate:"omitempty,url,max=2000"`
+	Events      []string `json:"events,omitempty" validate:"omitempty,min=1,dive,oneof=link.created link.updated link.deleted link.clicked"`
+	Description *string  `json:"description,omitempty" validate:"omitempty,max=200"`
+	Active      *bool    `json:"active,omitempty"`
+}
+
+func (d UpdateWebhookDTO) Apply(w *entity.Webhook) {
+	if d.URL != nil {
+		w.URL = *d.URL
+	}
+	if d.Events != nil {
+		w.Events = d.Events
+	}
+	if d.Description != nil {
+		w.Description = *d.Description
+	}
+	if d.Active != nil {
+		w.Active = *d.Active
+	}
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/julienschmidt/httprouter"
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	httpdto "github.com/slava-911/URL-shortener/internal/controller/http/dto"
+	"github.com/slava-911/URL-shortener/internal/controller/http/middleware"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/internal/jwt"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+const (
+	verifyURL               = "/auth/verify"
+	passwordResetURL        = "/auth/password-reset"
+	passwordResetConfirmURL = "/auth/password-reset/confirm"
+)
+
+type accountHandler struct {
+	auth           jwt.Middleware
+	limiter        middleware.RateLimiter
+	accountService interf.AccountService
+	userService    interf.UserService
+	validate       *validator.Validate
+	logger         *logging.Logger
+}
+
+func NewAccountHandler(a jwt.Middleware, rl middleware.RateLimiter, as interf.AccountService, us interf.UserService,
+	v *validator.Validate, l *logging.Logger) interf.Handler {
+	return &accountHandler{
+		auth:           a,
+		limiter:        rl,
+		accountService: as,
+		userService:    us,
+		validate:       v,
+		logger:         l,
+	}
+}
+
+func (h *accountHandler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, verifyURL, h.limiter.Handle(middleware.PolicyAuth, apperror.Middleware(h.VerifyEmail)))
+	router.HandlerFunc(http.MethodPost, verifyURL, h.auth.Handle(h.limiter.Handle(middleware.PolicyAuth, apperror.Middleware(h.ResendVerification)), entity.ScopeSession))
+	router.HandlerFunc(http.MethodPost, passwordResetURL, h.limiter.Handle(middleware.PolicyAuth, apperror.Middleware(h.RequestPasswordReset)))
+	router.HandlerFunc(http.MethodPost, passwordResetConfirmURL, h.limiter.Handle(middleware.PolicyAuth, apperror.Middleware(h.ResetPassword)))
+}
+
+func (h *accountHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("VERIFY EMAIL")
+	w.Header().Set("Content-Type", "application/json")
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		return apperror.BadRequestError("token query parameter is required")
+	}
+
+	if err := h.accountService.VerifyEmail(r.Context(), token); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *accountHandler) ResendVerification(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("RESEND VERIFICATION")
+	w.Header().Set("Content-Type", "application/json")
+
+	vUserID := r.Context().Value("user_id")
+	if vUserID == nil {
+		h.logger.Error("there is no user_id in context")
+		return apperror.ErrUnauthorized
+	}
+	userID := vUserID.(string)
+
+	user, err := h.userService.GetOneByID(r.Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	if err = h.accountService.SendVerification(r.Context(), user); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+
+	return nil
+}
+
+func (h *accountHandler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("REQUEST PASSWORD RESET")
+	w.Header().Set("Content-Type", "application/json")
+
+	defer r.Body.Close()
+	var resetDTO httpdto.PasswordResetDTO
+	if err := json.NewDecoder(r.Body).Decode(&resetDTO); err != nil {
+		return apperror.BadRequestError("failed to decode data")
+	}
+
+	if err := h.validate.Struct(resetDTO); err != nil {
+		return apperror.BadRequestError(utils.TranslateValidationError(err, ""))
+	}
+
+	if err := h.accountService.RequestPasswordReset(r.Context(), resetDTO.Email); err != nil {
+		return err
+	}
+
+	// the same response for known and unknown emails
+	w.WriteHeader(http.StatusAccepted)
+
+	return nil
+}
+
+func (h *accountHandler) ResetPassword(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("RESET PASSWORD")
+	w.Header().Set("Content-Type", "application/json")
+
+	defer r.Body.Close()
+	var confirmDTO httpdto.PasswordResetConfirmDTO
+	if err := json.NewDecoder(r.Body).Decode(&confirmDTO); err != nil {
+		return apperror.BadRequestError("failed to decode data")
+	}
+
+	if err := h.validate.Struct(confirmDTO); err != nil {
+		return apperror.BadRequestError(utils.TranslateValidationError(err, ""))
+	}
+
+	h.logger.Debug("check password and repeat password")
+	if confirmDTO.Password != confirmDTO.RepeatPassword {
+		return apperror.BadRequestError("password does not match repeat password")
+	}
+
+	if err := h.accountService.ResetPassword(r.Context(), confirmDTO.Token, confirmDTO.Password); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
@@ -0,0 +1,358 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/julienschmidt/httprouter"
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	httpdto "github.com/slava-911/URL-shortener/internal/controller/http/dto"
+	"github.com/slava-911/URL-shortener/internal/controller/http/middleware"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/internal/jwt"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+const (
+	adminUsersURL   = "/admin/users"
+	adminUserURL    = "/admin/users/:id"
+	adminLinksURL   = "/admin/links"
+	adminLinkURL    = "/admin/links/:id"
+	adminStatsURL   = "/admin/stats"
+	adminReportsURL = "/admin/reports"
+	adminReportURL  = "/admin/reports/:id"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+type adminHandler struct {
+	auth          jwt.Middleware
+	roles         middleware.RoleGuard
+	userService   interf.UserService
+	linkService   interf.LinkService
+	reportService interf.LinkReportService
+	statsService  interf.StatsService
+	validate      *validator.Validate
+	logger        *logging.Logger
+}
+
+func NewAdminHandler(a jwt.Middleware, rg middleware.RoleGuard, us interf.UserService, ls interf.LinkService,
+	rs interf.LinkReportService, ss interf.StatsService, v *validator.Validate, l *logging.Logger) interf.Handler {
+	return &adminHandler{
+		auth:          a,
+		roles:         rg,
+		userService:   us,
+		linkService:   ls,
+		reportService: rs,
+		statsService:  ss,
+		validate:      v,
+		logger:        l,
+	}
+}
+
+func (h *adminHandler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, adminUsersURL, h.admin(h.GetUsers))
+	router.HandlerFunc(http.MethodGet, adminUserURL, h.admin(h.GetUser))
+	router.HandlerFunc(http.MethodPatch, adminUserURL, h.admin(h.PartiallyUpdateUser))
+	router.HandlerFunc(http.MethodGet, adminLinksURL, h.admin(h.GetLinks))
+	router.HandlerFunc(http.MethodGet, adminLinkURL, h.admin(h.GetLink))
+	router.HandlerFunc(http.MethodPatch, adminLinkURL, h.admin(h.SetLinkStatus))
+	router.HandlerFunc(http.MethodDelete, adminLinkURL, h.admin(h.TakedownLink))
+	router.HandlerFunc(http.MethodGet, adminReportsURL, h.admin(h.GetReports))
+	router.HandlerFunc(http.MethodPatch, adminReportURL, h.admin(h.ResolveReport))
+	router.HandlerFunc(http.MethodGet, adminStatsURL, h.admin(h.GetStats))
+}
+
+// admin lets only admins with session tokens through, api keys have no access to admin api
+func (h *adminHandler) admin(next func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
+	return h.auth.Handle(h.roles.Handle(entity.RoleAdmin, apperror.Middleware(next)), entity.ScopeSession)
+}
+
+func (h *adminHandler) GetUsers(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("ADMIN GET USERS")
+	w.Header().Set("Content-Type", "application/json")
+
+	limit, offset, err := pagination(r)
+	if err != nil {
+		return err
+	}
+
+	users, err := h.userService.GetAll(r.Context(), entity.UserFilter{
+		Query:  r.URL.Query().Get("q"),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		return err
+	}
+
+	usersBytes, err := json.Marshal(users)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(usersBytes)
+
+	return nil
+}
+
+func (h *adminHandler) GetUser(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("ADMIN GET USER")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	user, err := h.userService.GetOneByID(r.Context(), params.ByName("id"))
+	if err != nil {
+		return err
+	}
+
+	userBytes, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(userBytes)
+
+	return nil
+}
+
+func (h *adminHandler) PartiallyUpdateUser(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("ADMIN PARTIALLY UPDATE USER")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	userID := params.ByName("id")
+
+	h.logger.Debug("decode admin update user dto")
+	var userDTO httpdto.AdminUpdateUserDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&userDTO); err != nil {
+		return apperror.BadRequestError("failed to decode data")
+	}
+
+	if err := h.validate.Struct(userDTO); err != nil {
+		return apperror.BadRequestError(utils.TranslateValidationError(err, ""))
+	}
+	if userDTO.Disabled == nil && userDTO.Role == nil {
+		return apperror.BadRequestError("Nothing to update")
+	}
+
+	// admins must not lock out their own account, another admin can do it
+	if userID == r.Context().Value("user_id").(string) {
+		return apperror.BadRequestError("admin can not disable or change role of own account")
+	}
+
+	if userDTO.Role != nil {
+		if err := h.userService.SetRole(r.Context(), userID, *userDTO.Role); err != nil {
+			return err
+		}
+	}
+	if userDTO.Disabled != nil {
+		if err := h.userService.SetDisabled(r.Context(), userID, *userDTO.Disabled); err != nil {
+			return err
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *adminHandler) GetLinks(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("ADMIN GET LINKS")
+	w.Header().Set("Content-Type", "application/json")
+
+	limit, offset, err := pagination(r)
+	if err != nil {
+		return err
+	}
+
+	links, err := h.linkService.GetAll(r.Context(), entity.LinkFilter{
+		Query:  r.URL.Query().Get("q"),
+		UserID: r.URL.Query().Get("user_id"),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		return err
+	}
+
+	linksBytes, err := json.Marshal(links)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(linksBytes)
+
+	return nil
+}
+
+func (h *adminHandler) GetLink(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("ADMIN GET LINK")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	link, err := h.linkService.GetOneByID(r.Context(), params.ByName("id"))
+	if err != nil {
+		return err
+	}
+
+	linkBytes, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(linkBytes)
+
+	return nil
+}
+
+func (h *adminHandler) SetLinkStatus(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("ADMIN SET LINK STATUS")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	linkID := params.ByName("id")
+
+	h.logger.Debug("decode admin update link dto")
+	var linkDTO httpdto.AdminUpdateLinkDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&linkDTO); err != nil {
+		return apperror.BadRequestError("failed to decode data")
+	}
+
+	if err := h.validate.Struct(linkDTO); err != nil {
+		return apperror.BadRequestError(utils.TranslateValidationError(err, ""))
+	}
+
+	if err := h.linkService.SetStatus(r.Context(), linkID, linkDTO.Status); err != nil {
+		return err
+	}
+	h.logger.Warnf("status of link %s is set to %s", linkID, linkDTO.Status)
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// TakedownLink keeps the link, so that its short version is not reused, and stops redirecting it
+func (h *adminHandler) TakedownLink(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("ADMIN TAKEDOWN LINK")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	linkID := params.ByName("id")
+
+	if err := h.linkService.SetStatus(r.Context(), linkID, entity.LinkStatusTakenDown); err != nil {
+		return err
+	}
+	h.logger.Warnf("link %s is taken down", linkID)
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *adminHandler) GetReports(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("ADMIN GET REPORTS")
+	w.Header().Set("Content-Type", "application/json")
+
+	limit, offset, err := pagination(r)
+	if err != nil {
+		return err
+	}
+
+	reports, err := h.reportService.GetAll(r.Context(), entity.LinkReportFilter{
+		Resolved: r.URL.Query().Get("resolved") == "true",
+		LinkID:   r.URL.Query().Get("link_id"),
+		Limit:    limit,
+		Offset:   offset,
+	})
+	if err != nil {
+		return err
+	}
+
+	reportsBytes, err := json.Marshal(reports)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(reportsBytes)
+
+	return nil
+}
+
+func (h *adminHandler) ResolveReport(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("ADMIN RESOLVE REPORT")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	reportID := params.ByName("id")
+
+	h.logger.Debug("decode resolve link report dto")
+	var reportDTO httpdto.ResolveLinkReportDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&reportDTO); err != nil {
+		return apperror.BadRequestError("failed to decode data")
+	}
+
+	if err := h.validate.Struct(reportDTO); err != nil {
+		return apperror.BadRequestError(utils.TranslateValidationError(err, ""))
+	}
+
+	if err := h.reportService.Resolve(r.Context(), reportID, reportDTO.Resolution); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *adminHandler) GetStats(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("ADMIN GET STATS")
+	w.Header().Set("Content-Type", "application/json")
+
+	stats, err := h.statsService.Get(r.Context())
+	if err != nil {
+		return err
+	}
+
+	statsBytes, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(statsBytes)
+
+	return nil
+}
+
+// pagination reads limit and offset query parameters
+func pagination(r *http.Request) (limit, offset int, err error) {
+	limit, offset = defaultPageLimit, 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxPageLimit {
+			return 0, 0, apperror.BadRequestError("limit must be a number from 1 to " + strconv.Itoa(maxPageLimit))
+		}
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return 0, 0, apperror.BadRequestError("offset must be a non-negative number")
+		}
+	}
+	return limit, offset, nil
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/julienschmidt/httprouter"
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	httpdto "github.com/slava-911/URL-shortener/internal/controller/http/dto"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/internal/jwt"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+const (
+	apiKeysURL = "/api-keys"
+	apiKeyURL  = "/api-keys/:id"
+)
+
+type apiKeyHandler struct {
+	auth          jwt.Middleware
+	apiKeyService interf.APIKeyService
+	validate      *validator.Validate
+	logger        *logging.Logger
+}
+
+func NewAPIKeyHandler(a jwt.Middleware, ks interf.APIKeyService, v *validator.Validate, l *logging.Logger) interf.Handler {
+	return &apiKeyHandler{
+		auth:          a,
+		apiKeyService: ks,
+		validate:      v,
+		logger:        l,
+	}
+}
+
+func (h *apiKeyHandler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodPost, apiKeysURL, h.auth.Handle(apperror.Middleware(h.CreateAPIKey), entity.ScopeSession))
+	router.HandlerFunc(http.MethodGet, apiKeysURL, h.auth.Handle(apperror.Middleware(h.GetUserAPIKeys), entity.ScopeSession))
+	router.HandlerFunc(http.MethodDelete, apiKeyURL, h.auth.Handle(apperror.Middleware(h.RevokeAPIKey), entity.ScopeSession))
+}
+
+func (h *apiKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("CREATE API KEY")
+	w.Header().Set("Content-Type", "application/json")
+
+	vUserID := r.Context().Value("user_id")
+	if vUserID == nil {
+		h.logger.Error("there is no user_id in context")
+		return apperror.ErrUnauthorized
+	}
+	userID := vUserID.(string)
+
+	h.logger.Debug("decode create api key dto")
+	var keyDTO httpdto.CreateAPIKeyDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&keyDTO); err != nil {
+		return apperror.BadRequestError("invalid data")
+	}
+	keyDTO.UserID = userID
+
+	h.logger.Debugf("Validation for api key: %s", keyDTO.Name)
+	if err := h.validate.Struct(keyDTO); err != nil {
+		return apperror.BadRequestError(utils.TranslateValidationError(err, ""))
+	}
+	if keyDTO.ExpiresAt != nil && !keyDTO.ExpiresAt.After(time.Now()) {
+		return apperror.BadRequestError("expires_at must be in the future")
+	}
+
+	rawKey, key, err := h.apiKeyService.Create(r.Context(), httpdto.NewAPIKey(keyDTO))
+	if err != nil {
+		return err
+	}
+
+	keyBytes, err := json.Marshal(httpdto.CreatedAPIKeyDTO{APIKey: key, Key: rawKey})
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/%s", apiKeysURL, key.ID))
+	w.WriteHeader(http.StatusCreated)
+	w.Write(keyBytes)
+
+	return nil
+}
+
+func (h *apiKeyHandler) GetUserAPIKeys(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("GET USER API KEYS")
+	w.Header().Set("Content-Type", "application/json")
+
+	vUserID := r.Context().Value("user_id")
+	if vUserID == nil {
+		h.logger.Error("there is no user_id in context")
+		return apperror.ErrUnauthorized
+	}
+	userID := vUserID.(string)
+
+	keys, err := h.apiKeyService.GetAllByUserID(r.Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	keysBytes, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(keysBytes)
+
+	return nil
+}
+
+func (h *apiKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("REVOKE API KEY")
+	w.Header().Set("Content-Type", "application/json")
+
+	vUserID := r.Context().Value("user_id")
+	if vUserID == nil {
+		h.logger.Error("there is no user_id in context")
+		return apperror.ErrUnauthorized
+	}
+	userID := vUserID.(string)
+
+	h.logger.Debug("get id from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	keyID := params.ByName("id")
+	if keyID == "" {
+		return apperror.BadRequestError("id query parameter is required")
+	}
+
+	if err := h.apiKeyService.Revoke(r.Context(), keyID, userID); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
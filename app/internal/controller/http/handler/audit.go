@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/internal/jwt"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+)
+
+const auditURL = "/audit"
+
+type auditHandler struct {
+	auth         jwt.Middleware
+	auditService interf.AuditService
+	userService  interf.UserService
+	linkService  interf.LinkService
+	logger       *logging.Logger
+}
+
+func NewAuditHandler(a jwt.Middleware, as interf.AuditService, us interf.UserService, ls interf.LinkService,
+	l *logging.Logger) interf.Handler {
+	return &auditHandler{
+		auth:         a,
+		auditService: as,
+		userService:  us,
+		linkService:  ls,
+		logger:       l,
+	}
+}
+
+func (h *auditHandler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, auditURL, h.auth.Handle(apperror.Middleware(h.GetEvents), entity.ScopeSession))
+}
+
+// GetEvents returns events of any user and link to admins, other users see only their own account and links
+func (h *auditHandler) GetEvents(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("GET AUDIT EVENTS")
+	w.Header().Set("Content-Type", "application/json")
+
+	vUserID := r.Context().Value("user_id")
+	if vUserID == nil {
+		h.logger.Error("there is no user_id in context")
+		return apperror.ErrUnauthorized
+	}
+	userID := vUserID.(string)
+
+	limit, offset, err := pagination(r)
+	if err != nil {
+		return err
+	}
+
+	query := r.URL.Query()
+	filter := entity.AuditFilter{
+		UserID: query.Get("user_id"),
+		LinkID: query.Get("link_id"),
+		Limit:  limit,
+		Offset: offset,
+	}
+	if filter.From, err = parseTimeParam(query.Get("from"), "from"); err != nil {
+		return err
+	}
+	if filter.To, err = parseTimeParam(query.Get("to"), "to"); err != nil {
+		return err
+	}
+
+	user, err := h.userService.GetOneByID(r.Context(), userID)
+	if err != nil {
+		return err
+	}
+	if !user.IsAdmin() {
+		if filter.LinkID != "" {
+			link, err := h.linkService.GetOneByID(r.Context(), filter.LinkID)
+			if err != nil {
+				return err
+			}
+			if link.UserID != userID {
+				return apperror.ErrNotFound
+			}
+		} else if filter.UserID != "" && filter.UserID != userID {
+			return apperror.ErrForbidden
+		} else {
+			filter.UserID = userID
+		}
+	}
+
+	events, err := h.auditService.GetAll(r.Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	eventsBytes, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(eventsBytes)
+
+	return nil
+}
+
+// parseTimeParam reads optional RFC 3339 time query parameter
+func parseTimeParam(value, name string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, apperror.BadRequestError(name + " must be a time in RFC 3339 format, ex: 2023-01-02T15:04:05Z")
+	}
+	t = t.UTC()
+	return &t, nil
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/internal/jwt"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+)
+
+const jwksURL = "/.well-known/jwks.json"
+
+type jwksHandler struct {
+	keys   jwt.KeySet
+	logger *logging.Logger
+}
+
+func NewJWKSHandler(k jwt.KeySet, l *logging.Logger) interf.Handler {
+	return &jwksHandler{
+		keys:   k,
+		logger: l,
+	}
+}
+
+func (h *jwksHandler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, jwksURL, apperror.Middleware(h.GetJWKS))
+}
+
+func (h *jwksHandler) GetJWKS(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Debug("GET JWKS")
+	w.Header().Set("Content-Type", "application/json")
+
+	jwksBytes, err := json.Marshal(h.keys.JWKS())
+	if err != nil {
+		return err
+	}
+
+	// verifiers are expected to refetch the set when they meet a token with unknown kid after rotation
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jwksBytes)
+
+	return nil
+}
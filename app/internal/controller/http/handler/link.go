@@ -2,46 +2,103 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html/template"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/julienschmidt/httprouter"
+	"github.com/slava-911/URL-shortener/internal/adapter/linkfile"
 	"github.com/slava-911/URL-shortener/internal/apperror"
 	httpdto "github.com/slava-911/URL-shortener/internal/controller/http/dto"
+	"github.com/slava-911/URL-shortener/internal/controller/http/middleware"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
 	"github.com/slava-911/URL-shortener/internal/interf"
 	"github.com/slava-911/URL-shortener/internal/jwt"
 	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/metric"
 	"github.com/slava-911/URL-shortener/pkg/utils"
 )
 
 const (
 	linksURL     = "/links"
 	linkURL      = "/links/:id"
+	restoreURL   = "/links/:id/restore"
+	historyURL   = "/links/:id/history"
+	rollbackURL  = "/links/:id/history/:version/restore"
+	schedulesURL = "/links/:id/schedules"
+	scheduleURL  = "/links/:id/schedules/:schedule_id"
 	shortLinkURL = "/s/:short_version"
+	reportURL    = "/s/:short_version/report"
 )
 
+// trashID, exportID and importID are served by linkURL route, httprouter does not allow static segment next to :id
+const (
+	trashID  = "trash"
+	exportID = "export"
+	importID = "import"
+)
+
+// importMaxBytes and importMaxLinks limit the size of imported file
+const (
+	importMaxBytes = 10 << 20
+	importMaxLinks = 10000
+)
+
+// unavailablePage is shown to visitors of inactive links instead of redirect
+var unavailablePage = template.Must(template.New("unavailable").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+</body>
+</html>
+`))
+
 type linkHandler struct {
-	linkService interf.LinkService
-	validate    *validator.Validate
-	logger      *logging.Logger
+	auth          jwt.Middleware
+	limiter       middleware.RateLimiter
+	linkService   interf.LinkService
+	reportService interf.LinkReportService
+	redirects     interf.RedirectRecorder
+	validate      *validator.Validate
+	logger        *logging.Logger
 }
 
-func NewLinkHandler(ls interf.LinkService, v *validator.Validate, l *logging.Logger) interf.Handler {
+func NewLinkHandler(a jwt.Middleware, rl middleware.RateLimiter, ls interf.LinkService, rs interf.LinkReportService,
+	rr interf.RedirectRecorder, v *validator.Validate, l *logging.Logger) interf.Handler {
 	return &linkHandler{
-		linkService: ls,
-		validate:    v,
-		logger:      l,
+		auth:          a,
+		limiter:       rl,
+		linkService:   ls,
+		reportService: rs,
+		redirects:     rr,
+		validate:      v,
+		logger:        l,
 	}
 }
 
 func (h *linkHandler) Register(router *httprouter.Router) {
-	router.HandlerFunc(http.MethodPost, linksURL, jwt.Middleware(apperror.Middleware(h.CreateLink), h.logger))
-	router.HandlerFunc(http.MethodGet, linksURL, jwt.Middleware(apperror.Middleware(h.GetUserLinks), h.logger))
-	router.HandlerFunc(http.MethodGet, linkURL, jwt.Middleware(apperror.Middleware(h.GetLink), h.logger))
-	router.HandlerFunc(http.MethodPatch, linkURL, jwt.Middleware(apperror.Middleware(h.PartiallyUpdateLink), h.logger))
-	router.HandlerFunc(http.MethodDelete, linkURL, jwt.Middleware(apperror.Middleware(h.DeleteLink), h.logger))
-	router.HandlerFunc(http.MethodGet, shortLinkURL, apperror.Middleware(h.ClickOnLink))
+	router.HandlerFunc(http.MethodPost, linksURL, h.auth.Handle(
+		h.limiter.Handle(middleware.PolicyLinksCreate, apperror.Middleware(h.CreateLink)), entity.ScopeLinksWrite))
+	router.HandlerFunc(http.MethodGet, linksURL, h.auth.Handle(apperror.Middleware(h.GetUserLinks), entity.ScopeLinksRead))
+	router.HandlerFunc(http.MethodGet, linkURL, h.auth.Handle(apperror.Middleware(h.GetLink), entity.ScopeLinksRead))
+	router.HandlerFunc(http.MethodPost, linkURL, h.auth.Handle(
+		h.limiter.Handle(middleware.PolicyLinksImport, apperror.Middleware(h.ImportLinks)), entity.ScopeLinksWrite))
+	router.HandlerFunc(http.MethodPatch, linkURL, h.auth.Handle(apperror.Middleware(h.PartiallyUpdateLink), entity.ScopeLinksWrite))
+	router.HandlerFunc(http.MethodDelete, linkURL, h.auth.Handle(apperror.Middleware(h.DeleteLink), entity.ScopeLinksWrite))
+	router.HandlerFunc(http.MethodPost, restoreURL, h.auth.Handle(apperror.Middleware(h.RestoreLink), entity.ScopeLinksWrite))
+	router.HandlerFunc(http.MethodGet, historyURL, h.auth.Handle(apperror.Middleware(h.GetLinkHistory), entity.ScopeLinksRead))
+	router.HandlerFunc(http.MethodPost, rollbackURL, h.auth.Handle(apperror.Middleware(h.RollbackLink), entity.ScopeLinksWrite))
+	router.HandlerFunc(http.MethodPost, schedulesURL, h.auth.Handle(apperror.Middleware(h.CreateLinkSchedule), entity.ScopeLinksWrite))
+	router.HandlerFunc(http.MethodGet, schedulesURL, h.auth.Handle(apperror.Middleware(h.GetLinkSchedules), entity.ScopeLinksRead))
+	router.HandlerFunc(http.MethodDelete, scheduleURL, h.auth.Handle(apperror.Middleware(h.DeleteLinkSchedule), entity.ScopeLinksWrite))
+	router.HandlerFunc(http.MethodGet, shortLinkURL, h.limiter.Handle(middleware.PolicyRedirect, apperror.Middleware(h.ClickOnLink)))
+	router.HandlerFunc(http.MethodPost, reportURL, h.limiter.Handle(middleware.PolicyReport, apperror.Middleware(h.ReportLink)))
 }
 
 func (h *linkHandler) CreateLink(w http.ResponseWriter, r *http.Request) error {
@@ -124,6 +181,12 @@ func (h *linkHandler) GetLink(w http.ResponseWriter, r *http.Request) error {
 	if linkID == "" {
 		return apperror.BadRequestError("id query parameter is required")
 	}
+	if linkID == trashID {
+		return h.GetTrash(w, r)
+	}
+	if linkID == exportID {
+		return h.ExportLinks(w, r)
+	}
 
 	link, err := h.linkService.GetOneByID(r.Context(), linkID)
 	if err != nil {
@@ -222,6 +285,332 @@ func (h *linkHandler) DeleteLink(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+func (h *linkHandler) GetTrash(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("GET LINKS TRASH")
+	w.Header().Set("Content-Type", "application/json")
+
+	vUserID := r.Context().Value("user_id")
+	if vUserID == nil {
+		h.logger.Error("there is no user_id in context")
+		return apperror.ErrUnauthorized
+	}
+	userID := vUserID.(string)
+
+	links, err := h.linkService.GetTrashByUserID(r.Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	linksBytes, err := json.Marshal(links)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(linksBytes)
+
+	return nil
+}
+
+// ExportLinks streams all links of the user as csv or json lines file
+func (h *linkHandler) ExportLinks(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("EXPORT LINKS")
+
+	vUserID := r.Context().Value("user_id")
+	if vUserID == nil {
+		h.logger.Error("there is no user_id in context")
+		return apperror.ErrUnauthorized
+	}
+	userID := vUserID.(string)
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = linkfile.FormatCSV
+	}
+	if format != linkfile.FormatCSV && format != linkfile.FormatJSONL {
+		w.Header().Set("Content-Type", "application/json")
+		return apperror.BadRequestError("format must be csv or jsonl")
+	}
+
+	w.Header().Set("Content-Type", linkfile.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="links.%s"`, format))
+	lw, err := linkfile.NewWriter(w, format)
+	if err != nil {
+		return err
+	}
+
+	// the status is sent with the first bytes, later errors can only cut the file short
+	err = h.linkService.Export(r.Context(), entity.LinkFilter{UserID: userID}, lw.Write)
+	if err == nil {
+		err = lw.Flush()
+	}
+	if err != nil {
+		h.logger.Errorf("failed to export links due to error %v", err)
+	}
+
+	return nil
+}
+
+// ImportLinks creates links of the user from csv, json lines or Bitly csv file sent as request body
+func (h *linkHandler) ImportLinks(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("IMPORT LINKS")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	if params.ByName("id") != importID {
+		return apperror.ErrNotFound
+	}
+
+	vUserID := r.Context().Value("user_id")
+	if vUserID == nil {
+		h.logger.Error("there is no user_id in context")
+		return apperror.ErrUnauthorized
+	}
+	userID := vUserID.(string)
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = linkfile.FormatCSV
+		if strings.HasPrefix(r.Header.Get("Content-Type"), linkfile.ContentType(linkfile.FormatJSONL)) {
+			format = linkfile.FormatJSONL
+		}
+	}
+	if format != linkfile.FormatCSV && format != linkfile.FormatJSONL && format != linkfile.FormatBitly {
+		return apperror.BadRequestError("format must be csv, jsonl or bitly")
+	}
+	onConflict := r.URL.Query().Get("on_conflict")
+	if onConflict == "" {
+		onConflict = entity.LinkImportGenerate
+	}
+	if onConflict != entity.LinkImportGenerate && onConflict != entity.LinkImportSkip {
+		return apperror.BadRequestError("on_conflict must be generate or skip")
+	}
+
+	defer r.Body.Close()
+	rows, issues, err := linkfile.Read(http.MaxBytesReader(w, r.Body, importMaxBytes), format, importMaxLinks)
+	if err != nil {
+		return apperror.BadRequestError(fmt.Sprintf("failed to read file: %v", err))
+	}
+
+	h.logger.Debug("validate imported links")
+	valid := rows[:0]
+	for _, row := range rows {
+		linkDTO := httpdto.CreateLinkDTO{FullVersion: row.Link.FullVersion, Description: row.Link.Description, UserID: userID}
+		if err = h.validate.Struct(linkDTO); err != nil {
+			issues = append(issues, entity.LinkImportIssue{Line: row.Line, ShortVersion: row.Link.ShortVersion,
+				Reason: utils.TranslateValidationError(err, "")})
+			continue
+		}
+		valid = append(valid, row)
+	}
+
+	res, err := h.linkService.Import(r.Context(), userID, valid, onConflict)
+	if err != nil {
+		return err
+	}
+	res.Errors = append(issues, res.Errors...)
+	if res.Conflicts == nil {
+		res.Conflicts = []entity.LinkImportIssue{}
+	}
+	if res.Errors == nil {
+		res.Errors = []entity.LinkImportIssue{}
+	}
+
+	resBytes, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resBytes)
+
+	return nil
+}
+
+func (h *linkHandler) RestoreLink(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("RESTORE LINK")
+	w.Header().Set("Content-Type", "application/json")
+
+	vUserID := r.Context().Value("user_id")
+	if vUserID == nil {
+		h.logger.Error("there is no user_id in context")
+		return apperror.ErrUnauthorized
+	}
+	userID := vUserID.(string)
+
+	h.logger.Debug("get id from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	linkID := params.ByName("id")
+	if linkID == "" {
+		return apperror.BadRequestError("id query parameter is required")
+	}
+
+	if err := h.linkService.Restore(r.Context(), linkID, userID); err != nil {
+		return err
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/%s", linksURL, linkID))
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *linkHandler) GetLinkHistory(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("GET LINK HISTORY")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	linkID := params.ByName("id")
+	if err := h.checkOwner(r, linkID); err != nil {
+		return err
+	}
+
+	versions, err := h.linkService.GetHistory(r.Context(), linkID)
+	if err != nil {
+		return err
+	}
+
+	versionsBytes, err := json.Marshal(versions)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(versionsBytes)
+
+	return nil
+}
+
+func (h *linkHandler) RollbackLink(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("ROLLBACK LINK")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	linkID := params.ByName("id")
+	version, err := strconv.Atoi(params.ByName("version"))
+	if err != nil || version < 1 {
+		return apperror.BadRequestError("version must be a positive number")
+	}
+	if err = h.checkOwner(r, linkID); err != nil {
+		return err
+	}
+
+	if err = h.linkService.RestoreVersion(r.Context(), linkID, version); err != nil {
+		return err
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/%s", linksURL, linkID))
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *linkHandler) CreateLinkSchedule(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("CREATE LINK SCHEDULE")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	linkID := params.ByName("id")
+	if err := h.checkOwner(r, linkID); err != nil {
+		return err
+	}
+
+	h.logger.Debug("decode create link schedule dto")
+	var scheduleDTO httpdto.CreateLinkScheduleDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&scheduleDTO); err != nil {
+		return apperror.BadRequestError("invalid data")
+	}
+
+	if err := h.validate.Struct(scheduleDTO); err != nil {
+		return apperror.BadRequestError(utils.TranslateValidationError(err, ""))
+	}
+	if !httpdto.ValidLink(scheduleDTO.FullVersion) {
+		return apperror.BadRequestError("Need an absolute path link to schedule. Ex: https://p.com/")
+	}
+
+	schedule, err := h.linkService.CreateSchedule(r.Context(), httpdto.NewLinkSchedule(linkID, scheduleDTO))
+	if err != nil {
+		return err
+	}
+
+	scheduleBytes, err := json.Marshal(schedule)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/%s/schedules/%s", linksURL, linkID, schedule.ID))
+	w.WriteHeader(http.StatusCreated)
+	w.Write(scheduleBytes)
+
+	return nil
+}
+
+func (h *linkHandler) GetLinkSchedules(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("GET LINK SCHEDULES")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	linkID := params.ByName("id")
+	if err := h.checkOwner(r, linkID); err != nil {
+		return err
+	}
+
+	schedules, err := h.linkService.GetSchedules(r.Context(), linkID)
+	if err != nil {
+		return err
+	}
+
+	schedulesBytes, err := json.Marshal(schedules)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(schedulesBytes)
+
+	return nil
+}
+
+func (h *linkHandler) DeleteLinkSchedule(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("DELETE LINK SCHEDULE")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	linkID := params.ByName("id")
+	if err := h.checkOwner(r, linkID); err != nil {
+		return err
+	}
+
+	if err := h.linkService.DeleteSchedule(r.Context(), params.ByName("schedule_id"), linkID); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// checkOwner hides links of other users as not found
+func (h *linkHandler) checkOwner(r *http.Request, linkID string) error {
+	vUserID := r.Context().Value("user_id")
+	if vUserID == nil {
+		h.logger.Error("there is no user_id in context")
+		return apperror.ErrUnauthorized
+	}
+
+	link, err := h.linkService.GetOneByID(r.Context(), linkID)
+	if err != nil {
+		return err
+	}
+	if link.UserID != vUserID.(string) {
+		h.logger.Warnf("user %s has no access to link %s", vUserID, linkID)
+		return apperror.ErrNotFound
+	}
+
+	return nil
+}
+
 func (h *linkHandler) ClickOnLink(w http.ResponseWriter, r *http.Request) error {
 	h.logger.Info("CLICK ON THE LINK")
 	w.Header().Set("Content-Type", "application/json")
@@ -233,14 +622,72 @@ func (h *linkHandler) ClickOnLink(w http.ResponseWriter, r *http.Request) error
 		return apperror.BadRequestError("short_version query parameter is required")
 	}
 
-	fullLink, err := h.linkService.GetFullVersionByShortVersion(r.Context(), shortVersion)
+	click := entity.ClickEvent{Referrer: r.Referer(), UserAgent: r.UserAgent()}
+	fullLink, err := h.linkService.GetFullVersionByShortVersion(r.Context(), shortVersion, click)
 	if err != nil {
+		if errors.Is(err, apperror.ErrLinkDisabled) {
+			h.redirects.Redirect(metric.RedirectDisabled)
+			return h.writeUnavailablePage(w, http.StatusGone, "Link is disabled",
+				"This link has been disabled and no longer redirects.")
+		}
+		if errors.Is(err, apperror.ErrLinkTakenDown) {
+			h.redirects.Redirect(metric.RedirectTakenDown)
+			return h.writeUnavailablePage(w, http.StatusUnavailableForLegalReasons, "Link is unavailable",
+				"This link has been taken down after an abuse report.")
+		}
+		if errors.Is(err, apperror.ErrNotFound) {
+			h.redirects.Redirect(metric.RedirectNotFound)
+		} else {
+			h.redirects.Redirect(metric.RedirectError)
+		}
 		return err
 	}
 
+	h.redirects.Redirect(metric.RedirectOK)
 	h.logger.Infof("Redirected from link short version: %s", shortVersion)
 	w.Header().Set("Location", fullLink)
 	http.Redirect(w, r, fullLink, http.StatusTemporaryRedirect)
 
 	return nil
 }
+
+func (h *linkHandler) writeUnavailablePage(w http.ResponseWriter, status int, title, message string) error {
+	h.logger.Infof("link is not redirected, status %d", status)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := unavailablePage.Execute(w, map[string]string{"Title": title, "Message": message}); err != nil {
+		h.logger.Errorf("failed to write unavailable page due to error %v", err)
+	}
+	return nil
+}
+
+func (h *linkHandler) ReportLink(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("REPORT LINK")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	shortVersion := params.ByName("short_version")
+	if shortVersion == "" {
+		return apperror.BadRequestError("short_version query parameter is required")
+	}
+
+	h.logger.Debug("decode create link report dto")
+	var reportDTO httpdto.CreateLinkReportDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&reportDTO); err != nil {
+		return apperror.BadRequestError("failed to decode data")
+	}
+
+	if err := h.validate.Struct(reportDTO); err != nil {
+		return apperror.BadRequestError(utils.TranslateValidationError(err, ""))
+	}
+
+	_, err := h.reportService.Report(r.Context(), shortVersion, reportDTO.Reason, reportDTO.Details, utils.ClientIP(r))
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusCreated)
+
+	return nil
+}
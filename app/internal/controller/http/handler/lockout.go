@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/controller/http/middleware"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/internal/jwt"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+)
+
+const (
+	lockoutsURL = "/admin/lockouts"
+	lockoutURL  = "/admin/lockouts/:kind/:value"
+)
+
+type lockoutHandler struct {
+	auth         jwt.Middleware
+	roles        middleware.RoleGuard
+	guardService interf.LoginGuardService
+	logger       *logging.Logger
+}
+
+func NewLockoutHandler(a jwt.Middleware, rg middleware.RoleGuard, gs interf.LoginGuardService, l *logging.Logger) interf.Handler {
+	return &lockoutHandler{
+		auth:         a,
+		roles:        rg,
+		guardService: gs,
+		logger:       l,
+	}
+}
+
+func (h *lockoutHandler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, lockoutsURL, h.auth.Handle(
+		h.roles.Handle(entity.RoleAdmin, apperror.Middleware(h.GetLockouts)), entity.ScopeSession))
+	router.HandlerFunc(http.MethodDelete, lockoutURL, h.auth.Handle(
+		h.roles.Handle(entity.RoleAdmin, apperror.Middleware(h.Unlock)), entity.ScopeSession))
+}
+
+func (h *lockoutHandler) GetLockouts(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("GET LOCKOUTS")
+	w.Header().Set("Content-Type", "application/json")
+
+	attempts, err := h.guardService.GetLocked(r.Context())
+	if err != nil {
+		return err
+	}
+
+	attemptsBytes, err := json.Marshal(attempts)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(attemptsBytes)
+
+	return nil
+}
+
+func (h *lockoutHandler) Unlock(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("UNLOCK LOGIN")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	kind, value := params.ByName("kind"), params.ByName("value")
+	if kind != entity.LoginAttemptEmail && kind != entity.LoginAttemptIP && kind != entity.LoginAttemptTwoFactor {
+		return apperror.BadRequestError("kind must be email, two_factor or ip")
+	}
+
+	if err := h.guardService.Unlock(r.Context(), kind, value); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/controller/http/middleware"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/internal/jwt"
+	"github.com/slava-911/URL-shortener/pkg/cache"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/oidc"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+const (
+	oidcLoginURL    = "/auth/oidc/login"
+	oidcCallbackURL = "/auth/oidc/callback"
+
+	oidcStateCookie    = "oidc_state"
+	oidcStateKeyPrefix = "oidc_state:"
+	oidcStateTTL       = 10 * time.Minute
+	oidcSecretBytes    = 32
+)
+
+// oidcLogin is a login started at the provider, kept until the callback
+type oidcLogin struct {
+	Verifier string `json:"verifier"`
+	Nonce    string `json:"nonce"`
+}
+
+type oidcHandler struct {
+	limiter          middleware.RateLimiter
+	providerName     string
+	secureCookie     bool
+	provider         oidc.Provider
+	logins           cache.Repository
+	jwtHelper        jwt.Helper
+	identityService  interf.IdentityService
+	twoFactorService interf.TwoFactorService
+	logger           *logging.Logger
+}
+
+func NewOIDCHandler(rl middleware.RateLimiter, providerName string, secureCookie bool, p oidc.Provider,
+	logins cache.Repository, h jwt.Helper, is interf.IdentityService, tfs interf.TwoFactorService, l *logging.Logger) interf.Handler {
+	return &oidcHandler{
+		limiter:          rl,
+		providerName:     providerName,
+		secureCookie:     secureCookie,
+		provider:         p,
+		logins:           logins,
+		jwtHelper:        h,
+		identityService:  is,
+		twoFactorService: tfs,
+		logger:           l,
+	}
+}
+
+func (h *oidcHandler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, oidcLoginURL, h.limiter.Handle(middleware.PolicyAuth, apperror.Middleware(h.Login)))
+	router.HandlerFunc(http.MethodGet, oidcCallbackURL, h.limiter.Handle(middleware.PolicyAuth, apperror.Middleware(h.Callback)))
+}
+
+// Login redirects to the provider, state is bound to the browser with cookie
+func (h *oidcHandler) Login(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("OIDC LOGIN")
+
+	state, err := entity.NewChallengeToken()
+	if err != nil {
+		return err
+	}
+	login := oidcLogin{}
+	if login.Verifier, err = entity.NewChallengeToken(); err != nil {
+		return err
+	}
+	if login.Nonce, err = entity.NewChallengeToken(); err != nil {
+		return err
+	}
+
+	loginBytes, err := json.Marshal(login)
+	if err != nil {
+		return err
+	}
+	if err = h.logins.Set([]byte(oidcStateKeyPrefix+state), loginBytes, int(oidcStateTTL.Seconds())); err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     oidcCallbackURL,
+		MaxAge:   int(oidcStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   h.secureCookie,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, h.provider.AuthCodeURL(state, login.Verifier, login.Nonce), http.StatusFound)
+
+	return nil
+}
+
+// Callback finishes login at the provider and responds as /auth does: with tokens or two-factor challenge
+func (h *oidcHandler) Callback(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("OIDC CALLBACK")
+	w.Header().Set("Content-Type", "application/json")
+
+	q := r.URL.Query()
+	if errCode := q.Get("error"); errCode != "" {
+		h.logger.Warnf("oidc provider returned error %s: %s", errCode, q.Get("error_description"))
+		return apperror.ErrUnauthorized
+	}
+
+	state := q.Get("state")
+	cookie, err := r.Cookie(oidcStateCookie)
+	if state == "" || err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		return apperror.BadRequestError("state does not match")
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: oidcCallbackURL, MaxAge: -1, HttpOnly: true, Secure: h.secureCookie})
+
+	key := []byte(oidcStateKeyPrefix + state)
+	loginBytes, err := h.logins.Get(key)
+	if err != nil {
+		return apperror.BadRequestError("login is expired, start it again")
+	}
+	h.logins.Del(key)
+	var login oidcLogin
+	if err = json.Unmarshal(loginBytes, &login); err != nil {
+		return err
+	}
+
+	claims, err := h.provider.Exchange(r.Context(), q.Get("code"), login.Verifier, login.Nonce)
+	if err != nil {
+		h.logger.Errorf("failed to finish oidc login due to error %v", err)
+		return apperror.ErrUnauthorized
+	}
+
+	u, err := h.identityService.Login(r.Context(), entity.Identity{
+		Provider: h.providerName,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}, claims.EmailVerified, claims.Name)
+	if err != nil {
+		return err
+	}
+
+	enabled, err := h.twoFactorService.IsEnabled(r.Context(), u.ID)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		h.logger.Debug("second factor is required")
+		return writeTwoFactorChallenge(w, r, h.twoFactorService, u.ID)
+	}
+
+	token, err := h.jwtHelper.GenerateAccessToken(r.Context(), u, r.UserAgent(), utils.ClientIP(r))
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write(token)
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/internal/jwt"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+)
+
+const (
+	logoutURL   = "/auth/logout"
+	sessionsURL = "/auth/sessions"
+	sessionURL  = "/auth/sessions/:id"
+)
+
+type sessionHandler struct {
+	auth           jwt.Middleware
+	sessionService interf.SessionService
+	logger         *logging.Logger
+}
+
+func NewSessionHandler(a jwt.Middleware, ss interf.SessionService, l *logging.Logger) interf.Handler {
+	return &sessionHandler{
+		auth:           a,
+		sessionService: ss,
+		logger:         l,
+	}
+}
+
+func (h *sessionHandler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodPost, logoutURL, h.auth.Handle(apperror.Middleware(h.Logout), entity.ScopeSession))
+	router.HandlerFunc(http.MethodGet, sessionsURL, h.auth.Handle(apperror.Middleware(h.GetUserSessions), entity.ScopeSession))
+	router.HandlerFunc(http.MethodDelete, sessionURL, h.auth.Handle(apperror.Middleware(h.RevokeSession), entity.ScopeSession))
+}
+
+func (h *sessionHandler) Logout(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("LOGOUT")
+	w.Header().Set("Content-Type", "application/json")
+
+	vClaims := r.Context().Value("user_claims")
+	if vClaims == nil {
+		h.logger.Error("there is no user_claims in context")
+		return apperror.ErrUnauthorized
+	}
+	claims := vClaims.(jwt.UserClaims)
+
+	err := h.sessionService.Logout(r.Context(), claims.Subject, claims.SessionID, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *sessionHandler) GetUserSessions(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("GET USER SESSIONS")
+	w.Header().Set("Content-Type", "application/json")
+
+	vUserID := r.Context().Value("user_id")
+	if vUserID == nil {
+		h.logger.Error("there is no user_id in context")
+		return apperror.ErrUnauthorized
+	}
+	userID := vUserID.(string)
+
+	sessions, err := h.sessionService.GetAllByUserID(r.Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	sessionsBytes, err := json.Marshal(sessions)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(sessionsBytes)
+
+	return nil
+}
+
+func (h *sessionHandler) RevokeSession(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("REVOKE SESSION")
+	w.Header().Set("Content-Type", "application/json")
+
+	vUserID := r.Context().Value("user_id")
+	if vUserID == nil {
+		h.logger.Error("there is no user_id in context")
+		return apperror.ErrUnauthorized
+	}
+	userID := vUserID.(string)
+
+	h.logger.Debug("get id from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	sessionID := params.ByName("id")
+	if sessionID == "" {
+		return apperror.BadRequestError("id query parameter is required")
+	}
+
+	if err := h.sessionService.Revoke(r.Context(), sessionID, userID); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
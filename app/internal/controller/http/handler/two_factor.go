@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/julienschmidt/httprouter"
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	httpdto "github.com/slava-911/URL-shortener/internal/controller/http/dto"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/internal/jwt"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+const (
+	twoFactorURL              = "/profile/2fa"
+	twoFactorEnableURL        = "/profile/2fa/enable"
+	twoFactorRecoveryCodesURL = "/profile/2fa/recovery-codes"
+)
+
+type twoFactorHandler struct {
+	auth             jwt.Middleware
+	twoFactorService interf.TwoFactorService
+	userService      interf.UserService
+	validate         *validator.Validate
+	logger           *logging.Logger
+}
+
+func NewTwoFactorHandler(a jwt.Middleware, tfs interf.TwoFactorService, us interf.UserService, v *validator.Validate,
+	l *logging.Logger) interf.Handler {
+	return &twoFactorHandler{
+		auth:             a,
+		twoFactorService: tfs,
+		userService:      us,
+		validate:         v,
+		logger:           l,
+	}
+}
+
+func (h *twoFactorHandler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodPost, twoFactorURL, h.auth.Handle(apperror.Middleware(h.Enroll), entity.ScopeSession))
+	router.HandlerFunc(http.MethodDelete, twoFactorURL, h.auth.Handle(apperror.Middleware(h.Disable), entity.ScopeSession))
+	router.HandlerFunc(http.MethodPost, twoFactorEnableURL, h.auth.Handle(apperror.Middleware(h.Enable), entity.ScopeSession))
+	router.HandlerFunc(http.MethodPost, twoFactorRecoveryCodesURL, h.auth.Handle(apperror.Middleware(h.RegenerateRecoveryCodes), entity.ScopeSession))
+}
+
+func (h *twoFactorHandler) Enroll(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("ENROLL TWO FACTOR")
+	w.Header().Set("Content-Type", "application/json")
+
+	vUserID := r.Context().Value("user_id")
+	if vUserID == nil {
+		h.logger.Error("there is no user_id in context")
+		return apperror.ErrUnauthorized
+	}
+	userID := vUserID.(string)
+
+	user, err := h.userService.GetOneByID(r.Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	enrollment, err := h.twoFactorService.Enroll(r.Context(), user)
+	if err != nil {
+		return err
+	}
+
+	enrollmentBytes, err := json.Marshal(enrollment)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write(enrollmentBytes)
+
+	return nil
+}
+
+func (h *twoFactorHandler) Enable(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("ENABLE TWO FACTOR")
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, code, err := h.userAndCode(r)
+	if err != nil {
+		return err
+	}
+
+	codes, err := h.twoFactorService.Enable(r.Context(), userID, code)
+	if err != nil {
+		return err
+	}
+
+	return h.writeRecoveryCodes(w, codes)
+}
+
+func (h *twoFactorHandler) Disable(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("DISABLE TWO FACTOR")
+	w.Header().Set("Content-Type", "application/json")
+
+	vUserID := r.Context().Value("user_id")
+	if vUserID == nil {
+		h.logger.Error("there is no user_id in context")
+		return apperror.ErrUnauthorized
+	}
+	userID := vUserID.(string)
+
+	defer r.Body.Close()
+	var disableDTO httpdto.DisableTwoFactorDTO
+	if err := json.NewDecoder(r.Body).Decode(&disableDTO); err != nil {
+		return apperror.BadRequestError("failed to decode data")
+	}
+
+	if err := h.validate.Struct(disableDTO); err != nil {
+		return apperror.BadRequestError(utils.TranslateValidationError(err, ""))
+	}
+
+	if err := h.twoFactorService.Disable(r.Context(), userID, disableDTO.Password, disableDTO.Code); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *twoFactorHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("REGENERATE RECOVERY CODES")
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, code, err := h.userAndCode(r)
+	if err != nil {
+		return err
+	}
+
+	codes, err := h.twoFactorService.RegenerateRecoveryCodes(r.Context(), userID, code)
+	if err != nil {
+		return err
+	}
+
+	return h.writeRecoveryCodes(w, codes)
+}
+
+// userAndCode returns id of the current user and TOTP or recovery code from request body
+func (h *twoFactorHandler) userAndCode(r *http.Request) (string, string, error) {
+	vUserID := r.Context().Value("user_id")
+	if vUserID == nil {
+		h.logger.Error("there is no user_id in context")
+		return "", "", apperror.ErrUnauthorized
+	}
+	userID := vUserID.(string)
+
+	defer r.Body.Close()
+	var codeDTO httpdto.TwoFactorCodeDTO
+	if err := json.NewDecoder(r.Body).Decode(&codeDTO); err != nil {
+		return "", "", apperror.BadRequestError("failed to decode data")
+	}
+
+	if err := h.validate.Struct(codeDTO); err != nil {
+		return "", "", apperror.BadRequestError(utils.TranslateValidationError(err, ""))
+	}
+
+	return userID, codeDTO.Code, nil
+}
+
+func (h *twoFactorHandler) writeRecoveryCodes(w http.ResponseWriter, codes []string) error {
+	codesBytes, err := json.Marshal(httpdto.RecoveryCodesDTO{RecoveryCodes: codes})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(codesBytes)
+
+	return nil
+}
+
+// writeTwoFactorChallenge responds with challenge token which is exchanged for access tokens at /auth/2fa
+func writeTwoFactorChallenge(w http.ResponseWriter, r *http.Request, tfs interf.TwoFactorService, userID string) error {
+	challenge, err := tfs.CreateChallenge(r.Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	challengeBytes, err := json.Marshal(httpdto.TwoFactorChallengeDTO{
+		ChallengeToken:    challenge,
+		TwoFactorRequired: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(challengeBytes)
+
+	return nil
+}
@@ -3,47 +3,68 @@ package handler
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/julienschmidt/httprouter"
 	"github.com/slava-911/URL-shortener/internal/apperror"
 	httpdto "github.com/slava-911/URL-shortener/internal/controller/http/dto"
+	"github.com/slava-911/URL-shortener/internal/controller/http/middleware"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
 	"github.com/slava-911/URL-shortener/internal/interf"
 	"github.com/slava-911/URL-shortener/internal/jwt"
 	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/password"
 	"github.com/slava-911/URL-shortener/pkg/utils"
 )
 
 const (
-	authURL   = "/auth"
-	signupURL = "/signup"
-	userURL   = "/profile"
+	authURL          = "/auth"
+	authTwoFactorURL = "/auth/2fa"
+	signupURL        = "/signup"
+	userURL          = "/profile"
+	userExportURL    = "/profile/export"
 )
 
 type userHandler struct {
-	jwtHelper   jwt.Helper
-	userService interf.UserService
-	validate    *validator.Validate
-	logger      *logging.Logger
+	auth             jwt.Middleware
+	limiter          middleware.RateLimiter
+	jwtHelper        jwt.Helper
+	userService      interf.UserService
+	accountService   interf.AccountService
+	twoFactorService interf.TwoFactorService
+	accountData      interf.AccountDataService
+	validate         *validator.Validate
+	logger           *logging.Logger
 }
 
-func NewUserHandler(h jwt.Helper, us interf.UserService, v *validator.Validate, l *logging.Logger) interf.Handler {
+func NewUserHandler(a jwt.Middleware, rl middleware.RateLimiter, h jwt.Helper, us interf.UserService,
+	as interf.AccountService, tfs interf.TwoFactorService, ads interf.AccountDataService, v *validator.Validate,
+	l *logging.Logger) interf.Handler {
 	return &userHandler{
-		jwtHelper:   h,
-		userService: us,
-		validate:    v,
-		logger:      l,
+		auth:             a,
+		limiter:          rl,
+		jwtHelper:        h,
+		userService:      us,
+		accountService:   as,
+		twoFactorService: tfs,
+		accountData:      ads,
+		validate:         v,
+		logger:           l,
 	}
 }
 
 func (h *userHandler) Register(router *httprouter.Router) {
-	router.HandlerFunc(http.MethodPost, authURL, apperror.Middleware(h.Auth))
-	router.HandlerFunc(http.MethodPut, authURL, apperror.Middleware(h.Auth))
-	router.HandlerFunc(http.MethodPost, signupURL, apperror.Middleware(h.Signup))
-	router.HandlerFunc(http.MethodGet, userURL, jwt.Middleware(apperror.Middleware(h.GetUser), h.logger))
-	router.HandlerFunc(http.MethodPatch, userURL, jwt.Middleware(apperror.Middleware(h.PartiallyUpdateUser), h.logger))
-	router.HandlerFunc(http.MethodDelete, userURL, jwt.Middleware(apperror.Middleware(h.DeleteUser), h.logger))
+	router.HandlerFunc(http.MethodPost, authURL, h.limiter.Handle(middleware.PolicyAuth, apperror.Middleware(h.Auth)))
+	router.HandlerFunc(http.MethodPut, authURL, h.limiter.Handle(middleware.PolicyAuth, apperror.Middleware(h.Auth)))
+	router.HandlerFunc(http.MethodPost, authTwoFactorURL, h.limiter.Handle(middleware.PolicyAuth, apperror.Middleware(h.AuthTwoFactor)))
+	router.HandlerFunc(http.MethodPost, signupURL, h.limiter.Handle(middleware.PolicyAuth, apperror.Middleware(h.Signup)))
+	router.HandlerFunc(http.MethodGet, userURL, h.auth.Handle(apperror.Middleware(h.GetUser), entity.ScopeProfileRead))
+	router.HandlerFunc(http.MethodPatch, userURL, h.auth.Handle(apperror.Middleware(h.PartiallyUpdateUser), entity.ScopeProfileWrite))
+	router.HandlerFunc(http.MethodDelete, userURL, h.auth.Handle(apperror.Middleware(h.DeleteUser), entity.ScopeSession))
+	router.HandlerFunc(http.MethodGet, userExportURL, h.auth.Handle(apperror.Middleware(h.ExportUser), entity.ScopeSession))
 }
 
 func (h *userHandler) Signup(w http.ResponseWriter, r *http.Request) error {
@@ -70,7 +91,12 @@ func (h *userHandler) Signup(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	token, err := h.jwtHelper.GenerateAccessToken(user)
+	// user can request verification email again, so signup does not fail
+	if err = h.accountService.SendVerification(r.Context(), user); err != nil {
+		h.logger.Errorf("failed to send verification email due to error %v", err)
+	}
+
+	token, err := h.jwtHelper.GenerateAccessToken(r.Context(), user, r.UserAgent(), utils.ClientIP(r))
 	if err != nil {
 		return err
 	}
@@ -93,11 +119,19 @@ func (h *userHandler) Auth(w http.ResponseWriter, r *http.Request) error {
 		if err := json.NewDecoder(r.Body).Decode(&userDTO); err != nil {
 			return apperror.BadRequestError("failed to decode data")
 		}
-		u, err := h.userService.GetOneByEmailAndPassword(r.Context(), userDTO.Email, userDTO.Password)
+		u, err := h.userService.GetOneByEmailAndPassword(r.Context(), userDTO.Email, userDTO.Password, utils.ClientIP(r))
 		if err != nil {
 			return err
 		}
-		token, err = h.jwtHelper.GenerateAccessToken(u)
+		enabled, err := h.twoFactorService.IsEnabled(r.Context(), u.ID)
+		if err != nil {
+			return err
+		}
+		if enabled {
+			h.logger.Debug("second factor is required")
+			return writeTwoFactorChallenge(w, r, h.twoFactorService, u.ID)
+		}
+		token, err = h.jwtHelper.GenerateAccessToken(r.Context(), u, r.UserAgent(), utils.ClientIP(r))
 		if err != nil {
 			return err
 		}
@@ -107,7 +141,7 @@ func (h *userHandler) Auth(w http.ResponseWriter, r *http.Request) error {
 		if err := json.NewDecoder(r.Body).Decode(&rt); err != nil {
 			return apperror.BadRequestError("failed to decode data")
 		}
-		token, err = h.jwtHelper.UpdateRefreshToken(rt)
+		token, err = h.jwtHelper.UpdateRefreshToken(r.Context(), rt)
 		if err != nil {
 			return err
 		}
@@ -119,6 +153,42 @@ func (h *userHandler) Auth(w http.ResponseWriter, r *http.Request) error {
 	return err
 }
 
+func (h *userHandler) AuthTwoFactor(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("AUTH TWO FACTOR")
+	w.Header().Set("Content-Type", "application/json")
+
+	defer r.Body.Close()
+	var loginDTO httpdto.TwoFactorLoginDTO
+	if err := json.NewDecoder(r.Body).Decode(&loginDTO); err != nil {
+		return apperror.BadRequestError("failed to decode data")
+	}
+
+	if err := h.validate.Struct(loginDTO); err != nil {
+		return apperror.BadRequestError(utils.TranslateValidationError(err, ""))
+	}
+
+	userID, err := h.twoFactorService.VerifyChallenge(r.Context(), loginDTO.ChallengeToken, loginDTO.Code,
+		utils.ClientIP(r))
+	if err != nil {
+		return err
+	}
+
+	u, err := h.userService.GetOneByID(r.Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	token, err := h.jwtHelper.GenerateAccessToken(r.Context(), u, r.UserAgent(), utils.ClientIP(r))
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write(token)
+
+	return nil
+}
+
 func (h *userHandler) GetUser(w http.ResponseWriter, r *http.Request) error {
 	h.logger.Info("GET USER")
 	w.Header().Set("Content-Type", "application/json")
@@ -182,7 +252,19 @@ func (h *userHandler) PartiallyUpdateUser(w http.ResponseWriter, r *http.Request
 	}
 	if userDTO.OldPassword != nil && userDTO.NewPassword != nil {
 		if *userDTO.OldPassword != *userDTO.NewPassword && *userDTO.OldPassword != "" && *userDTO.NewPassword != "" {
-			if err := h.validate.Var(*userDTO.NewPassword, "required,min=6"); err != nil {
+			user, err := h.userService.GetOneByID(r.Context(), userID)
+			if err != nil {
+				return err
+			}
+			personal := []string{user.Name, user.Email}
+			if userDTO.Name != nil {
+				personal = append(personal, *userDTO.Name)
+			}
+			if userDTO.Email != nil {
+				personal = append(personal, *userDTO.Email)
+			}
+			ctx := password.WithPersonalInfo(r.Context(), personal...)
+			if err = h.validate.VarCtx(ctx, *userDTO.NewPassword, "required,password"); err != nil {
 				return apperror.BadRequestError(utils.TranslateValidationError(err, "New password"))
 			}
 			oldPassword = *userDTO.OldPassword
@@ -198,6 +280,17 @@ func (h *userHandler) PartiallyUpdateUser(w http.ResponseWriter, r *http.Request
 		return err
 	}
 
+	if _, ok := changedFields["email"]; ok {
+		h.logger.Debug("send verification to the new email")
+		user, err := h.userService.GetOneByID(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+		if err = h.accountService.SendVerification(r.Context(), user); err != nil {
+			h.logger.Errorf("failed to send verification email due to error %v", err)
+		}
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 
 	return nil
@@ -215,13 +308,55 @@ func (h *userHandler) DeleteUser(w http.ResponseWriter, r *http.Request) error {
 	}
 	userID := vUserID.(string)
 
-	err := h.userService.Delete(r.Context(), userID)
+	// the account is disabled at once and deleted by background job
+	err := h.accountData.RequestDeletion(r.Context(), userID)
 	if err != nil {
 		return err
 	}
 
 	w.Header().Set("Location", signupURL)
-	w.WriteHeader(http.StatusNoContent)
+	w.WriteHeader(http.StatusAccepted)
+
+	return nil
+}
+
+// ExportUser sends zip archive with profile, links, click events and audit events of the user
+func (h *userHandler) ExportUser(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("EXPORT USER")
+
+	h.logger.Debug("get id from context")
+	vUserID := r.Context().Value("user_id")
+	if vUserID == nil {
+		h.logger.Error("there is no user_id in context")
+		return apperror.ErrUnauthorized
+	}
+	userID := vUserID.(string)
+
+	// archive is built in temporary file, so that failures are reported with proper status instead of broken file
+	// and memory does not grow with the number of click events
+	f, err := os.CreateTemp("", "account-export-*.zip")
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		return fmt.Errorf("failed to create temporary file, error: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err = h.accountData.Export(r.Context(), userID, f); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		return err
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		return fmt.Errorf("failed to rewind temporary file, error: %w", err)
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="account-data.zip"`)
+	w.WriteHeader(http.StatusOK)
+	if _, err = io.Copy(w, f); err != nil {
+		h.logger.Errorf("failed to send account export due to error %v", err)
+	}
 
 	return nil
 }
@@ -0,0 +1,277 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/julienschmidt/httprouter"
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	httpdto "github.com/slava-911/URL-shortener/internal/controller/http/dto"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/internal/jwt"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+const (
+	webhooksURL          = "/webhooks"
+	webhookURL           = "/webhooks/:id"
+	webhookDeliveriesURL = "/webhooks/:id/deliveries"
+	webhookPingURL       = "/webhooks/:id/ping"
+)
+
+type webhookHandler struct {
+	auth           jwt.Middleware
+	webhookService interf.WebhookService
+	validate       *validator.Validate
+	logger         *logging.Logger
+}
+
+func NewWebhookHandler(a jwt.Middleware, ws interf.WebhookService, v *validator.Validate, l *logging.Logger) interf.Handler {
+	return &webhookHandler{
+		auth:           a,
+		webhookService: ws,
+		validate:       v,
+		logger:         l,
+	}
+}
+
+func (h *webhookHandler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodPost, webhooksURL, h.auth.Handle(apperror.Middleware(h.CreateWebhook), entity.ScopeSession))
+	router.HandlerFunc(http.MethodGet, webhooksURL, h.auth.Handle(apperror.Middleware(h.GetUserWebhooks), entity.ScopeSession))
+	router.HandlerFunc(http.MethodGet, webhookURL, h.auth.Handle(apperror.Middleware(h.GetWebhook), entity.ScopeSession))
+	router.HandlerFunc(http.MethodPatch, webhookURL, h.auth.Handle(apperror.Middleware(h.PartiallyUpdateWebhook), entity.ScopeSession))
+	router.HandlerFunc(http.MethodDelete, webhookURL, h.auth.Handle(apperror.Middleware(h.DeleteWebhook), entity.ScopeSession))
+	router.HandlerFunc(http.MethodGet, webhookDeliveriesURL, h.auth.Handle(apperror.Middleware(h.GetWebhookDeliveries), entity.ScopeSession))
+	router.HandlerFunc(http.MethodPost, webhookPingURL, h.auth.Handle(apperror.Middleware(h.PingWebhook), entity.ScopeSession))
+}
+
+func (h *webhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("CREATE WEBHOOK")
+	w.Header().Set("Content-Type", "application/json")
+
+	vUserID := r.Context().Value("user_id")
+	if vUserID == nil {
+		h.logger.Error("there is no user_id in context")
+		return apperror.ErrUnauthorized
+	}
+	userID := vUserID.(string)
+
+	h.logger.Debug("decode create webhook dto")
+	var webhookDTO httpdto.CreateWebhookDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&webhookDTO); err != nil {
+		return apperror.BadRequestError("invalid data")
+	}
+	webhookDTO.UserID = userID
+
+	h.logger.Debugf("Validation for webhook: %s", webhookDTO.URL)
+	if err := h.validate.Struct(webhookDTO); err != nil {
+		return apperror.BadRequestError(utils.TranslateValidationError(err, ""))
+	}
+	if !httpdto.ValidLink(webhookDTO.URL) {
+		return apperror.BadRequestError("webhook url must be an absolute http or https url")
+	}
+
+	webhook, err := h.webhookService.Create(r.Context(), httpdto.NewWebhook(webhookDTO))
+	if err != nil {
+		return err
+	}
+
+	webhookBytes, err := json.Marshal(httpdto.CreatedWebhookDTO{Webhook: webhook, Secret: webhook.Secret})
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/%s", webhooksURL, webhook.ID))
+	w.WriteHeader(http.StatusCreated)
+	w.Write(webhookBytes)
+
+	return nil
+}
+
+func (h *webhookHandler) GetUserWebhooks(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("GET USER WEBHOOKS")
+	w.Header().Set("Content-Type", "application/json")
+
+	vUserID := r.Context().Value("user_id")
+	if vUserID == nil {
+		h.logger.Error("there is no user_id in context")
+		return apperror.ErrUnauthorized
+	}
+	userID := vUserID.(string)
+
+	webhooks, err := h.webhookService.GetAllByUserID(r.Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	webhooksBytes, err := json.Marshal(webhooks)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(webhooksBytes)
+
+	return nil
+}
+
+func (h *webhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("GET WEBHOOK")
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, webhookID, err := h.ids(r)
+	if err != nil {
+		return err
+	}
+
+	webhook, err := h.webhookService.GetOne(r.Context(), webhookID, userID)
+	if err != nil {
+		return err
+	}
+
+	webhookBytes, err := json.Marshal(webhook)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(webhookBytes)
+
+	return nil
+}
+
+func (h *webhookHandler) PartiallyUpdateWebhook(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("PARTIALLY UPDATE WEBHOOK")
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, webhookID, err := h.ids(r)
+	if err != nil {
+		return err
+	}
+
+	h.logger.Debug("decode update webhook dto")
+	var webhookDTO httpdto.UpdateWebhookDTO
+	defer r.Body.Close()
+	if err = json.NewDecoder(r.Body).Decode(&webhookDTO); err != nil {
+		return apperror.BadRequestError("invalid data")
+	}
+
+	if err = h.validate.Struct(webhookDTO); err != nil {
+		return apperror.BadRequestError(utils.TranslateValidationError(err, ""))
+	}
+	if webhookDTO.URL != nil && !httpdto.ValidLink(*webhookDTO.URL) {
+		return apperror.BadRequestError("webhook url must be an absolute http or https url")
+	}
+
+	webhook, err := h.webhookService.GetOne(r.Context(), webhookID, userID)
+	if err != nil {
+		return err
+	}
+	webhookDTO.Apply(&webhook)
+
+	if err = h.webhookService.Update(r.Context(), webhook); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *webhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("DELETE WEBHOOK")
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, webhookID, err := h.ids(r)
+	if err != nil {
+		return err
+	}
+
+	if err = h.webhookService.Delete(r.Context(), webhookID, userID); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// GetWebhookDeliveries returns delivery log of the webhook, the latest deliveries first
+func (h *webhookHandler) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("GET WEBHOOK DELIVERIES")
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, webhookID, err := h.ids(r)
+	if err != nil {
+		return err
+	}
+
+	limit, offset, err := pagination(r)
+	if err != nil {
+		return err
+	}
+
+	deliveries, err := h.webhookService.GetDeliveries(r.Context(), webhookID, userID, limit, offset)
+	if err != nil {
+		return err
+	}
+
+	deliveriesBytes, err := json.Marshal(deliveries)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(deliveriesBytes)
+
+	return nil
+}
+
+// PingWebhook sends ping event at once and returns the delivery, failed ping is reported in the delivery
+func (h *webhookHandler) PingWebhook(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info("PING WEBHOOK")
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, webhookID, err := h.ids(r)
+	if err != nil {
+		return err
+	}
+
+	delivery, err := h.webhookService.Ping(r.Context(), webhookID, userID)
+	if err != nil {
+		return err
+	}
+
+	deliveryBytes, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(deliveryBytes)
+
+	return nil
+}
+
+// ids returns id of the signed-in user and id of the webhook from the path
+func (h *webhookHandler) ids(r *http.Request) (userID, webhookID string, err error) {
+	vUserID := r.Context().Value("user_id")
+	if vUserID == nil {
+		h.logger.Error("there is no user_id in context")
+		return "", "", apperror.ErrUnauthorized
+	}
+
+	h.logger.Debug("get id from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	webhookID = params.ByName("id")
+	if webhookID == "" {
+		return "", "", apperror.BadRequestError("id query parameter is required")
+	}
+
+	return vUserID.(string), webhookID, nil
+}
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/config"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/jwt"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/ratelimit"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+// Keys requests are counted by
+const (
+	KeyIP     = "ip"
+	KeyUser   = "user"
+	KeyAPIKey = "api_key"
+)
+
+// Policy names used by routes
+const (
+	PolicyAuth        = "auth"
+	PolicyLinksCreate = "links_create"
+	PolicyLinksImport = "links_import"
+	PolicyRedirect    = "redirect"
+	PolicyReport      = "report"
+)
+
+// defaultPolicies apply to policies missing from the config, so that a route is not left unlimited by mistake
+var defaultPolicies = map[string]config.RateLimitPolicy{
+	PolicyAuth:        {Rate: 10, Period: time.Minute, Burst: 5, Key: KeyIP},
+	PolicyLinksCreate: {Rate: 60, Period: time.Minute, Burst: 20, Key: KeyAPIKey},
+	PolicyLinksImport: {Rate: 10, Period: time.Hour, Burst: 3, Key: KeyUser},
+	PolicyRedirect:    {Rate: 600, Period: time.Minute, Burst: 100, Key: KeyIP},
+	PolicyReport:      {Rate: 10, Period: time.Hour, Burst: 3, Key: KeyIP},
+}
+
+var _ RateLimiter = &rateLimiter{}
+
+type RateLimiter interface {
+	// Handle limits requests to h according to the named policy, policy missing from the config has default limit,
+	// policy configured with zero rate, period or burst is disabled.
+	// Policies counting by user must wrap handlers which are already authorized.
+	Handle(policy string, h http.HandlerFunc) http.HandlerFunc
+}
+
+type rateLimiter struct {
+	enabled  bool
+	store    ratelimit.Store
+	policies map[string]config.RateLimitPolicy
+	logger   *logging.Logger
+}
+
+func NewRateLimiter(enabled bool, store ratelimit.Store, policies map[string]config.RateLimitPolicy,
+	logger *logging.Logger) RateLimiter {
+	merged := make(map[string]config.RateLimitPolicy, len(defaultPolicies))
+	for name, p := range defaultPolicies {
+		merged[name] = p
+	}
+	for name, p := range policies {
+		if _, ok := defaultPolicies[name]; !ok {
+			logger.Warnf("unknown rate limit policy %s in config is ignored", name)
+			continue
+		}
+		merged[name] = p
+	}
+	for name := range defaultPolicies {
+		if _, ok := policies[name]; !ok && enabled {
+			logger.Warnf("rate limit policy %s is not configured, default limit is used", name)
+		}
+	}
+
+	return &rateLimiter{
+		enabled:  enabled,
+		store:    store,
+		policies: merged,
+		logger:   logger,
+	}
+}
+
+func (l *rateLimiter) Handle(policy string, h http.HandlerFunc) http.HandlerFunc {
+	if !l.enabled {
+		return h
+	}
+	p, ok := l.policies[policy]
+	if !ok {
+		// routes are registered on start, so that a route with unknown policy fails the start
+		l.logger.Fatalf("unknown rate limit policy %s", policy)
+	}
+	if p.Rate <= 0 || p.Period <= 0 || p.Burst <= 0 {
+		l.logger.Warnf("rate limit policy %s is disabled", policy)
+		return h
+	}
+	limit := ratelimit.Limit{Rate: p.Rate, Period: p.Period, Burst: p.Burst}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := policy + ":" + requestKey(p.Key, r)
+		res, err := l.store.Take(r.Context(), key, limit)
+		if err != nil {
+			// limiter must not take the service down, let the request through
+			l.logger.Errorf("failed to check rate limit due to error %v", err)
+			h(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		w.Header().Set("RateLimit-Reset", ceilSeconds(res.ResetAfter.Seconds()))
+
+		if !res.Allowed {
+			l.logger.Warnf("rate limit %s exceeded by %s", policy, key)
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", ceilSeconds(res.RetryAfter.Seconds()))
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write(apperror.ErrTooManyRequests.Marshal())
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+// requestKey identifies request origin, falling back to less specific keys when more specific one is missing:
+// api key -> user -> ip
+func requestKey(kind string, r *http.Request) string {
+	switch kind {
+	case KeyAPIKey:
+		if key := apiKey(r); key != "" {
+			return KeyAPIKey + ":" + entity.HashToken(key)
+		}
+		fallthrough
+	case KeyUser:
+		if userID, ok := r.Context().Value("user_id").(string); ok && userID != "" {
+			return KeyUser + ":" + userID
+		}
+	}
+	return KeyIP + ":" + utils.ClientIP(r)
+}
+
+func apiKey(r *http.Request) string {
+	if key := r.Header.Get(jwt.APIKeyHeader); key != "" {
+		return key
+	}
+	if token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); strings.HasPrefix(token, entity.APIKeyPrefix) {
+		return token
+	}
+	return ""
+}
+
+func ceilSeconds(s float64) string {
+	return strconv.Itoa(int(math.Ceil(s)))
+}
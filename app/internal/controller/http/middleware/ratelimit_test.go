@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/slava-911/URL-shortener/internal/config"
+	"github.com/slava-911/URL-shortener/pkg/cache/freecache"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterPolicies(t *testing.T) {
+	logger := logging.GetLogger("panic")
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+
+	for _, tc := range []struct {
+		name     string
+		policies map[string]config.RateLimitPolicy
+		allowed  int
+	}{
+		{name: "missing policy has default limit", policies: nil, allowed: defaultPolicies[PolicyAuth].Burst},
+		{
+			name: "configured policy overrides default",
+			policies: map[string]config.RateLimitPolicy{
+				PolicyAuth: {Rate: 1, Period: time.Minute, Burst: 2, Key: KeyIP},
+			},
+			allowed: 2,
+		},
+		{
+			name: "policy with zero burst is disabled",
+			policies: map[string]config.RateLimitPolicy{
+				PolicyAuth: {Rate: 1, Period: time.Minute, Key: KeyIP},
+			},
+			allowed: 20,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			store := ratelimit.NewCacheStore(freecache.NewCacheRepo(1024 * 1024))
+			h := NewRateLimiter(true, store, tc.policies, logger).Handle(PolicyAuth, ok)
+
+			allowed := 0
+			for i := 0; i < 20; i++ {
+				rec := httptest.NewRecorder()
+				h(rec, httptest.NewRequest(http.MethodPost, "/auth", nil))
+				if rec.Code == http.StatusOK {
+					allowed++
+				}
+			}
+			assert.Equal(t, tc.allowed, allowed)
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+// RequestInfo stores client ip and user agent in the request context, so that services can record them in audit log
+func RequestInfo(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := entity.WithRequestInfo(r.Context(), entity.RequestInfo{
+			IP:        utils.ClientIP(r),
+			UserAgent: r.UserAgent(),
+		})
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+)
+
+var _ RoleGuard = &roleGuard{}
+
+type RoleGuard interface {
+	// Handle lets through only enabled users having the role, it must wrap handlers which are already authorized
+	Handle(role string, h http.HandlerFunc) http.HandlerFunc
+}
+
+type roleGuard struct {
+	userService interf.UserService
+	logger      *logging.Logger
+}
+
+func NewRoleGuard(us interf.UserService, logger *logging.Logger) RoleGuard {
+	return &roleGuard{
+		userService: us,
+		logger:      logger,
+	}
+}
+
+func (g *roleGuard) Handle(role string, h http.HandlerFunc) http.HandlerFunc {
+	return apperror.Middleware(func(w http.ResponseWriter, r *http.Request) error {
+		vUserID := r.Context().Value("user_id")
+		if vUserID == nil {
+			g.logger.Error("there is no user_id in context")
+			return apperror.ErrUnauthorized
+		}
+		userID := vUserID.(string)
+
+		// role is read from storage, so that revoked role takes effect before access token expires
+		u, err := g.userService.GetOneByID(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+		if u.Role != role || u.IsDisabled() {
+			g.logger.Warnf("user %s with role %s has no access to role %s", userID, u.Role, role)
+			return apperror.ErrForbidden
+		}
+
+		h(w, r)
+		return nil
+	})
+}
@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+// AccountDeletion is a request of the user to delete the account, it is completed by background job.
+// Links and Clicks are totals of the deleted links kept for global stats.
+type AccountDeletion struct {
+	UserID      string     `json:"user_id"`
+	RequestedAt time.Time  `json:"requested_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Links       int64      `json:"links"`
+	Clicks      int64      `json:"clicks"`
+}
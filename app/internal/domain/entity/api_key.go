@@ -0,0 +1,88 @@
+package entity
+
+import "time"
+
+const (
+	// APIKeyPrefix marks personal API keys so they can be told apart from JWT access tokens
+	APIKeyPrefix = "usk_"
+
+	apiKeyBytes       = 32
+	apiKeyPrefixChars = 8
+)
+
+// Scopes which can be granted to a personal API key
+const (
+	ScopeLinksRead    = "links:read"
+	ScopeLinksWrite   = "links:write"
+	ScopeProfileRead  = "profile:read"
+	ScopeProfileWrite = "profile:write"
+)
+
+// ScopeSession is required by routes that must only be reachable with an interactive login
+// (for example API key management), it can never be granted to an API key.
+const ScopeSession = "session"
+
+var APIKeyScopes = []string{ScopeLinksRead, ScopeLinksWrite, ScopeProfileRead, ScopeProfileWrite}
+
+type APIKey struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// GenerateKey creates new random key, stores its hash and display prefix and returns raw key,
+// raw key is not stored anywhere and must be shown to the user only once
+func (k *APIKey) GenerateKey() (string, error) {
+	token, err := generateToken(apiKeyBytes)
+	if err != nil {
+		return "", err
+	}
+	raw := APIKeyPrefix + token
+	k.Prefix = raw[:len(APIKeyPrefix)+apiKeyPrefixChars]
+	k.KeyHash = HashToken(raw)
+	return raw, nil
+}
+
+// IsActiveAt reports whether the key is neither revoked nor expired at the given time
+func (k *APIKey) IsActiveAt(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	if k.ExpiresAt != nil && !now.Before(*k.ExpiresAt) {
+		return false
+	}
+	return true
+}
+
+// HasScopes reports whether the key grants all passed scopes, key without scopes grants every APIKeyScopes scope
+func (k *APIKey) HasScopes(scopes ...string) bool {
+	for _, s := range scopes {
+		if !ValidAPIKeyScope(s) {
+			return false
+		}
+		if len(k.Scopes) > 0 && !contains(k.Scopes, s) {
+			return false
+		}
+	}
+	return true
+}
+
+func ValidAPIKeyScope(scope string) bool {
+	return contains(APIKeyScopes, scope)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package entity
+
+import (
+	"context"
+	"time"
+)
+
+// Audit actions
+const (
+	AuditUserUpdate        = "user.update"
+	AuditUserDelete        = "user.delete"
+	AuditUserDeleteRequest = "user.delete_request"
+	AuditUserRole          = "user.role"
+	AuditUserDisable       = "user.disable"
+	AuditUserEnable        = "user.enable"
+	AuditUserPasswordReset = "user.password_reset"
+	AuditLinkCreate        = "link.create"
+	AuditLinkUpdate        = "link.update"
+	AuditLinkDelete        = "link.delete"
+	AuditLinkRestore       = "link.restore"
+	AuditLinkStatus        = "link.status"
+)
+
+// Types of audit targets
+const (
+	AuditTargetUser = "user"
+	AuditTargetLink = "link"
+)
+
+// auditRedacted replaces values of secret fields, so that only the fact of the change is recorded
+const auditRedacted = "[redacted]"
+
+var auditSecretFields = map[string]bool{"password": true}
+
+// AuditChange is a value of the field before and after the change
+type AuditChange struct {
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// AuditEvent records who changed what, ActorID is empty for changes made without signed-in user
+type AuditEvent struct {
+	ID         string                 `json:"id"`
+	ActorID    string                 `json:"actor_id,omitempty"`
+	Action     string                 `json:"action"`
+	TargetType string                 `json:"target_type"`
+	TargetID   string                 `json:"target_id"`
+	Changes    map[string]AuditChange `json:"changes,omitempty"`
+	IP         string                 `json:"ip"`
+	UserAgent  string                 `json:"user_agent"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+// AuditFilter selects events, UserID matches both the actor and the target user
+type AuditFilter struct {
+	UserID string
+	LinkID string
+	From   *time.Time
+	To     *time.Time
+	Limit  int
+	Offset int
+}
+
+// AuditDiff returns changes of fields present in after, unchanged fields are skipped and secret fields are redacted
+func AuditDiff(before, after map[string]string) map[string]AuditChange {
+	changes := make(map[string]AuditChange, len(after))
+	for field, value := range after {
+		if auditSecretFields[field] {
+			changes[field] = AuditChange{Before: auditRedacted, After: auditRedacted}
+			continue
+		}
+		if before[field] != value {
+			changes[field] = AuditChange{Before: before[field], After: value}
+		}
+	}
+	return changes
+}
+
+// RequestInfo describes the client making the request
+type RequestInfo struct {
+	IP        string
+	UserAgent string
+}
+
+type requestInfoKey struct{}
+
+func WithRequestInfo(ctx context.Context, ri RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, ri)
+}
+
+// RequestInfoFromContext returns empty info for contexts not made by http request, e.g. background jobs
+func RequestInfoFromContext(ctx context.Context) RequestInfo {
+	ri, _ := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return ri
+}
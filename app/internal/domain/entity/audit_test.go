@@ -0,0 +1,27 @@
+package entity
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditDiff(t *testing.T) {
+	before := map[string]string{"name": "Ivan", "email": "ivan@mail.ru", "password": "hash"}
+	after := map[string]string{"name": "Ivan", "email": "ivan@gmail.com", "password": "new hash"}
+
+	changes := AuditDiff(before, after)
+
+	assert.Equal(t, map[string]AuditChange{
+		"email":    {Before: "ivan@mail.ru", After: "ivan@gmail.com"},
+		"password": {Before: auditRedacted, After: auditRedacted},
+	}, changes)
+}
+
+func TestRequestInfoFromContext(t *testing.T) {
+	assert.Equal(t, RequestInfo{}, RequestInfoFromContext(context.Background()))
+
+	ctx := WithRequestInfo(context.Background(), RequestInfo{IP: "10.0.0.1", UserAgent: "curl"})
+	assert.Equal(t, RequestInfo{IP: "10.0.0.1", UserAgent: "curl"}, RequestInfoFromContext(ctx))
+}
@@ -0,0 +1,36 @@
+package entity
+
+import (
+	"time"
+	"unicode/utf8"
+)
+
+// clickFieldMaxLen limits stored referrer and user agent, clients can send headers of any length
+const clickFieldMaxLen = 512
+
+// ClickEvent is a single redirect of the link, LinkID is empty once the owner's account is deleted
+type ClickEvent struct {
+	ID        int64     `json:"id"`
+	LinkID    string    `json:"link_id,omitempty"`
+	Referrer  string    `json:"referrer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	ClickedAt time.Time `json:"clicked_at"`
+}
+
+// Truncated returns the event with referrer and user agent cut to the stored length
+func (c ClickEvent) Truncated() ClickEvent {
+	c.Referrer = truncate(c.Referrer, clickFieldMaxLen)
+	c.UserAgent = truncate(c.UserAgent, clickFieldMaxLen)
+	return c
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	// do not cut multibyte rune in half
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,21 @@
+package entity
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClickEventTruncated(t *testing.T) {
+	c := ClickEvent{
+		Referrer:  "https://example.com/",
+		UserAgent: strings.Repeat("a", clickFieldMaxLen-1) + "я",
+	}
+
+	got := c.Truncated()
+	assert.Equal(t, c.Referrer, got.Referrer)
+	assert.Len(t, got.UserAgent, clickFieldMaxLen-1)
+	assert.True(t, utf8.ValidString(got.UserAgent))
+}
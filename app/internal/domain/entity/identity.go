@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+// Identity is an account of external identity provider linked to the user
+type Identity struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
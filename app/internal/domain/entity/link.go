@@ -7,6 +7,13 @@ import (
 
 const letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 
+// Link statuses, only active links are redirected
+const (
+	LinkStatusActive    = "active"
+	LinkStatusDisabled  = "disabled"
+	LinkStatusTakenDown = "taken_down"
+)
+
 type Link struct {
 	ID           string    `json:"id"`
 	FullVersion  string    `json:"full_version"`
@@ -15,6 +22,26 @@ type Link struct {
 	CreatedAt    time.Time `json:"created_at"`
 	Clicked      int       `json:"clicked"`
 	UserID       string    `json:"user_id"`
+	Status       string    `json:"status"`
+	// DeletedAt is set while the link is in trash
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// AuditFields returns fields which changes are recorded in audit log
+func (l *Link) AuditFields() map[string]string {
+	return map[string]string{
+		"full_version": l.FullVersion,
+		"description":  l.Description,
+		"status":       l.Status,
+	}
+}
+
+// LinkFilter is a search of links by admin, Query is matched against full version, short version and description
+type LinkFilter struct {
+	Query  string
+	UserID string
+	Limit  int
+	Offset int
 }
 
 // GenerateShortVersion creates random string for short version of link
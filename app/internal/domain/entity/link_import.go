@@ -0,0 +1,37 @@
+package entity
+
+import "regexp"
+
+// What to do with imported link which short version is taken or retired
+const (
+	LinkImportGenerate = "generate"
+	LinkImportSkip     = "skip"
+)
+
+var shortVersionRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// LinkImportRow is a link read from line Line of imported file, empty ShortVersion gets a generated one
+type LinkImportRow struct {
+	Line int
+	Link Link
+}
+
+// LinkImportIssue describes a row which was not imported as is
+type LinkImportIssue struct {
+	Line         int    `json:"line"`
+	ShortVersion string `json:"short_version,omitempty"`
+	// AssignedShortVersion is set when the link was imported under a generated short version
+	AssignedShortVersion string `json:"assigned_short_version,omitempty"`
+	Reason               string `json:"reason"`
+}
+
+type LinkImportResult struct {
+	Imported  int               `json:"imported"`
+	Conflicts []LinkImportIssue `json:"conflicts"`
+	Errors    []LinkImportIssue `json:"errors"`
+}
+
+// ValidShortVersion reports whether sv can be used in short link path as is
+func ValidShortVersion(sv string) bool {
+	return shortVersionRe.MatchString(sv)
+}
@@ -0,0 +1,40 @@
+package entity
+
+import "time"
+
+// Reasons of abuse reports
+const (
+	ReportReasonSpam      = "spam"
+	ReportReasonPhishing  = "phishing"
+	ReportReasonMalware   = "malware"
+	ReportReasonCopyright = "copyright"
+	ReportReasonOther     = "other"
+)
+
+// Resolutions of abuse reports, all but dismissed change status of the link
+const (
+	ReportResolutionDismissed = "dismissed"
+	ReportResolutionDisabled  = LinkStatusDisabled
+	ReportResolutionTakenDown = LinkStatusTakenDown
+)
+
+// LinkReport is an abuse report sent by a visitor of short link
+type LinkReport struct {
+	ID           string     `json:"id"`
+	LinkID       string     `json:"link_id"`
+	ShortVersion string     `json:"short_version"`
+	Reason       string     `json:"reason"`
+	Details      string     `json:"details"`
+	ReporterIP   string     `json:"reporter_ip"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ResolvedAt   *time.Time `json:"resolved_at,omitempty"`
+	Resolution   *string    `json:"resolution,omitempty"`
+}
+
+// LinkReportFilter is a search of reports by admin, only unresolved reports are returned if Resolved is false
+type LinkReportFilter struct {
+	Resolved bool
+	LinkID   string
+	Limit    int
+	Offset   int
+}
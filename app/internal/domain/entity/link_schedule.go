@@ -0,0 +1,29 @@
+package entity
+
+import "time"
+
+// LinkSchedule switches the link to FullVersion at ApplyAt, AppliedAt is nil while it is pending
+type LinkSchedule struct {
+	ID          string     `json:"id"`
+	LinkID      string     `json:"link_id"`
+	FullVersion string     `json:"full_version"`
+	ApplyAt     time.Time  `json:"apply_at"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"`
+	CreatedBy   string     `json:"created_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// LatestDue returns the pending schedule of the link which is due last by now, schedules due earlier are overridden by it
+func LatestDue(schedules []LinkSchedule, now time.Time) (LinkSchedule, bool) {
+	var latest LinkSchedule
+	found := false
+	for _, s := range schedules {
+		if s.AppliedAt != nil || s.ApplyAt.After(now) {
+			continue
+		}
+		if !found || s.ApplyAt.After(latest.ApplyAt) {
+			latest, found = s, true
+		}
+	}
+	return latest, found
+}
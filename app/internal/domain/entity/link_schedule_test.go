@@ -0,0 +1,27 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatestDue(t *testing.T) {
+	now := time.Date(2023, 2, 6, 9, 0, 0, 0, time.UTC)
+	applied := now.Add(-time.Hour)
+	schedules := []LinkSchedule{
+		{ID: "1", ApplyAt: now.Add(-2 * time.Hour)},
+		{ID: "2", ApplyAt: now},
+		{ID: "3", ApplyAt: now.Add(-time.Minute)},
+		{ID: "4", ApplyAt: now.Add(-time.Second), AppliedAt: &applied},
+		{ID: "5", ApplyAt: now.Add(time.Minute)},
+	}
+
+	s, ok := LatestDue(schedules, now)
+	assert.True(t, ok)
+	assert.Equal(t, "2", s.ID)
+
+	_, ok = LatestDue(schedules, now.Add(-3*time.Hour))
+	assert.False(t, ok)
+}
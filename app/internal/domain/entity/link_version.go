@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+// LinkVersion is a snapshot of link destination and metadata, versions are numbered from 1 for every link
+type LinkVersion struct {
+	LinkID      string    `json:"link_id"`
+	Version     int       `json:"version"`
+	FullVersion string    `json:"full_version"`
+	Description string    `json:"description"`
+	CreatedBy   string    `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
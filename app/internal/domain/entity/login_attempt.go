@@ -0,0 +1,47 @@
+package entity
+
+import "time"
+
+// Kinds of login attempt counters
+const (
+	LoginAttemptEmail = "email"
+	LoginAttemptIP    = "ip"
+	// LoginAttemptTwoFactor counts wrong second factor codes per email, it is not reset by a correct password
+	LoginAttemptTwoFactor = "two_factor"
+)
+
+// LoginAttempt counts failed logins for an email or a client IP
+type LoginAttempt struct {
+	Kind          string     `json:"kind"`
+	Value         string     `json:"value"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+}
+
+func (a *LoginAttempt) IsLockedAt(now time.Time) bool {
+	return a.LockedUntil != nil && now.Before(*a.LockedUntil)
+}
+
+// LockoutPolicy locks the counter after Threshold failures for BaseDelay,
+// the delay doubles with every next failure up to MaxDelay
+type LockoutPolicy struct {
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// LockDuration returns how long the counter with given number of failures must be locked. Zero means no lock.
+func (p LockoutPolicy) LockDuration(failures int) time.Duration {
+	if p.Threshold <= 0 || failures < p.Threshold {
+		return 0
+	}
+	d := p.BaseDelay
+	for i := p.Threshold; i < failures && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
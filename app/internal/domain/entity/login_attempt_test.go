@@ -0,0 +1,21 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockoutPolicyLockDuration(t *testing.T) {
+	p := LockoutPolicy{Threshold: 3, BaseDelay: time.Minute, MaxDelay: 10 * time.Minute}
+
+	assert.Zero(t, p.LockDuration(2))
+	assert.Equal(t, time.Minute, p.LockDuration(3))
+	assert.Equal(t, 2*time.Minute, p.LockDuration(4))
+	assert.Equal(t, 8*time.Minute, p.LockDuration(6))
+	assert.Equal(t, 10*time.Minute, p.LockDuration(7))
+	assert.Equal(t, 10*time.Minute, p.LockDuration(100))
+
+	assert.Zero(t, LockoutPolicy{}.LockDuration(100))
+}
@@ -0,0 +1,42 @@
+package entity
+
+import "time"
+
+const refreshTokenBytes = 32
+
+// Session is a family of refresh tokens issued for a single login
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (s *Session) IsActiveAt(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+type RefreshToken struct {
+	ID        string
+	SessionID string
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// NewRefreshToken generates random refresh token valid for ttl and returns it with its raw value
+func NewRefreshToken(ttl time.Duration) (RefreshToken, string, error) {
+	raw, err := generateToken(refreshTokenBytes)
+	if err != nil {
+		return RefreshToken{}, "", err
+	}
+	return RefreshToken{
+		TokenHash: HashToken(raw),
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	}, raw, nil
+}
@@ -0,0 +1,15 @@
+package entity
+
+// Stats are global counters shown to admins
+type Stats struct {
+	Users         int64 `json:"users"`
+	DisabledUsers int64 `json:"disabled_users"`
+	DeletedUsers  int64 `json:"deleted_users"`
+	Admins        int64 `json:"admins"`
+	Links         int64 `json:"links"`
+	// Clicks include clicks on links of deleted accounts
+	Clicks        int64 `json:"clicks"`
+	LinksLastDay  int64 `json:"links_last_day"`
+	InactiveLinks int64 `json:"inactive_links"`
+	OpenReports   int64 `json:"open_reports"`
+}
@@ -0,0 +1,23 @@
+package entity
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// HashToken returns hex encoded sha256 of a secret token, only hashes of tokens are stored
+func HashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateToken returns hex encoded random string of n bytes
+func generateToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token due to error %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
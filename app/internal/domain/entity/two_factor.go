@@ -0,0 +1,63 @@
+package entity
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	RecoveryCodesCount = 10
+	recoveryCodeBytes  = 5
+	challengeBytes     = 32
+)
+
+// TOTP is a time-based one-time password secret of a user
+type TOTP struct {
+	UserID    string
+	Secret    string
+	CreatedAt time.Time
+	EnabledAt *time.Time
+	// LastUsedStep is the time step of the last accepted code, codes of this and earlier steps are rejected
+	LastUsedStep int64
+}
+
+func (t *TOTP) IsEnabled() bool {
+	return t.EnabledAt != nil
+}
+
+// GenerateRecoveryCodes returns n raw codes formatted as xxxxx-xxxxx and their hashes
+func GenerateRecoveryCodes(n int) (codes []string, hashes []string, err error) {
+	for i := 0; i < n; i++ {
+		raw, err := generateToken(recoveryCodeBytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		code := fmt.Sprintf("%s-%s", raw[:5], raw[5:])
+		codes = append(codes, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode normalizes code typed by user and hashes it
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	if len(code) == 2*recoveryCodeBytes {
+		code = code[:5] + "-" + code[5:]
+	}
+	return HashToken(code)
+}
+
+// NewChallengeToken returns random token identifying login waiting for the second factor
+func NewChallengeToken() (string, error) {
+	return generateToken(challengeBytes)
+}
+
+// TOTPEnrollment is what user needs to add the secret to an authenticator app
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+	// QRCode is PNG image of URI encoded as data URI
+	QRCode string `json:"qr_code"`
+}
@@ -0,0 +1,22 @@
+package entity
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, hashes, err := GenerateRecoveryCodes(RecoveryCodesCount)
+	require.NoError(t, err)
+	require.Len(t, codes, RecoveryCodesCount)
+
+	for i, code := range codes {
+		assert.Len(t, code, 11)
+		assert.Equal(t, hashes[i], HashRecoveryCode(code))
+		// codes typed without dash or in upper case are accepted too
+		assert.Equal(t, hashes[i], HashRecoveryCode(" "+strings.ToUpper(strings.ReplaceAll(code, "-", ""))))
+	}
+}
@@ -2,8 +2,15 @@ package entity
 
 import (
 	"fmt"
+	"time"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/slava-911/URL-shortener/pkg/password"
+)
+
+// User roles
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
 )
 
 type User struct {
@@ -11,29 +18,54 @@ type User struct {
 	Email    string `json:"email"`
 	Name     string `json:"name"`
 	Password string `json:"-"`
+	// EmailVerifiedAt is nil until the user follows the link sent to the email
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	Role            string     `json:"role"`
+	// DisabledAt is set when admin disables the account, disabled user can not sign in
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
 }
 
-func (u *User) CheckPassword(password string) error {
-	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
-	if err != nil {
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+func (u *User) IsDisabled() bool {
+	return u.DisabledAt != nil
+}
+
+// AuditFields returns fields which changes are recorded in audit log, password is recorded redacted
+func (u *User) AuditFields() map[string]string {
+	return map[string]string{
+		"name":  u.Name,
+		"email": u.Email,
+		"role":  u.Role,
+	}
+}
+
+// UserFilter is a search of users by admin, Query is matched against email and name
+type UserFilter struct {
+	Query  string
+	Limit  int
+	Offset int
+}
+
+func (u *User) CheckPassword(h password.Hasher, password string) error {
+	if err := h.Verify(u.Password, password); err != nil {
 		return fmt.Errorf("password does not match")
 	}
 	return nil
 }
 
-func (u *User) GeneratePasswordHash() error {
-	pwd, err := generatePasswordHash(u.Password)
+// GeneratePasswordHash replaces plain password with its hash
+func (u *User) GeneratePasswordHash(h password.Hasher) error {
+	pwd, err := h.Hash(u.Password)
 	if err != nil {
 		return err
 	}
 	u.Password = pwd
 	return nil
 }
-
-func generatePasswordHash(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
-	if err != nil {
-		return "", fmt.Errorf("failed to hash password due to error %w", err)
-	}
-	return string(hash), nil
-}
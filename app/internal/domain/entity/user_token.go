@@ -0,0 +1,36 @@
+package entity
+
+import "time"
+
+const userTokenBytes = 32
+
+// Purposes of one-time user tokens
+const (
+	TokenPurposeVerifyEmail   = "verify_email"
+	TokenPurposeResetPassword = "reset_password"
+)
+
+// UserToken is a one-time token sent to user by email
+type UserToken struct {
+	ID        string
+	UserID    string
+	Purpose   string
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// NewUserToken generates random token for the purpose valid for ttl and returns it with its raw value
+func NewUserToken(userID, purpose string, ttl time.Duration) (UserToken, string, error) {
+	raw, err := generateToken(userTokenBytes)
+	if err != nil {
+		return UserToken{}, "", err
+	}
+	return UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: HashToken(raw),
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	}, raw, nil
+}
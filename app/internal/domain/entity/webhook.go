@@ -0,0 +1,136 @@
+package entity
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Events webhooks can subscribe to
+const (
+	WebhookLinkCreated = "link.created"
+	WebhookLinkUpdated = "link.updated"
+	WebhookLinkDeleted = "link.deleted"
+	WebhookLinkClicked = "link.clicked"
+	// WebhookPing is sent by test ping only, it can't be subscribed to
+	WebhookPing = "ping"
+)
+
+// Statuses of webhook deliveries
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// Headers of webhook requests
+const (
+	WebhookHeaderID        = "X-Webhook-Id"
+	WebhookHeaderEvent     = "X-Webhook-Event"
+	WebhookHeaderTimestamp = "X-Webhook-Timestamp"
+	WebhookHeaderSignature = "X-Webhook-Signature"
+)
+
+const webhookSecretBytes = 32
+
+var WebhookEvents = []string{WebhookLinkCreated, WebhookLinkUpdated, WebhookLinkDeleted, WebhookLinkClicked}
+
+type Webhook struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"-"`
+	Events      []string  `json:"events"`
+	Description string    `json:"description"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// GenerateSecret sets new random signing secret, it is shown to the user only once
+func (w *Webhook) GenerateSecret() error {
+	secret, err := generateToken(webhookSecretBytes)
+	if err != nil {
+		return err
+	}
+	w.Secret = "whsec_" + secret
+	return nil
+}
+
+func ValidWebhookEvent(event string) bool {
+	return contains(WebhookEvents, event)
+}
+
+// WebhookDelivery is a single event queued for the webhook, Payload is the data of the event
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	WebhookID      string          `json:"webhook_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// Body returns request body of the delivery, the id lets receivers drop deliveries retried after a lost response
+func (d *WebhookDelivery) Body() ([]byte, error) {
+	return json.Marshal(struct {
+		ID        string          `json:"id"`
+		Event     string          `json:"event"`
+		CreatedAt time.Time       `json:"created_at"`
+		Data      json.RawMessage `json:"data"`
+	}{d.ID, d.Event, d.CreatedAt, d.Payload})
+}
+
+// WebhookClick is the data of link.clicked event
+type WebhookClick struct {
+	Link      Link      `json:"link"`
+	Referrer  string    `json:"referrer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	ClickedAt time.Time `json:"clicked_at"`
+}
+
+// SignWebhook returns value of signature header: hex encoded HMAC-SHA256 of "timestamp.body" keyed with the secret.
+// Receivers recompute it and reject old timestamps, so that captured requests can't be replayed.
+func SignWebhook(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookRetryPolicy retries failed delivery after BaseDelay, the delay doubles with every next attempt up to MaxDelay.
+// The delivery fails for good after MaxAttempts attempts.
+type WebhookRetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// RetryDelay returns the delay before the next attempt of the delivery failed attempts times, false means give up
+func (p WebhookRetryPolicy) RetryDelay(attempts int) (time.Duration, bool) {
+	if attempts >= p.MaxAttempts {
+		return 0, false
+	}
+	d := p.BaseDelay
+	for i := 1; i < attempts && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d, true
+}
+
+// WebhookTask is a pending delivery claimed by the sender together with its webhook
+type WebhookTask struct {
+	Webhook  Webhook
+	Delivery WebhookDelivery
+}
@@ -0,0 +1,56 @@
+package entity
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignWebhook(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	body := []byte(`{"event":"ping"}`)
+
+	sig := SignWebhook("secret", ts, body)
+	// echo -n '1700000000.{"event":"ping"}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t, "sha256=4d39bd2442f073b6bc62e95d0297ce25475582a17389ab860abdc778fe1d9f77", sig)
+	assert.NotEqual(t, sig, SignWebhook("other", ts, body))
+	assert.NotEqual(t, sig, SignWebhook("secret", ts.Add(time.Second), body))
+}
+
+func TestWebhookRetryPolicy(t *testing.T) {
+	p := WebhookRetryPolicy{MaxAttempts: 5, BaseDelay: time.Minute, MaxDelay: 5 * time.Minute}
+
+	tests := []struct {
+		attempts int
+		delay    time.Duration
+		retry    bool
+	}{
+		{1, time.Minute, true},
+		{2, 2 * time.Minute, true},
+		{3, 4 * time.Minute, true},
+		{4, 5 * time.Minute, true},
+		{5, 0, false},
+	}
+	for _, tt := range tests {
+		d, retry := p.RetryDelay(tt.attempts)
+		assert.Equal(t, tt.delay, d, "attempts %d", tt.attempts)
+		assert.Equal(t, tt.retry, retry, "attempts %d", tt.attempts)
+	}
+}
+
+func TestWebhookDeliveryBody(t *testing.T) {
+	d := WebhookDelivery{
+		ID:        "d1",
+		Event:     WebhookLinkCreated,
+		Payload:   json.RawMessage(`{"id":"l1"}`),
+		CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	body, err := d.Body()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":"d1","event":"link.created","created_at":"2024-01-02T03:04:05Z","data":{"id":"l1"}}`,
+		string(body))
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/mailer"
+	"github.com/slava-911/URL-shortener/pkg/password"
+)
+
+type accountService struct {
+	users     interf.UserStorage
+	tokens    interf.UserTokenStorage
+	sessions  interf.SessionService
+	guard     interf.LoginGuardService
+	mailer    mailer.Mailer
+	hasher    password.Hasher
+	tx        interf.Transactor
+	audit     interf.AuditService
+	verifyURL string
+	resetURL  string
+	verifyTTL time.Duration
+	resetTTL  time.Duration
+	logger    *logging.Logger
+}
+
+// NewAccountService creates service for email verification and password reset.
+// Tokens sent by email are appended to verifyURL and resetURL.
+func NewAccountService(users interf.UserStorage, tokens interf.UserTokenStorage, sessions interf.SessionService,
+	guard interf.LoginGuardService, m mailer.Mailer, hasher password.Hasher, tx interf.Transactor, audit interf.AuditService,
+	verifyURL, resetURL string, verifyTTL, resetTTL time.Duration, logger *logging.Logger) interf.AccountService {
+	return &accountService{
+		users:     users,
+		tokens:    tokens,
+		sessions:  sessions,
+		guard:     guard,
+		mailer:    m,
+		hasher:    hasher,
+		tx:        tx,
+		audit:     audit,
+		verifyURL: verifyURL,
+		resetURL:  resetURL,
+		verifyTTL: verifyTTL,
+		resetTTL:  resetTTL,
+		logger:    logger,
+	}
+}
+
+func (s *accountService) SendVerification(ctx context.Context, u entity.User) error {
+	if u.EmailVerifiedAt != nil {
+		return apperror.BadRequestError("email is already verified")
+	}
+
+	token, err := s.createToken(ctx, u.ID, entity.TokenPurposeVerifyEmail, s.verifyTTL)
+	if err != nil {
+		return err
+	}
+
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      u.Email,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf("Hello, %s!\n\nTo confirm your email follow the link:\n%s%s\n\n"+
+			"The link is valid for %s. If you did not sign up, ignore this message.\n", u.Name, s.verifyURL, token, s.verifyTTL),
+	})
+	if err != nil {
+		s.logger.Error(err)
+		return fmt.Errorf("failed to send verification email, error: %w", err)
+	}
+
+	return nil
+}
+
+func (s *accountService) VerifyEmail(ctx context.Context, token string) error {
+	t, err := s.useToken(ctx, token, entity.TokenPurposeVerifyEmail)
+	if err != nil {
+		return err
+	}
+
+	if err = s.users.VerifyEmail(ctx, t.UserID); err != nil {
+		s.logger.Error(err)
+		return fmt.Errorf("failed to verify email, error: %w", err)
+	}
+
+	return nil
+}
+
+// passwordResetTimeout limits sending of reset link, which outlives the request
+const passwordResetTimeout = 30 * time.Second
+
+// RequestPasswordReset sends reset link in background if the user exists, unknown emails are silently ignored.
+// It returns at once, so that neither response time nor mailer failures tell whether the email is registered,
+// errors are logged.
+func (s *accountService) RequestPasswordReset(ctx context.Context, email string) error {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), passwordResetTimeout)
+		defer cancel()
+
+		if err := s.sendPasswordReset(ctx, email); err != nil {
+			s.logger.Errorf("failed to send password reset email due to error %v", err)
+		}
+	}()
+
+	return nil
+}
+
+func (s *accountService) sendPasswordReset(ctx context.Context, email string) error {
+	u, err := s.users.FindOneByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			s.logger.Debugf("password reset requested for unknown email %s", email)
+			return nil
+		}
+		return fmt.Errorf("failed to find user by email, error: %w", err)
+	}
+
+	token, err := s.createToken(ctx, u.ID, entity.TokenPurposeResetPassword, s.resetTTL)
+	if err != nil {
+		return err
+	}
+
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      u.Email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf("Hello, %s!\n\nTo set a new password follow the link:\n%s%s\n\n"+
+			"The link is valid for %s. If you did not request password reset, ignore this message.\n", u.Name, s.resetURL, token, s.resetTTL),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send mail, error: %w", err)
+	}
+
+	return nil
+}
+
+// ResetPassword sets new password and revokes all sessions of the user.
+// Following the link proves the email ownership, so email becomes verified and its login lockout is lifted.
+func (s *accountService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	t, err := s.useToken(ctx, token, entity.TokenPurposeResetPassword)
+	if err != nil {
+		return err
+	}
+
+	u, err := s.users.FindOneByID(ctx, t.UserID)
+	if err != nil {
+		s.logger.Error(err)
+		return fmt.Errorf("failed to find user by id, error: %w", err)
+	}
+
+	u.Password = newPassword
+	if err = u.GeneratePasswordHash(s.hasher); err != nil {
+		return fmt.Errorf("failed to reset password, error %w", err)
+	}
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		chFields := map[string]string{"password": u.Password}
+		if err := s.users.Update(ctx, u.ID, chFields); err != nil {
+			s.logger.Error(err)
+			return fmt.Errorf("failed to reset password, error: %w", err)
+		}
+		if err := s.users.VerifyEmail(ctx, u.ID); err != nil {
+			s.logger.Error(err)
+			return fmt.Errorf("failed to verify email, error: %w", err)
+		}
+		return s.audit.Record(ctx, entity.AuditUserPasswordReset, entity.AuditTargetUser, u.ID,
+			entity.AuditDiff(nil, chFields))
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.Debug("revoke all sessions after password reset")
+	if err = s.sessions.RevokeAllByUserID(ctx, u.ID); err != nil {
+		return err
+	}
+
+	if err = s.guard.Unlock(ctx, entity.LoginAttemptEmail, u.Email); err != nil && !errors.Is(err, apperror.ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *accountService) createToken(ctx context.Context, userID, purpose string, ttl time.Duration) (string, error) {
+	t, raw, err := entity.NewUserToken(userID, purpose, ttl)
+	if err != nil {
+		s.logger.Error(err)
+		return "", err
+	}
+
+	if err = s.tokens.Create(ctx, t); err != nil {
+		s.logger.Error(err)
+		return "", fmt.Errorf("failed to create %s token, error: %w", purpose, err)
+	}
+
+	return raw, nil
+}
+
+// useToken returns apperror.BadRequestError for unknown, expired or already used tokens
+func (s *accountService) useToken(ctx context.Context, token, purpose string) (entity.UserToken, error) {
+	t, err := s.tokens.Use(ctx, entity.HashToken(token), purpose)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return t, apperror.BadRequestError("token is invalid or expired")
+		}
+		s.logger.Error(err)
+		return t, fmt.Errorf("failed to use %s token, error: %w", purpose, err)
+	}
+
+	return t, nil
+}
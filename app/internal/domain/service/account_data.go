@@ -0,0 +1,195 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+)
+
+const (
+	// exportPage is the page size click and audit events are exported with
+	exportPage = 1000
+	// deletionBatch limits accounts deleted in one run of the job
+	deletionBatch = 10
+)
+
+type accountDataService struct {
+	users     interf.UserService
+	storage   interf.UserStorage
+	links     interf.LinkService
+	clicks    interf.ClickEventStorage
+	deletions interf.AccountDeletionStorage
+	sessions  interf.SessionService
+	tx        interf.Transactor
+	audit     interf.AuditService
+	logger    *logging.Logger
+}
+
+func NewAccountDataService(users interf.UserService, storage interf.UserStorage, links interf.LinkService,
+	clicks interf.ClickEventStorage, deletions interf.AccountDeletionStorage, sessions interf.SessionService,
+	tx interf.Transactor, audit interf.AuditService, logger *logging.Logger) interf.AccountDataService {
+	return &accountDataService{
+		users:     users,
+		storage:   storage,
+		links:     links,
+		clicks:    clicks,
+		deletions: deletions,
+		sessions:  sessions,
+		tx:        tx,
+		audit:     audit,
+		logger:    logger,
+	}
+}
+
+// Export writes profile.json, links.json, click_events.jsonl and audit_events.jsonl into zip archive
+func (s *accountDataService) Export(ctx context.Context, userID string, w io.Writer) error {
+	u, err := s.users.GetOneByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	links, err := s.links.GetAllByUserID(ctx, userID)
+	if err != nil && !errors.Is(err, apperror.ErrNotFound) {
+		return err
+	}
+	trash, err := s.links.GetTrashByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	if err = writeZipJSON(zw, "profile.json", u); err != nil {
+		return err
+	}
+	if err = writeZipJSON(zw, "links.json", append(links, trash...)); err != nil {
+		return err
+	}
+
+	f, err := zw.Create("click_events.jsonl")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	var afterID int64
+	for {
+		events, err := s.clicks.FindAllByUserID(ctx, userID, afterID, exportPage)
+		if err != nil {
+			s.logger.Error(err)
+			return fmt.Errorf("failed to find click events, error: %w", err)
+		}
+		for _, e := range events {
+			if err = enc.Encode(e); err != nil {
+				return err
+			}
+		}
+		if len(events) < exportPage {
+			break
+		}
+		afterID = events[len(events)-1].ID
+	}
+
+	if f, err = zw.Create("audit_events.jsonl"); err != nil {
+		return err
+	}
+	enc = json.NewEncoder(f)
+	af := entity.AuditFilter{UserID: userID, Limit: exportPage}
+	for ; ; af.Offset += exportPage {
+		events, err := s.audit.GetAll(ctx, af)
+		if err != nil {
+			return err
+		}
+		for _, e := range events {
+			if err = enc.Encode(e); err != nil {
+				return err
+			}
+		}
+		if len(events) < exportPage {
+			break
+		}
+	}
+
+	return zw.Close()
+}
+
+func writeZipJSON(zw *zip.Writer, name string, v interface{}) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// RequestDeletion disables the account at once and leaves the deletion to ProcessDeletions
+func (s *accountDataService) RequestDeletion(ctx context.Context, userID string) error {
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.storage.SetDisabled(ctx, userID, true); err != nil {
+			s.logger.Error(err)
+			if errors.Is(err, apperror.ErrNotFound) {
+				return err
+			}
+			return fmt.Errorf("failed to disable user, error: %w", err)
+		}
+		if err := s.deletions.Create(ctx, userID); err != nil {
+			s.logger.Error(err)
+			return fmt.Errorf("failed to request account deletion, error: %w", err)
+		}
+		return s.audit.Record(ctx, entity.AuditUserDeleteRequest, entity.AuditTargetUser, userID, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.Debug("revoke all sessions of user waiting for deletion")
+	return s.sessions.RevokeAllByUserID(ctx, userID)
+}
+
+// ProcessDeletions deletes accounts waiting for deletion and returns their number.
+// Clicks on their links stay anonymized and totals of the links are kept, so that global stats do not drop.
+// Audit events of the user are kept without client data and personal fields.
+func (s *accountDataService) ProcessDeletions(ctx context.Context) (deleted int, err error) {
+	for deleted < deletionBatch {
+		done := false
+		err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+			d, err := s.deletions.FindOnePending(ctx)
+			if err != nil {
+				if errors.Is(err, apperror.ErrNotFound) {
+					done = true
+					return nil
+				}
+				s.logger.Error(err)
+				return fmt.Errorf("failed to find pending account deletion, error: %w", err)
+			}
+
+			if d, err = s.deletions.Complete(ctx, d.UserID); err != nil {
+				s.logger.Error(err)
+				return fmt.Errorf("failed to anonymize account data, error: %w", err)
+			}
+			if err = s.users.Delete(ctx, d.UserID); err != nil && !errors.Is(err, apperror.ErrNotFound) {
+				return err
+			}
+			if err = s.audit.AnonymizeUser(ctx, d.UserID); err != nil {
+				return err
+			}
+
+			s.logger.Infof("account %s requested at %s is deleted with %d links", d.UserID,
+				d.RequestedAt.Format(time.RFC3339), d.Links)
+			return nil
+		})
+		if err != nil || done {
+			return deleted, err
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
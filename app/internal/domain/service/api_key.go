@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+)
+
+type apiKeyService struct {
+	storage interf.APIKeyStorage
+	logger  *logging.Logger
+}
+
+func NewAPIKeyService(storage interf.APIKeyStorage, logger *logging.Logger) interf.APIKeyService {
+	return &apiKeyService{
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+func (s *apiKeyService) Create(ctx context.Context, k entity.APIKey) (rawKey string, key entity.APIKey, err error) {
+	s.logger.Debug("generate api key")
+	rawKey, err = k.GenerateKey()
+	if err != nil {
+		s.logger.Error(err)
+		return "", k, err
+	}
+
+	key, err = s.storage.Create(ctx, k)
+	if err != nil {
+		s.logger.Error(err)
+		return "", key, fmt.Errorf("failed to create api key, error: %w", err)
+	}
+
+	return rawKey, key, nil
+}
+
+func (s *apiKeyService) GetAllByUserID(ctx context.Context, userID string) (keys []entity.APIKey, err error) {
+	keys, err = s.storage.FindAllByUserID(ctx, userID)
+	if err != nil {
+		s.logger.Error(err)
+		return keys, fmt.Errorf("failed to get api keys by user id %s, error: %w", userID, err)
+	}
+
+	return keys, nil
+}
+
+func (s *apiKeyService) Revoke(ctx context.Context, id, userID string) error {
+	if err := s.storage.Revoke(ctx, id, userID); err != nil {
+		s.logger.Error(err)
+		if errors.Is(err, apperror.ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to revoke api key, error: %w", err)
+	}
+
+	return nil
+}
+
+func (s *apiKeyService) Authenticate(ctx context.Context, rawKey string) (k entity.APIKey, err error) {
+	if !strings.HasPrefix(rawKey, entity.APIKeyPrefix) {
+		return k, apperror.ErrUnauthorized
+	}
+
+	k, err = s.storage.FindOneByHash(ctx, entity.HashToken(rawKey))
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return k, apperror.ErrUnauthorized
+		}
+		s.logger.Error(err)
+		return k, fmt.Errorf("failed to find api key, error: %w", err)
+	}
+
+	if !k.IsActiveAt(time.Now()) {
+		s.logger.Debugf("api key %s is revoked or expired", k.Prefix)
+		return k, apperror.ErrUnauthorized
+	}
+
+	if err = s.storage.UpdateLastUsed(ctx, k.ID); err != nil {
+		s.logger.Errorf("failed to update api key last usage due to error %v", err)
+	}
+
+	return k, nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+)
+
+type auditService struct {
+	storage interf.AuditStorage
+	logger  *logging.Logger
+}
+
+func NewAuditService(storage interf.AuditStorage, logger *logging.Logger) interf.AuditService {
+	return &auditService{
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+// Record takes the actor from user_id set by auth middleware and the client from request info
+func (s *auditService) Record(ctx context.Context, action, targetType, targetID string,
+	changes map[string]entity.AuditChange) error {
+	ri := entity.RequestInfoFromContext(ctx)
+	e := entity.AuditEvent{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Changes:    changes,
+		ActorID:    actorFromContext(ctx),
+		IP:         ri.IP,
+		UserAgent:  ri.UserAgent,
+	}
+
+	if err := s.storage.Create(ctx, e); err != nil {
+		s.logger.Error(err)
+		return fmt.Errorf("failed to record audit event, error: %w", err)
+	}
+
+	return nil
+}
+
+func (s *auditService) GetAll(ctx context.Context, f entity.AuditFilter) (events []entity.AuditEvent, err error) {
+	events, err = s.storage.FindAll(ctx, f)
+	if err != nil {
+		s.logger.Error(err)
+		return events, fmt.Errorf("failed to find audit events, error: %w", err)
+	}
+
+	return events, nil
+}
+
+func (s *auditService) AnonymizeUser(ctx context.Context, userID string) error {
+	n, err := s.storage.AnonymizeUser(ctx, userID)
+	if err != nil {
+		s.logger.Error(err)
+		return fmt.Errorf("failed to anonymize audit events, error: %w", err)
+	}
+	s.logger.Debugf("%d audit events of user %s are anonymized", n, userID)
+
+	return nil
+}
+
+// actorFromContext returns id of the signed-in user or empty string for changes made without one
+func actorFromContext(ctx context.Context) string {
+	actorID, _ := ctx.Value("user_id").(string)
+	return actorID
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+)
+
+type identityService struct {
+	storage interf.IdentityStorage
+	users   interf.UserStorage
+	logger  *logging.Logger
+}
+
+func NewIdentityService(storage interf.IdentityStorage, users interf.UserStorage, logger *logging.Logger) interf.IdentityService {
+	return &identityService{
+		storage: storage,
+		users:   users,
+		logger:  logger,
+	}
+}
+
+func (s *identityService) Login(ctx context.Context, i entity.Identity, emailVerified bool, name string) (u entity.User, err error) {
+	linked, err := s.storage.FindOne(ctx, i.Provider, i.Subject)
+	if err == nil {
+		u, err = s.users.FindOneByID(ctx, linked.UserID)
+		if err != nil {
+			s.logger.Error(err)
+			return u, fmt.Errorf("failed to find user by id, error: %w", err)
+		}
+		return u, nil
+	}
+	if !errors.Is(err, apperror.ErrNotFound) {
+		s.logger.Error(err)
+		return u, fmt.Errorf("failed to find identity, error: %w", err)
+	}
+
+	// accounts are matched by email only when the provider has verified it
+	if i.Email == "" || !emailVerified {
+		return u, apperror.BadRequestError("email is not verified by identity provider")
+	}
+
+	u, err = s.users.FindOneByEmail(ctx, i.Email)
+	switch {
+	case errors.Is(err, apperror.ErrNotFound):
+		s.logger.Infof("create user %s signed in with %s", i.Email, i.Provider)
+		if name == "" {
+			name, _, _ = strings.Cut(i.Email, "@")
+		}
+		// user without password can set it with password reset
+		u, err = s.users.Create(ctx, entity.User{Name: name, Email: i.Email})
+		if err != nil {
+			s.logger.Error(err)
+			return u, fmt.Errorf("failed to create user, error: %w", err)
+		}
+	case err != nil:
+		s.logger.Error(err)
+		return u, fmt.Errorf("failed to find user by email, error: %w", err)
+	}
+
+	if u.EmailVerifiedAt == nil {
+		if err = s.users.VerifyEmail(ctx, u.ID); err != nil {
+			s.logger.Error(err)
+			return u, fmt.Errorf("failed to verify email, error: %w", err)
+		}
+	}
+
+	s.logger.Infof("link %s identity %s to user %s", i.Provider, i.Subject, u.ID)
+	i.UserID = u.ID
+	if _, err = s.storage.Create(ctx, i); err != nil {
+		s.logger.Error(err)
+		return u, fmt.Errorf("failed to link identity, error: %w", err)
+	}
+
+	return u, nil
+}
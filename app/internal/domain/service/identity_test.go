@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeIdentities struct {
+	linked map[string]entity.Identity
+}
+
+func (f *fakeIdentities) Create(_ context.Context, i entity.Identity) (entity.Identity, error) {
+	f.linked[i.Provider+"/"+i.Subject] = i
+	return i, nil
+}
+
+func (f *fakeIdentities) FindOne(_ context.Context, provider, subject string) (entity.Identity, error) {
+	i, ok := f.linked[provider+"/"+subject]
+	if !ok {
+		return i, apperror.ErrNotFound
+	}
+	return i, nil
+}
+
+// fakeUsers implements only methods used by identityService
+type fakeUsers struct {
+	interf.UserStorage
+	users map[string]entity.User
+}
+
+func (f *fakeUsers) Create(_ context.Context, u entity.User) (entity.User, error) {
+	u.ID = fmt.Sprintf("user-%d", len(f.users)+1)
+	f.users[u.ID] = u
+	return u, nil
+}
+
+func (f *fakeUsers) FindOneByEmail(_ context.Context, email string) (entity.User, error) {
+	for _, u := range f.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return entity.User{}, apperror.ErrNotFound
+}
+
+func (f *fakeUsers) FindOneByID(_ context.Context, id string) (entity.User, error) {
+	u, ok := f.users[id]
+	if !ok {
+		return u, apperror.ErrNotFound
+	}
+	return u, nil
+}
+
+func (f *fakeUsers) VerifyEmail(_ context.Context, id string) error {
+	u := f.users[id]
+	now := time.Now()
+	u.EmailVerifiedAt = &now
+	f.users[id] = u
+	return nil
+}
+
+func TestIdentityServiceLogin(t *testing.T) {
+	verifiedAt := time.Now()
+	existing := entity.User{ID: "user-1", Email: "user@example.com", Name: "user", EmailVerifiedAt: &verifiedAt}
+
+	tests := []struct {
+		name          string
+		linked        []entity.Identity
+		users         []entity.User
+		identity      entity.Identity
+		emailVerified bool
+		userName      string
+		wantErr       bool
+		wantUser      entity.User
+		wantCount     int
+	}{
+		{
+			name:      "linked identity",
+			linked:    []entity.Identity{{UserID: "user-1", Provider: "oidc", Subject: "sub"}},
+			users:     []entity.User{existing},
+			identity:  entity.Identity{Provider: "oidc", Subject: "sub", Email: "other@example.com"},
+			wantUser:  existing,
+			wantCount: 1,
+		},
+		{
+			name:     "unverified email",
+			users:    []entity.User{existing},
+			identity: entity.Identity{Provider: "oidc", Subject: "sub", Email: "user@example.com"},
+			wantErr:  true,
+		},
+		{
+			name:          "empty email",
+			identity:      entity.Identity{Provider: "oidc", Subject: "sub"},
+			emailVerified: true,
+			wantErr:       true,
+		},
+		{
+			name:          "existing user by email",
+			users:         []entity.User{existing},
+			identity:      entity.Identity{Provider: "oidc", Subject: "sub", Email: "user@example.com"},
+			emailVerified: true,
+			wantUser:      existing,
+			wantCount:     1,
+		},
+		{
+			name:          "new user named by email",
+			identity:      entity.Identity{Provider: "oidc", Subject: "sub", Email: "new@example.com"},
+			emailVerified: true,
+			wantUser:      entity.User{ID: "user-1", Email: "new@example.com", Name: "new"},
+			wantCount:     1,
+		},
+		{
+			name:          "new user with provider name",
+			identity:      entity.Identity{Provider: "oidc", Subject: "sub", Email: "new@example.com"},
+			emailVerified: true,
+			userName:      "New User",
+			wantUser:      entity.User{ID: "user-1", Email: "new@example.com", Name: "New User"},
+			wantCount:     1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identities := &fakeIdentities{linked: map[string]entity.Identity{}}
+			for _, i := range tt.linked {
+				identities.linked[i.Provider+"/"+i.Subject] = i
+			}
+			users := &fakeUsers{users: map[string]entity.User{}}
+			for _, u := range tt.users {
+				users.users[u.ID] = u
+			}
+			s := NewIdentityService(identities, users, logging.GetLogger("panic"))
+
+			u, err := s.Login(context.Background(), tt.identity, tt.emailVerified, tt.userName)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Empty(t, identities.linked)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantUser.ID, u.ID)
+			assert.Equal(t, tt.wantUser.Email, u.Email)
+			assert.Equal(t, tt.wantUser.Name, u.Name)
+			assert.Len(t, identities.linked, tt.wantCount)
+			assert.Equal(t, u.ID, identities.linked["oidc/sub"].UserID)
+			assert.NotNil(t, users.users[u.ID].EmailVerifiedAt)
+		})
+	}
+}
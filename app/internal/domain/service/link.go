@@ -4,38 +4,89 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/slava-911/URL-shortener/internal/apperror"
 	"github.com/slava-911/URL-shortener/internal/domain/entity"
 	"github.com/slava-911/URL-shortener/internal/interf"
 	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/tracing"
+	"go.opentelemetry.io/otel"
+)
+
+// tracer starts spans of link and user service methods
+var tracer = otel.Tracer("github.com/slava-911/URL-shortener/internal/domain/service")
+
+const (
+	// dueSchedulesBatch limits schedules applied in one transaction
+	dueSchedulesBatch = 100
+	// exportBatch is the page size links are exported with
+	exportBatch = 500
+	// shortVersionAttempts limits generation of short version when generated one is taken or retired
+	shortVersionAttempts = 5
 )
 
 type linkService struct {
-	storage interf.LinkStorage
-	logger  *logging.Logger
+	storage   interf.LinkStorage
+	versions  interf.LinkVersionStorage
+	schedules interf.LinkScheduleStorage
+	tx        interf.Transactor
+	audit     interf.AuditService
+	webhooks  interf.WebhookService
+	logger    *logging.Logger
 }
 
-func NewLinkService(storage interf.LinkStorage, logger *logging.Logger) interf.LinkService {
+func NewLinkService(storage interf.LinkStorage, versions interf.LinkVersionStorage, schedules interf.LinkScheduleStorage,
+	tx interf.Transactor, audit interf.AuditService, webhooks interf.WebhookService,
+	logger *logging.Logger) interf.LinkService {
 	return &linkService{
-		storage: storage,
-		logger:  logger,
+		storage:   storage,
+		versions:  versions,
+		schedules: schedules,
+		tx:        tx,
+		audit:     audit,
+		webhooks:  webhooks,
+		logger:    logger,
 	}
 }
 
 func (s *linkService) Create(ctx context.Context, l entity.Link) (linkID string, err error) {
-	l.GenerateShortVersion(7)
+	ctx, span := tracer.Start(ctx, "linkService.Create")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
 
-	linkID, err = s.storage.Create(ctx, l)
-	if err != nil {
-		s.logger.Error(err)
-		return linkID, fmt.Errorf("failed to create link, error: %w", err)
-	}
+	for attempt := 1; ; attempt++ {
+		l.GenerateShortVersion(7)
 
-	return linkID, nil
+		err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+			linkID, err = s.storage.Create(ctx, l)
+			if err != nil {
+				if errors.Is(err, apperror.ErrShortTaken) {
+					return err
+				}
+				s.logger.Error(err)
+				return fmt.Errorf("failed to create link, error: %w", err)
+			}
+			l.ID, l.Status = linkID, entity.LinkStatusActive
+			if err = s.saveVersion(ctx, l, l.UserID); err != nil {
+				return err
+			}
+			if err = s.audit.Record(ctx, entity.AuditLinkCreate, entity.AuditTargetLink, linkID,
+				entity.AuditDiff(nil, l.AuditFields())); err != nil {
+				return err
+			}
+			return s.publishCreated(ctx, linkID)
+		})
+		if !errors.Is(err, apperror.ErrShortTaken) || attempt == shortVersionAttempts {
+			return linkID, err
+		}
+		s.logger.Debugf("short version %s is taken or retired, generate another one", l.ShortVersion)
+	}
 }
 
 func (s *linkService) GetAllByUserID(ctx context.Context, id string) (links []entity.Link, err error) {
+	ctx, span := tracer.Start(ctx, "linkService.GetAllByUserID")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
 	links, err = s.storage.FindAllByUserID(ctx, id)
 	if err != nil {
 		s.logger.Error(err)
@@ -49,6 +100,9 @@ func (s *linkService) GetAllByUserID(ctx context.Context, id string) (links []en
 }
 
 func (s *linkService) GetOneByID(ctx context.Context, id string) (l entity.Link, err error) {
+	ctx, span := tracer.Start(ctx, "linkService.GetOneByID")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
 	l, err = s.storage.FindOneByID(ctx, id)
 	if err != nil {
 		s.logger.Error(err)
@@ -61,40 +115,441 @@ func (s *linkService) GetOneByID(ctx context.Context, id string) (l entity.Link,
 	return l, nil
 }
 
-func (s *linkService) Update(ctx context.Context, id string, chFields map[string]string) error {
-	err := s.storage.Update(ctx, id, chFields)
+func (s *linkService) Update(ctx context.Context, id string, chFields map[string]string) (err error) {
+	ctx, span := tracer.Start(ctx, "linkService.Update")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := s.GetOneByID(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if err = s.storage.Update(ctx, id, chFields); err != nil {
+			s.logger.Error(err)
+			if errors.Is(err, apperror.ErrNotFound) {
+				return err
+			}
+			return fmt.Errorf("failed to update link, error: %w", err)
+		}
+
+		after, err := s.GetOneByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if after.FullVersion != before.FullVersion || after.Description != before.Description {
+			if err = s.saveVersion(ctx, after, actorFromContext(ctx)); err != nil {
+				return err
+			}
+		}
+
+		if err = s.audit.Record(ctx, entity.AuditLinkUpdate, entity.AuditTargetLink, id,
+			entity.AuditDiff(before.AuditFields(), chFields)); err != nil {
+			return err
+		}
+		return s.webhooks.Publish(ctx, after.UserID, entity.WebhookLinkUpdated, after)
+	})
+}
+
+func (s *linkService) Delete(ctx context.Context, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "linkService.Delete")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := s.GetOneByID(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if err = s.storage.Delete(ctx, id); err != nil {
+			s.logger.Error(err)
+			if errors.Is(err, apperror.ErrNotFound) {
+				return err
+			}
+			return fmt.Errorf("failed to delete link, error: %w", err)
+		}
+
+		if err = s.audit.Record(ctx, entity.AuditLinkDelete, entity.AuditTargetLink, before.ID, nil); err != nil {
+			return err
+		}
+		return s.webhooks.Publish(ctx, before.UserID, entity.WebhookLinkDeleted, before)
+	})
+}
+
+// GetFullVersionByShortVersion returns apperror.ErrLinkDisabled or apperror.ErrLinkTakenDown for inactive links
+func (s *linkService) GetFullVersionByShortVersion(ctx context.Context, shortVersion string,
+	click entity.ClickEvent) (fv string, err error) {
+	ctx, span := tracer.Start(ctx, "linkService.GetFullVersionByShortVersion")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	click = click.Truncated()
+	l, err := s.storage.FindFullVersionByShortVersion(ctx, shortVersion, click)
 	if err != nil {
 		s.logger.Error(err)
 		if errors.Is(err, apperror.ErrNotFound) {
+			return "", err
+		}
+		return "", fmt.Errorf("failed to find link by short version, error: %w", err)
+	}
+
+	switch l.Status {
+	case entity.LinkStatusDisabled:
+		return "", apperror.ErrLinkDisabled
+	case entity.LinkStatusTakenDown:
+		return "", apperror.ErrLinkTakenDown
+	}
+
+	// the redirect must not fail because of webhooks, the click is already counted anyway
+	err = s.webhooks.Publish(ctx, l.UserID, entity.WebhookLinkClicked, entity.WebhookClick{
+		Link:      l,
+		Referrer:  click.Referrer,
+		UserAgent: click.UserAgent,
+		ClickedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		s.logger.Errorf("failed to publish click on link %s due to error %v", l.ID, err)
+	}
+
+	return l.FullVersion, nil
+}
+
+func (s *linkService) GetAll(ctx context.Context, f entity.LinkFilter) (links []entity.Link, err error) {
+	ctx, span := tracer.Start(ctx, "linkService.GetAll")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	links, err = s.storage.FindAll(ctx, f)
+	if err != nil {
+		s.logger.Error(err)
+		return links, fmt.Errorf("failed to find links, error: %w", err)
+	}
+
+	return links, nil
+}
+
+func (s *linkService) SetStatus(ctx context.Context, id, status string) (err error) {
+	ctx, span := tracer.Start(ctx, "linkService.SetStatus")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := s.GetOneByID(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if err = s.storage.SetStatus(ctx, id, status); err != nil {
+			s.logger.Error(err)
+			if errors.Is(err, apperror.ErrNotFound) {
+				return err
+			}
+			return fmt.Errorf("failed to set link status, error: %w", err)
+		}
+
+		if err = s.audit.Record(ctx, entity.AuditLinkStatus, entity.AuditTargetLink, id,
+			entity.AuditDiff(before.AuditFields(), map[string]string{"status": status})); err != nil {
 			return err
 		}
-		return fmt.Errorf("failed to update link, error: %w", err)
+		after := before
+		after.Status = status
+		return s.webhooks.Publish(ctx, after.UserID, entity.WebhookLinkUpdated, after)
+	})
+}
+
+func (s *linkService) GetTrashByUserID(ctx context.Context, userID string) (links []entity.Link, err error) {
+	ctx, span := tracer.Start(ctx, "linkService.GetTrashByUserID")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	links, err = s.storage.FindAllDeletedByUserID(ctx, userID)
+	if err != nil {
+		s.logger.Error(err)
+		return links, fmt.Errorf("failed to get deleted links by user id %s, error: %w", userID, err)
+	}
+
+	return links, nil
+}
+
+func (s *linkService) Restore(ctx context.Context, id, userID string) (err error) {
+	ctx, span := tracer.Start(ctx, "linkService.Restore")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.storage.Restore(ctx, id, userID); err != nil {
+			s.logger.Error(err)
+			if errors.Is(err, apperror.ErrNotFound) {
+				return err
+			}
+			return fmt.Errorf("failed to restore link, error: %w", err)
+		}
+
+		return s.audit.Record(ctx, entity.AuditLinkRestore, entity.AuditTargetLink, id, nil)
+	})
+}
+
+// PurgeTrash permanently deletes links which are in trash longer than retention
+func (s *linkService) PurgeTrash(ctx context.Context, retention time.Duration) (_ int64, err error) {
+	ctx, span := tracer.Start(ctx, "linkService.PurgeTrash")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	n, err := s.storage.PurgeDeleted(ctx, time.Now().UTC().Add(-retention))
+	if err != nil {
+		s.logger.Error(err)
+		return n, fmt.Errorf("failed to purge deleted links, error: %w", err)
+	}
+
+	return n, nil
+}
+
+func (s *linkService) saveVersion(ctx context.Context, l entity.Link, createdBy string) error {
+	_, err := s.versions.Create(ctx, entity.LinkVersion{
+		LinkID:      l.ID,
+		FullVersion: l.FullVersion,
+		Description: l.Description,
+		CreatedBy:   createdBy,
+	})
+	if err != nil {
+		s.logger.Error(err)
+		return fmt.Errorf("failed to save link version, error: %w", err)
 	}
 
 	return nil
 }
 
-func (s *linkService) Delete(ctx context.Context, id string) error {
-	if err := s.storage.Delete(ctx, id); err != nil {
+// GetHistory returns versions of the link from the latest one
+func (s *linkService) GetHistory(ctx context.Context, id string) (versions []entity.LinkVersion, err error) {
+	ctx, span := tracer.Start(ctx, "linkService.GetHistory")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	versions, err = s.versions.FindAllByLinkID(ctx, id)
+	if err != nil {
+		s.logger.Error(err)
+		return versions, fmt.Errorf("failed to find link versions, error: %w", err)
+	}
+
+	return versions, nil
+}
+
+// RestoreVersion rolls the link back to destination and description of the version, rollback is a new version itself
+func (s *linkService) RestoreVersion(ctx context.Context, id string, version int) (err error) {
+	ctx, span := tracer.Start(ctx, "linkService.RestoreVersion")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	v, err := s.versions.FindOne(ctx, id, version)
+	if err != nil {
 		s.logger.Error(err)
 		if errors.Is(err, apperror.ErrNotFound) {
 			return err
 		}
-		return fmt.Errorf("failed to delete user, error: %w", err)
+		return fmt.Errorf("failed to find link version, error: %w", err)
 	}
 
-	return nil
+	return s.Update(ctx, id, map[string]string{
+		"full_version": v.FullVersion,
+		"description":  v.Description,
+	})
+}
+
+// CreateSchedule plans destination change of the link, ApplyAt must be in the future
+func (s *linkService) CreateSchedule(ctx context.Context, ls entity.LinkSchedule) (_ entity.LinkSchedule, err error) {
+	ctx, span := tracer.Start(ctx, "linkService.CreateSchedule")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	ls.ApplyAt = ls.ApplyAt.UTC()
+	if !ls.ApplyAt.After(time.Now().UTC()) {
+		return ls, apperror.BadRequestError("apply_at must be in the future")
+	}
+	ls.CreatedBy = actorFromContext(ctx)
+
+	ls, err = s.schedules.Create(ctx, ls)
+	if err != nil {
+		s.logger.Error(err)
+		return ls, fmt.Errorf("failed to create link schedule, error: %w", err)
+	}
+
+	return ls, nil
 }
 
-func (s *linkService) GetFullVersionByShortVersion(ctx context.Context, shortVersion string) (fv string, err error) {
-	fv, err = s.storage.FindFullVersionByShortVersion(ctx, shortVersion)
+func (s *linkService) GetSchedules(ctx context.Context, id string) (schedules []entity.LinkSchedule, err error) {
+	ctx, span := tracer.Start(ctx, "linkService.GetSchedules")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	schedules, err = s.schedules.FindAllByLinkID(ctx, id)
 	if err != nil {
+		s.logger.Error(err)
+		return schedules, fmt.Errorf("failed to find link schedules, error: %w", err)
+	}
+
+	return schedules, nil
+}
+
+func (s *linkService) DeleteSchedule(ctx context.Context, scheduleID, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "linkService.DeleteSchedule")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	if err := s.schedules.Delete(ctx, scheduleID, id); err != nil {
 		s.logger.Error(err)
 		if errors.Is(err, apperror.ErrNotFound) {
-			return fv, err
+			return err
 		}
-		return fv, fmt.Errorf("failed to find link by short version, error: %w", err)
+		return fmt.Errorf("failed to delete link schedule, error: %w", err)
 	}
 
-	return fv, nil
+	return nil
+}
+
+// ApplyDueSchedules sets destinations of links to their latest due schedules and returns number of updated links.
+// Schedules of links deleted meanwhile are marked applied without effect, schedules of links changed after
+// they were due are cancelled.
+func (s *linkService) ApplyDueSchedules(ctx context.Context) (updated int, err error) {
+	ctx, span := tracer.Start(ctx, "linkService.ApplyDueSchedules")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		now := time.Now().UTC()
+		stale, err := s.schedules.DeleteStale(ctx, now)
+		if err != nil {
+			s.logger.Error(err)
+			return fmt.Errorf("failed to cancel stale link schedules, error: %w", err)
+		}
+		if stale > 0 {
+			s.logger.Infof("%d link schedules are cancelled, their links were changed after they were due", stale)
+		}
+
+		due, err := s.schedules.FindDue(ctx, now, dueSchedulesBatch)
+		if err != nil {
+			s.logger.Error(err)
+			return fmt.Errorf("failed to find due link schedules, error: %w", err)
+		}
+		if len(due) == 0 {
+			return nil
+		}
+
+		byLink := make(map[string][]entity.LinkSchedule)
+		ids := make([]string, 0, len(due))
+		for _, ls := range due {
+			byLink[ls.LinkID] = append(byLink[ls.LinkID], ls)
+			ids = append(ids, ls.ID)
+		}
+
+		for linkID, schedules := range byLink {
+			latest, _ := entity.LatestDue(schedules, now)
+			err = s.Update(ctx, linkID, map[string]string{"full_version": latest.FullVersion})
+			if err != nil {
+				if errors.Is(err, apperror.ErrNotFound) {
+					continue
+				}
+				return err
+			}
+			updated++
+		}
+
+		if err = s.schedules.MarkApplied(ctx, ids); err != nil {
+			s.logger.Error(err)
+			return fmt.Errorf("failed to mark link schedules applied, error: %w", err)
+		}
+
+		return nil
+	})
+
+	return updated, err
+}
+
+// Export passes links matching the filter to write page by page, f.Limit is the page size
+func (s *linkService) Export(ctx context.Context, f entity.LinkFilter, write func(l entity.Link) error) (err error) {
+	ctx, span := tracer.Start(ctx, "linkService.Export")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	if f.Limit <= 0 {
+		f.Limit = exportBatch
+	}
+	for {
+		links, err := s.GetAll(ctx, f)
+		if err != nil {
+			return err
+		}
+		for _, l := range links {
+			if err = write(l); err != nil {
+				return err
+			}
+		}
+		if len(links) < f.Limit {
+			return nil
+		}
+		f.Offset += f.Limit
+	}
+}
+
+// Import creates links of the user keeping their short versions and creation time. Clicks of the file are not
+// imported, they can not be verified and would count in global stats, imported links count clicks from zero.
+// Rows which short version is taken or retired are skipped or get a generated one depending on onConflict.
+func (s *linkService) Import(ctx context.Context, userID string, rows []entity.LinkImportRow,
+	onConflict string) (res entity.LinkImportResult, err error) {
+	ctx, span := tracer.Start(ctx, "linkService.Import")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	for _, row := range rows {
+		l := row.Link
+		l.UserID = userID
+		l.Clicked = 0
+		requested := l.ShortVersion
+
+		if requested != "" && !entity.ValidShortVersion(requested) {
+			res.Errors = append(res.Errors, entity.LinkImportIssue{Line: row.Line, ShortVersion: requested,
+				Reason: "short version may contain only letters, digits, - and _"})
+			continue
+		}
+		if requested == "" {
+			l.GenerateShortVersion(7)
+		}
+
+		err = s.createImported(ctx, l)
+		if errors.Is(err, apperror.ErrShortTaken) {
+			issue := entity.LinkImportIssue{Line: row.Line, ShortVersion: l.ShortVersion, Reason: "short version is taken"}
+			if requested == "" || onConflict != entity.LinkImportSkip {
+				l.GenerateShortVersion(7)
+				if err = s.createImported(ctx, l); err == nil {
+					issue.AssignedShortVersion = l.ShortVersion
+				}
+			}
+			res.Conflicts = append(res.Conflicts, issue)
+		}
+		if errors.Is(err, apperror.ErrShortTaken) {
+			continue
+		}
+		if err != nil {
+			return res, err
+		}
+		res.Imported++
+	}
+
+	return res, nil
+}
+
+func (s *linkService) createImported(ctx context.Context, l entity.Link) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		linkID, err := s.storage.CreateImported(ctx, l)
+		if err != nil {
+			s.logger.Error(err)
+			if errors.Is(err, apperror.ErrShortTaken) {
+				return err
+			}
+			return fmt.Errorf("failed to import link, error: %w", err)
+		}
+		l.ID, l.Status = linkID, entity.LinkStatusActive
+		if err = s.saveVersion(ctx, l, l.UserID); err != nil {
+			return err
+		}
+		if err = s.audit.Record(ctx, entity.AuditLinkCreate, entity.AuditTargetLink, linkID,
+			entity.AuditDiff(nil, l.AuditFields())); err != nil {
+			return err
+		}
+		return s.publishCreated(ctx, linkID)
+	})
+}
+
+// publishCreated queues link.created event with the link as stored, including its creation time
+func (s *linkService) publishCreated(ctx context.Context, id string) error {
+	l, err := s.GetOneByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.webhooks.Publish(ctx, l.UserID, entity.WebhookLinkCreated, l)
 }
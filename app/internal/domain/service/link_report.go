@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+)
+
+type linkReportService struct {
+	storage     interf.LinkReportStorage
+	linkStorage interf.LinkStorage
+	links       interf.LinkService
+	tx          interf.Transactor
+	logger      *logging.Logger
+}
+
+func NewLinkReportService(storage interf.LinkReportStorage, linkStorage interf.LinkStorage, links interf.LinkService,
+	tx interf.Transactor, logger *logging.Logger) interf.LinkReportService {
+	return &linkReportService{
+		storage:     storage,
+		linkStorage: linkStorage,
+		links:       links,
+		tx:          tx,
+		logger:      logger,
+	}
+}
+
+// Report saves abuse report for the link, links which are already taken down can not be reported
+func (s *linkReportService) Report(ctx context.Context, shortVersion, reason, details, ip string) (r entity.LinkReport, err error) {
+	l, err := s.linkStorage.FindOneByShortVersion(ctx, shortVersion)
+	if err != nil {
+		s.logger.Error(err)
+		if errors.Is(err, apperror.ErrNotFound) {
+			return r, err
+		}
+		return r, fmt.Errorf("failed to find link by short version, error: %w", err)
+	}
+	if l.Status == entity.LinkStatusTakenDown {
+		return r, apperror.ErrLinkTakenDown
+	}
+
+	r, err = s.storage.Create(ctx, entity.LinkReport{
+		LinkID:       l.ID,
+		ShortVersion: l.ShortVersion,
+		Reason:       reason,
+		Details:      details,
+		ReporterIP:   ip,
+	})
+	if err != nil {
+		s.logger.Error(err)
+		return r, fmt.Errorf("failed to create link report, error: %w", err)
+	}
+	s.logger.Warnf("link %s is reported for %s", l.ID, reason)
+
+	return r, nil
+}
+
+func (s *linkReportService) GetAll(ctx context.Context, f entity.LinkReportFilter) (reports []entity.LinkReport, err error) {
+	reports, err = s.storage.FindAll(ctx, f)
+	if err != nil {
+		s.logger.Error(err)
+		return reports, fmt.Errorf("failed to find link reports, error: %w", err)
+	}
+
+	return reports, nil
+}
+
+// Resolve applies resolution to the reported link and closes all its open reports
+func (s *linkReportService) Resolve(ctx context.Context, id, resolution string) error {
+	r, err := s.storage.FindOneByID(ctx, id)
+	if err != nil {
+		s.logger.Error(err)
+		if errors.Is(err, apperror.ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to find link report, error: %w", err)
+	}
+	if r.ResolvedAt != nil {
+		return apperror.BadRequestError("report is already resolved")
+	}
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if resolution != entity.ReportResolutionDismissed {
+			if err = s.links.SetStatus(ctx, r.LinkID, resolution); err != nil {
+				return err
+			}
+		}
+
+		if err = s.storage.ResolveAllByLinkID(ctx, r.LinkID, resolution); err != nil {
+			s.logger.Error(err)
+			return fmt.Errorf("failed to resolve link reports, error: %w", err)
+		}
+
+		return nil
+	})
+}
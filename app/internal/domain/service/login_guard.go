@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+)
+
+type loginGuardService struct {
+	storage     interf.LoginAttemptStorage
+	notifier    interf.LockoutNotifier
+	emailPolicy entity.LockoutPolicy
+	ipPolicy    entity.LockoutPolicy
+	resetAfter  time.Duration
+	logger      *logging.Logger
+}
+
+// NewLoginGuardService creates guard which counts failed logins per email and per client ip.
+// Counters are forgotten after resetAfter without failures.
+func NewLoginGuardService(storage interf.LoginAttemptStorage, notifier interf.LockoutNotifier,
+	emailPolicy, ipPolicy entity.LockoutPolicy, resetAfter time.Duration, logger *logging.Logger) interf.LoginGuardService {
+	return &loginGuardService{
+		storage:     storage,
+		notifier:    notifier,
+		emailPolicy: emailPolicy,
+		ipPolicy:    ipPolicy,
+		resetAfter:  resetAfter,
+		logger:      logger,
+	}
+}
+
+// Check returns apperror.ErrTooManyRequests if either email, second factor of the email or ip is locked
+func (s *loginGuardService) Check(ctx context.Context, email, ip string) error {
+	now := time.Now().UTC()
+	for kind, value := range map[string]string{
+		entity.LoginAttemptEmail:     email,
+		entity.LoginAttemptTwoFactor: email,
+		entity.LoginAttemptIP:        ip,
+	} {
+		a, err := s.storage.FindOne(ctx, kind, value)
+		if err != nil {
+			if errors.Is(err, apperror.ErrNotFound) {
+				continue
+			}
+			s.logger.Error(err)
+			return fmt.Errorf("failed to find login attempts, error: %w", err)
+		}
+		if a.IsLockedAt(now) {
+			s.logger.Warnf("login for %s %s is locked until %s", kind, value, a.LockedUntil)
+			return apperror.ErrTooManyRequests
+		}
+	}
+
+	return nil
+}
+
+func (s *loginGuardService) RegisterFailure(ctx context.Context, email, ip string) error {
+	if err := s.registerFailure(ctx, entity.LoginAttemptEmail, email, s.emailPolicy); err != nil {
+		return err
+	}
+	return s.registerFailure(ctx, entity.LoginAttemptIP, ip, s.ipPolicy)
+}
+
+// RegisterTwoFactorFailure counts wrong second factor codes separately from passwords, so that signing in
+// with the right password does not reset them and every new challenge does not give more guesses
+func (s *loginGuardService) RegisterTwoFactorFailure(ctx context.Context, email, ip string) error {
+	if err := s.registerFailure(ctx, entity.LoginAttemptTwoFactor, email, s.emailPolicy); err != nil {
+		return err
+	}
+	return s.registerFailure(ctx, entity.LoginAttemptIP, ip, s.ipPolicy)
+}
+
+func (s *loginGuardService) registerFailure(ctx context.Context, kind, value string, p entity.LockoutPolicy) error {
+	now := time.Now().UTC()
+	a, err := s.storage.IncrementFailures(ctx, kind, value, now.Add(-s.resetAfter))
+	if err != nil {
+		s.logger.Error(err)
+		return fmt.Errorf("failed to register login failure, error: %w", err)
+	}
+
+	d := p.LockDuration(a.Failures)
+	if d == 0 {
+		return nil
+	}
+
+	until := now.Add(d)
+	if err = s.storage.Lock(ctx, kind, value, until); err != nil {
+		s.logger.Error(err)
+		return fmt.Errorf("failed to lock login, error: %w", err)
+	}
+	a.LockedUntil = &until
+
+	s.logger.Warnf("login for %s %s is locked for %s after %d failures", kind, value, d, a.Failures)
+	if err = s.notifier.NotifyLockout(ctx, a); err != nil {
+		// lock is already applied, failed notification must not break login
+		s.logger.Errorf("failed to notify about lockout due to error %v", err)
+	}
+
+	return nil
+}
+
+// RegisterSuccess resets email counter, ip counter is kept to slow down attackers owning an account
+func (s *loginGuardService) RegisterSuccess(ctx context.Context, email string) error {
+	err := s.storage.Delete(ctx, entity.LoginAttemptEmail, email)
+	if err != nil && !errors.Is(err, apperror.ErrNotFound) {
+		s.logger.Error(err)
+		return fmt.Errorf("failed to reset login attempts, error: %w", err)
+	}
+
+	return nil
+}
+
+// RegisterTwoFactorSuccess resets second factor counter of the email
+func (s *loginGuardService) RegisterTwoFactorSuccess(ctx context.Context, email string) error {
+	err := s.storage.Delete(ctx, entity.LoginAttemptTwoFactor, email)
+	if err != nil && !errors.Is(err, apperror.ErrNotFound) {
+		s.logger.Error(err)
+		return fmt.Errorf("failed to reset two-factor attempts, error: %w", err)
+	}
+
+	return nil
+}
+
+func (s *loginGuardService) GetLocked(ctx context.Context) ([]entity.LoginAttempt, error) {
+	attempts, err := s.storage.FindAllLocked(ctx)
+	if err != nil {
+		s.logger.Error(err)
+		return attempts, fmt.Errorf("failed to find locked logins, error: %w", err)
+	}
+
+	return attempts, nil
+}
+
+func (s *loginGuardService) Unlock(ctx context.Context, kind, value string) error {
+	if err := s.storage.Delete(ctx, kind, value); err != nil {
+		s.logger.Error(err)
+		if errors.Is(err, apperror.ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to unlock login, error: %w", err)
+	}
+
+	return nil
+}
+
+// Purge deletes counters which are forgotten anyway, that is without failures for resetAfter and not locked
+func (s *loginGuardService) Purge(ctx context.Context) (int64, error) {
+	now := time.Now().UTC()
+	n, err := s.storage.PurgeExpired(ctx, now.Add(-s.resetAfter), now)
+	if err != nil {
+		s.logger.Error(err)
+		return n, fmt.Errorf("failed to purge login attempts, error: %w", err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+)
+
+type sessionService struct {
+	storage         interf.SessionStorage
+	denylist        interf.TokenDenylist
+	refreshTokenTTL time.Duration
+	logger          *logging.Logger
+}
+
+func NewSessionService(storage interf.SessionStorage, denylist interf.TokenDenylist, refreshTokenTTL time.Duration,
+	logger *logging.Logger) interf.SessionService {
+	return &sessionService{
+		storage:         storage,
+		denylist:        denylist,
+		refreshTokenTTL: refreshTokenTTL,
+		logger:          logger,
+	}
+}
+
+func (s *sessionService) Create(ctx context.Context, userID, userAgent, ip string) (sess entity.Session, refreshToken string, err error) {
+	t, refreshToken, err := entity.NewRefreshToken(s.refreshTokenTTL)
+	if err != nil {
+		s.logger.Error(err)
+		return sess, "", err
+	}
+
+	sess, err = s.storage.Create(ctx, entity.Session{
+		UserID:    userID,
+		UserAgent: userAgent,
+		IP:        ip,
+		ExpiresAt: t.ExpiresAt,
+	}, t)
+	if err != nil {
+		s.logger.Error(err)
+		return sess, "", fmt.Errorf("failed to create session, error: %w", err)
+	}
+
+	return sess, refreshToken, nil
+}
+
+// Refresh exchanges refresh token for a new one of the same session.
+// Exchanging already used token revokes the whole session, because one of its tokens has leaked.
+func (s *sessionService) Refresh(ctx context.Context, refreshToken string) (sess entity.Session, newToken string, err error) {
+	old, err := s.storage.FindRefreshTokenByHash(ctx, entity.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return sess, "", apperror.ErrUnauthorized
+		}
+		s.logger.Error(err)
+		return sess, "", fmt.Errorf("failed to find refresh token, error: %w", err)
+	}
+
+	sess, err = s.storage.FindOneByID(ctx, old.SessionID)
+	if err != nil {
+		s.logger.Error(err)
+		return sess, "", fmt.Errorf("failed to find session, error: %w", err)
+	}
+
+	now := time.Now()
+	if !sess.IsActiveAt(now) {
+		s.logger.Debugf("session %s is revoked or expired", sess.ID)
+		return sess, "", apperror.ErrUnauthorized
+	}
+	if old.UsedAt != nil {
+		return sess, "", s.revokeReused(ctx, sess)
+	}
+	if !now.Before(old.ExpiresAt) {
+		return sess, "", apperror.ErrUnauthorized
+	}
+
+	t, newToken, err := entity.NewRefreshToken(s.refreshTokenTTL)
+	if err != nil {
+		s.logger.Error(err)
+		return sess, "", err
+	}
+	if err = s.storage.RotateRefreshToken(ctx, old.ID, t); err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			// token has been used concurrently
+			return sess, "", s.revokeReused(ctx, sess)
+		}
+		s.logger.Error(err)
+		return sess, "", fmt.Errorf("failed to rotate refresh token, error: %w", err)
+	}
+	sess.ExpiresAt = t.ExpiresAt
+
+	return sess, newToken, nil
+}
+
+func (s *sessionService) revokeReused(ctx context.Context, sess entity.Session) error {
+	s.logger.Warnf("refresh token reuse detected, revoke session %s of user %s", sess.ID, sess.UserID)
+	if err := s.Revoke(ctx, sess.ID, sess.UserID); err != nil && !errors.Is(err, apperror.ErrNotFound) {
+		return err
+	}
+	return apperror.ErrUnauthorized
+}
+
+func (s *sessionService) GetAllByUserID(ctx context.Context, userID string) (sessions []entity.Session, err error) {
+	sessions, err = s.storage.FindAllActiveByUserID(ctx, userID)
+	if err != nil {
+		s.logger.Error(err)
+		return sessions, fmt.Errorf("failed to get sessions by user id %s, error: %w", userID, err)
+	}
+
+	return sessions, nil
+}
+
+func (s *sessionService) Revoke(ctx context.Context, id, userID string) error {
+	if err := s.storage.Revoke(ctx, id, userID); err != nil {
+		s.logger.Error(err)
+		if errors.Is(err, apperror.ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to revoke session, error: %w", err)
+	}
+
+	if err := s.denylist.RevokeSession(id); err != nil {
+		s.logger.Error(err)
+		return fmt.Errorf("failed to revoke session access tokens, error: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeAllByUserID logs user out everywhere: revokes all sessions and every access token issued so far
+func (s *sessionService) RevokeAllByUserID(ctx context.Context, userID string) error {
+	if err := s.storage.RevokeAllByUserID(ctx, userID); err != nil {
+		s.logger.Error(err)
+		return fmt.Errorf("failed to revoke user sessions, error: %w", err)
+	}
+
+	if err := s.denylist.RevokeUser(ctx, userID); err != nil {
+		s.logger.Error(err)
+		return fmt.Errorf("failed to revoke user access tokens, error: %w", err)
+	}
+
+	return nil
+}
+
+func (s *sessionService) Logout(ctx context.Context, userID, sessionID, tokenID string, tokenExpiresAt time.Time) error {
+	if err := s.denylist.RevokeToken(tokenID, tokenExpiresAt); err != nil {
+		s.logger.Error(err)
+		return fmt.Errorf("failed to revoke access token, error: %w", err)
+	}
+
+	if sessionID == "" {
+		return nil
+	}
+	if err := s.Revoke(ctx, sessionID, userID); err != nil && !errors.Is(err, apperror.ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+)
+
+type statsService struct {
+	storage interf.StatsStorage
+	logger  *logging.Logger
+}
+
+func NewStatsService(storage interf.StatsStorage, logger *logging.Logger) interf.StatsService {
+	return &statsService{
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+func (s *statsService) Get(ctx context.Context) (st entity.Stats, err error) {
+	st, err = s.storage.Get(ctx)
+	if err != nil {
+		s.logger.Error(err)
+		return st, fmt.Errorf("failed to get stats, error: %w", err)
+	}
+
+	return st, nil
+}
@@ -0,0 +1,349 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/png"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/cache"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/password"
+)
+
+const (
+	challengeKeyPrefix   = "2fa_challenge:"
+	maxChallengeAttempts = 5
+	totpPeriod           = 30
+	totpSkew             = 1
+	totpQRCodeSize       = 256
+	totpCodeLength       = 6
+)
+
+var errInvalidTOTPCode = apperror.BadRequestError("invalid two-factor authentication code")
+
+var totpOpts = totp.ValidateOpts{
+	Period:    totpPeriod,
+	Skew:      totpSkew,
+	Digits:    otp.DigitsSix,
+	Algorithm: otp.AlgorithmSHA1,
+}
+
+type twoFactorService struct {
+	storage      interf.TwoFactorStorage
+	users        interf.UserStorage
+	hasher       password.Hasher
+	guard        interf.LoginGuardService
+	challenges   cache.Repository
+	issuer       string
+	challengeTTL time.Duration
+	logger       *logging.Logger
+}
+
+// challenge is a login waiting for the second factor
+type challenge struct {
+	UserID    string    `json:"user_id"`
+	Attempts  int       `json:"attempts"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// NewTwoFactorService creates TOTP service, issuer is shown in authenticator apps,
+// login challenges are kept in cache for challengeTTL. Wrong codes on login are counted by guard
+// like wrong passwords, so that the account is locked regardless of the number of challenges.
+func NewTwoFactorService(storage interf.TwoFactorStorage, users interf.UserStorage, hasher password.Hasher,
+	guard interf.LoginGuardService, challenges cache.Repository, issuer string,
+	challengeTTL time.Duration, logger *logging.Logger) interf.TwoFactorService {
+	return &twoFactorService{
+		storage:      storage,
+		users:        users,
+		hasher:       hasher,
+		guard:        guard,
+		challenges:   challenges,
+		issuer:       issuer,
+		challengeTTL: challengeTTL,
+		logger:       logger,
+	}
+}
+
+// Enroll generates new secret, it starts to be required on login only after Enable
+func (s *twoFactorService) Enroll(ctx context.Context, u entity.User) (e entity.TOTPEnrollment, err error) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      s.issuer,
+		AccountName: u.Email,
+		Period:      totpPeriod,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		s.logger.Error(err)
+		return e, fmt.Errorf("failed to generate totp secret, error: %w", err)
+	}
+
+	if err = s.storage.SaveSecret(ctx, u.ID, key.Secret()); err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return e, apperror.BadRequestError("two-factor authentication is already enabled")
+		}
+		s.logger.Error(err)
+		return e, fmt.Errorf("failed to save totp secret, error: %w", err)
+	}
+
+	img, err := key.Image(totpQRCodeSize, totpQRCodeSize)
+	if err != nil {
+		s.logger.Error(err)
+		return e, fmt.Errorf("failed to generate qr code, error: %w", err)
+	}
+	var buf bytes.Buffer
+	if err = png.Encode(&buf, img); err != nil {
+		s.logger.Error(err)
+		return e, fmt.Errorf("failed to encode qr code, error: %w", err)
+	}
+
+	return entity.TOTPEnrollment{
+		Secret: key.Secret(),
+		URI:    key.URL(),
+		QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}, nil
+}
+
+// Enable confirms enrollment with the code from authenticator app and returns recovery codes
+func (s *twoFactorService) Enable(ctx context.Context, userID, code string) ([]string, error) {
+	t, err := s.storage.FindOneByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return nil, apperror.BadRequestError("two-factor authentication enrollment is not started")
+		}
+		s.logger.Error(err)
+		return nil, fmt.Errorf("failed to find totp secret, error: %w", err)
+	}
+	if t.IsEnabled() {
+		return nil, apperror.BadRequestError("two-factor authentication is already enabled")
+	}
+
+	step, ok := matchStep(t.Secret, code, time.Now())
+	if !ok {
+		return nil, errInvalidTOTPCode
+	}
+
+	codes, hashes, err := entity.GenerateRecoveryCodes(entity.RecoveryCodesCount)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, err
+	}
+	if err = s.storage.Enable(ctx, userID, step, hashes); err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return nil, apperror.BadRequestError("two-factor authentication is already enabled")
+		}
+		s.logger.Error(err)
+		return nil, fmt.Errorf("failed to enable two-factor authentication, error: %w", err)
+	}
+
+	return codes, nil
+}
+
+// Disable requires the current password besides the code, so that a stolen session is not enough
+func (s *twoFactorService) Disable(ctx context.Context, userID, pwd, code string) error {
+	u, err := s.users.FindOneByID(ctx, userID)
+	if err != nil {
+		s.logger.Error(err)
+		if errors.Is(err, apperror.ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to find user, error: %w", err)
+	}
+	if err = u.CheckPassword(s.hasher, pwd); err != nil {
+		return apperror.BadRequestError("password does not match current password")
+	}
+
+	if err = s.verifyEnabled(ctx, userID, code); err != nil {
+		return err
+	}
+
+	if err := s.storage.Delete(ctx, userID); err != nil {
+		s.logger.Error(err)
+		return fmt.Errorf("failed to disable two-factor authentication, error: %w", err)
+	}
+
+	return nil
+}
+
+func (s *twoFactorService) RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error) {
+	if err := s.verifyEnabled(ctx, userID, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := entity.GenerateRecoveryCodes(entity.RecoveryCodesCount)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, err
+	}
+	if err = s.storage.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		s.logger.Error(err)
+		return nil, fmt.Errorf("failed to replace recovery codes, error: %w", err)
+	}
+
+	return codes, nil
+}
+
+func (s *twoFactorService) IsEnabled(ctx context.Context, userID string) (bool, error) {
+	t, err := s.storage.FindOneByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return false, nil
+		}
+		s.logger.Error(err)
+		return false, fmt.Errorf("failed to find totp secret, error: %w", err)
+	}
+
+	return t.IsEnabled(), nil
+}
+
+// CreateChallenge returns token to be exchanged for access tokens together with the second factor
+func (s *twoFactorService) CreateChallenge(ctx context.Context, userID string) (string, error) {
+	token, err := entity.NewChallengeToken()
+	if err != nil {
+		s.logger.Error(err)
+		return "", err
+	}
+
+	c := challenge{UserID: userID, ExpiresAt: time.Now().Add(s.challengeTTL)}
+	if err = s.saveChallenge(token, c); err != nil {
+		s.logger.Error(err)
+		return "", fmt.Errorf("failed to save challenge, error: %w", err)
+	}
+
+	return token, nil
+}
+
+// VerifyChallenge checks TOTP or recovery code for the challenge and returns user id.
+// Challenge can be used once and is dropped after too many wrong codes, wrong codes of all challenges
+// of the user are counted by the guard and lock sign in.
+func (s *twoFactorService) VerifyChallenge(ctx context.Context, token, code, ip string) (string, error) {
+	key := []byte(challengeKeyPrefix + entity.HashToken(token))
+	val, err := s.challenges.Get(key)
+	if err != nil {
+		return "", apperror.ErrUnauthorized
+	}
+	var c challenge
+	if err = json.Unmarshal(val, &c); err != nil {
+		s.logger.Error(err)
+		return "", apperror.ErrUnauthorized
+	}
+
+	u, err := s.users.FindOneByID(ctx, c.UserID)
+	if err != nil {
+		s.logger.Error(err)
+		if errors.Is(err, apperror.ErrNotFound) {
+			return "", apperror.ErrUnauthorized
+		}
+		return "", fmt.Errorf("failed to find user, error: %w", err)
+	}
+	if err = s.guard.Check(ctx, u.Email, ip); err != nil {
+		return "", err
+	}
+
+	if err = s.verifyEnabled(ctx, c.UserID, code); err != nil {
+		if errors.Is(err, errInvalidTOTPCode) {
+			if guardErr := s.guard.RegisterTwoFactorFailure(ctx, u.Email, ip); guardErr != nil {
+				return "", guardErr
+			}
+		}
+		if c.Attempts++; c.Attempts >= maxChallengeAttempts {
+			s.logger.Warnf("too many wrong codes for challenge of user %s", c.UserID)
+			s.challenges.Del(key)
+		} else if saveErr := s.saveChallenge(token, c); saveErr != nil {
+			s.logger.Error(saveErr)
+		}
+		if errors.Is(err, errInvalidTOTPCode) {
+			return "", apperror.ErrUnauthorized
+		}
+		return "", err
+	}
+
+	s.challenges.Del(key)
+
+	if err = s.guard.RegisterTwoFactorSuccess(ctx, u.Email); err != nil {
+		return "", err
+	}
+
+	return c.UserID, nil
+}
+
+// verifyEnabled accepts TOTP code or unused recovery code of user with enabled two-factor authentication
+func (s *twoFactorService) verifyEnabled(ctx context.Context, userID, code string) error {
+	t, err := s.storage.FindOneByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return apperror.BadRequestError("two-factor authentication is not enabled")
+		}
+		s.logger.Error(err)
+		return fmt.Errorf("failed to find totp secret, error: %w", err)
+	}
+	if !t.IsEnabled() {
+		return apperror.BadRequestError("two-factor authentication is not enabled")
+	}
+
+	if len(code) != totpCodeLength {
+		err = s.storage.UseRecoveryCode(ctx, userID, entity.HashRecoveryCode(code))
+		if err != nil {
+			if errors.Is(err, apperror.ErrNotFound) {
+				return errInvalidTOTPCode
+			}
+			s.logger.Error(err)
+			return fmt.Errorf("failed to use recovery code, error: %w", err)
+		}
+		s.logger.Infof("recovery code used by user %s", userID)
+		return nil
+	}
+
+	step, ok := matchStep(t.Secret, code, time.Now())
+	if !ok {
+		return errInvalidTOTPCode
+	}
+	if err = s.storage.UseStep(ctx, userID, step); err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			s.logger.Warnf("replayed totp code of user %s", userID)
+			return errInvalidTOTPCode
+		}
+		s.logger.Error(err)
+		return fmt.Errorf("failed to save totp step, error: %w", err)
+	}
+
+	return nil
+}
+
+func (s *twoFactorService) saveChallenge(token string, c challenge) error {
+	ttl := time.Until(c.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	val, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return s.challenges.Set([]byte(challengeKeyPrefix+entity.HashToken(token)), val, int(ttl.Seconds())+1)
+}
+
+// matchStep returns the time step the code was generated for, allowing clock skew of totpSkew steps
+func matchStep(secret, code string, now time.Time) (int64, bool) {
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(step*totpPeriod, 0), totpOpts)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
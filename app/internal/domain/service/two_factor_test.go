@@ -0,0 +1,31 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchStep(t *testing.T) {
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: "test", AccountName: "user@example.com"})
+	require.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	current := now.Unix() / totpPeriod
+
+	for _, shift := range []int64{-1, 0, 1} {
+		code, err := totp.GenerateCodeCustom(key.Secret(), now.Add(time.Duration(shift*totpPeriod)*time.Second), totpOpts)
+		require.NoError(t, err)
+		step, ok := matchStep(key.Secret(), code, now)
+		assert.True(t, ok)
+		assert.Equal(t, current+shift, step)
+	}
+
+	code, err := totp.GenerateCodeCustom(key.Secret(), now.Add(-2*totpPeriod*time.Second), totpOpts)
+	require.NoError(t, err)
+	_, ok := matchStep(key.Secret(), code, now)
+	assert.False(t, ok)
+}
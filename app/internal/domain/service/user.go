@@ -9,23 +9,39 @@ import (
 	"github.com/slava-911/URL-shortener/internal/domain/entity"
 	"github.com/slava-911/URL-shortener/internal/interf"
 	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/slava-911/URL-shortener/pkg/password"
+	"github.com/slava-911/URL-shortener/pkg/tracing"
 )
 
 type userService struct {
-	storage interf.UserStorage
-	logger  *logging.Logger
+	storage  interf.UserStorage
+	sessions interf.SessionService
+	guard    interf.LoginGuardService
+	hasher   password.Hasher
+	tx       interf.Transactor
+	audit    interf.AuditService
+	logger   *logging.Logger
 }
 
-func NewUserService(userStorage interf.UserStorage, logger *logging.Logger) interf.UserService {
+func NewUserService(userStorage interf.UserStorage, sessions interf.SessionService, guard interf.LoginGuardService,
+	hasher password.Hasher, tx interf.Transactor, audit interf.AuditService, logger *logging.Logger) interf.UserService {
 	return &userService{
-		storage: userStorage,
-		logger:  logger,
+		storage:  userStorage,
+		sessions: sessions,
+		guard:    guard,
+		hasher:   hasher,
+		tx:       tx,
+		audit:    audit,
+		logger:   logger,
 	}
 }
 
 func (s *userService) Create(ctx context.Context, u entity.User) (user entity.User, err error) {
+	ctx, span := tracer.Start(ctx, "userService.Create")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
 	s.logger.Debug("generate password hash")
-	if err = u.GeneratePasswordHash(); err != nil {
+	if err = u.GeneratePasswordHash(s.hasher); err != nil {
 		s.logger.Errorf("failed to create user due to error %v", err)
 		return u, err
 	}
@@ -39,24 +55,69 @@ func (s *userService) Create(ctx context.Context, u entity.User) (user entity.Us
 	return user, nil
 }
 
-func (s *userService) GetOneByEmailAndPassword(ctx context.Context, email, password string) (u entity.User, err error) {
+// GetOneByEmailAndPassword checks credentials, failed attempts are counted per email and per ip
+// and lead to temporary lockout returned as apperror.ErrTooManyRequests
+func (s *userService) GetOneByEmailAndPassword(ctx context.Context, email, password, ip string) (u entity.User, err error) {
+	ctx, span := tracer.Start(ctx, "userService.GetOneByEmailAndPassword")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	if err = s.guard.Check(ctx, email, ip); err != nil {
+		return u, err
+	}
+
 	u, err = s.storage.FindOneByEmail(ctx, email)
 	if err != nil {
 		s.logger.Error(err)
 		if errors.Is(err, apperror.ErrNotFound) {
-			return u, err
+			// unknown emails are counted too, so that they can not be told apart from existing ones
+			if err = s.guard.RegisterFailure(ctx, email, ip); err != nil {
+				return u, err
+			}
+			return u, apperror.ErrNotFound
 		}
 		return u, fmt.Errorf("failed to find user by email, error: %w", err)
 	}
 
-	if err = u.CheckPassword(password); err != nil {
+	if err = u.CheckPassword(s.hasher, password); err != nil {
+		if err = s.guard.RegisterFailure(ctx, email, ip); err != nil {
+			return u, err
+		}
 		return u, apperror.ErrNotFound
 	}
 
+	if err = s.guard.RegisterSuccess(ctx, email); err != nil {
+		return u, err
+	}
+
+	if u.IsDisabled() {
+		s.logger.Warnf("disabled user %s tried to sign in", u.ID)
+		return u, apperror.ErrForbidden
+	}
+
+	if s.hasher.NeedsRehash(u.Password) {
+		s.rehashPassword(ctx, u, password)
+	}
+
 	return u, nil
 }
 
+// rehashPassword updates hash made with outdated algorithm or parameters, login does not fail if it is not possible
+func (s *userService) rehashPassword(ctx context.Context, u entity.User, password string) {
+	s.logger.Debugf("rehash password of user %s", u.ID)
+	u.Password = password
+	if err := u.GeneratePasswordHash(s.hasher); err != nil {
+		s.logger.Errorf("failed to rehash password due to error %v", err)
+		return
+	}
+	if err := s.storage.Update(ctx, u.ID, map[string]string{"password": u.Password}); err != nil {
+		s.logger.Errorf("failed to save rehashed password due to error %v", err)
+	}
+}
+
 func (s *userService) GetOneByID(ctx context.Context, id string) (u entity.User, err error) {
+	ctx, span := tracer.Start(ctx, "userService.GetOneByID")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
 	u, err = s.storage.FindOneByID(ctx, id)
 	if err != nil {
 		s.logger.Error(err)
@@ -69,48 +130,257 @@ func (s *userService) GetOneByID(ctx context.Context, id string) (u entity.User,
 	return u, nil
 }
 
-func (s *userService) Update(ctx context.Context, id string, chFields map[string]string, oldPass string) error {
+func (s *userService) Update(ctx context.Context, id string, chFields map[string]string, oldPass string) (err error) {
+	ctx, span := tracer.Start(ctx, "userService.Update")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
 
-	if oldPass != "" {
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
 		s.logger.Debug("get user by uuid")
 		user, err := s.GetOneByID(ctx, id)
 		if err != nil {
 			return err
 		}
+		before := user.AuditFields()
 
-		s.logger.Debug("compare hash current password and old password")
-		if err = user.CheckPassword(oldPass); err != nil {
-			return apperror.BadRequestError("old password does not match current password")
+		if oldPass != "" {
+			s.logger.Debug("compare hash current password and old password")
+			if err = user.CheckPassword(s.hasher, oldPass); err != nil {
+				return apperror.BadRequestError("old password does not match current password")
+			}
+
+			user.Password = chFields["password"]
+
+			s.logger.Debug("generate password hash")
+			if err = user.GeneratePasswordHash(s.hasher); err != nil {
+				return fmt.Errorf("failed to update user, error %w", err)
+			}
+
+			chFields["password"] = user.Password
 		}
 
-		user.Password = chFields["password"]
+		if err = s.storage.Update(ctx, id, chFields); err != nil {
+			s.logger.Error(err)
+			if errors.Is(err, apperror.ErrNotFound) {
+				return err
+			}
+			return fmt.Errorf("failed to update user, error: %w", err)
+		}
+
+		return s.audit.Record(ctx, entity.AuditUserUpdate, entity.AuditTargetUser, id, entity.AuditDiff(before, chFields))
+	})
+	if err != nil {
+		return err
+	}
 
-		s.logger.Debug("generate password hash")
-		if err = user.GeneratePasswordHash(); err != nil {
-			return fmt.Errorf("failed to update user, error %w", err)
+	if oldPass != "" {
+		s.logger.Debug("revoke all sessions after password change")
+		if err = s.sessions.RevokeAllByUserID(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *userService) Delete(ctx context.Context, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "userService.Delete")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := s.GetOneByID(ctx, id)
+		if err != nil {
+			return err
 		}
 
-		chFields["password"] = user.Password
+		if err = s.storage.Delete(ctx, id); err != nil {
+			s.logger.Error(err)
+			if errors.Is(err, apperror.ErrNotFound) {
+				return err
+			}
+			return fmt.Errorf("failed to delete user, error: %w", err)
+		}
+
+		// name and email are not recorded, the account data must not outlive the account
+		return s.audit.Record(ctx, entity.AuditUserDelete, entity.AuditTargetUser, id,
+			entity.AuditDiff(map[string]string{"role": before.Role}, map[string]string{"role": ""}))
+	})
+	if err != nil {
+		return err
 	}
 
-	if err := s.storage.Update(ctx, id, chFields); err != nil {
+	s.logger.Debug("revoke access tokens of deleted user")
+	if err = s.sessions.RevokeAllByUserID(ctx, id); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *userService) GetAll(ctx context.Context, f entity.UserFilter) (users []entity.User, err error) {
+	ctx, span := tracer.Start(ctx, "userService.GetAll")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	users, err = s.storage.FindAll(ctx, f)
+	if err != nil {
 		s.logger.Error(err)
-		if errors.Is(err, apperror.ErrNotFound) {
+		return users, fmt.Errorf("failed to find users, error: %w", err)
+	}
+
+	return users, nil
+}
+
+func (s *userService) SetRole(ctx context.Context, id, role string) (err error) {
+	ctx, span := tracer.Start(ctx, "userService.SetRole")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := s.GetOneByID(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if err = s.storage.SetRole(ctx, id, role); err != nil {
+			s.logger.Error(err)
+			if errors.Is(err, apperror.ErrNotFound) {
+				return err
+			}
+			return fmt.Errorf("failed to set user role, error: %w", err)
+		}
+
+		return s.audit.Record(ctx, entity.AuditUserRole, entity.AuditTargetUser, id,
+			entity.AuditDiff(before.AuditFields(), map[string]string{"role": role}))
+	})
+}
+
+// SetDisabled disables or enables the account, all sessions of disabled user are revoked
+func (s *userService) SetDisabled(ctx context.Context, id string, disabled bool) (err error) {
+	ctx, span := tracer.Start(ctx, "userService.SetDisabled")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	action := entity.AuditUserEnable
+	if disabled {
+		action = entity.AuditUserDisable
+	}
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.storage.SetDisabled(ctx, id, disabled); err != nil {
+			s.logger.Error(err)
+			if errors.Is(err, apperror.ErrNotFound) {
+				return err
+			}
+			return fmt.Errorf("failed to disable user, error: %w", err)
+		}
+
+		return s.audit.Record(ctx, action, entity.AuditTargetUser, id, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	if disabled {
+		s.logger.Debug("revoke all sessions of disabled user")
+		if err = s.sessions.RevokeAllByUserID(ctx, id); err != nil {
 			return err
 		}
-		return fmt.Errorf("failed to update user, error: %w", err)
 	}
 
 	return nil
 }
 
-func (s *userService) Delete(ctx context.Context, id string) error {
-	if err := s.storage.Delete(ctx, id); err != nil {
+func (s *userService) GetOneByEmail(ctx context.Context, email string) (u entity.User, err error) {
+	ctx, span := tracer.Start(ctx, "userService.GetOneByEmail")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	u, err = s.storage.FindOneByEmail(ctx, email)
+	if err != nil {
 		s.logger.Error(err)
 		if errors.Is(err, apperror.ErrNotFound) {
-			return err
+			return u, err
 		}
-		return fmt.Errorf("failed to delete user, error: %w", err)
+		return u, fmt.Errorf("failed to find user by email, error: %w", err)
+	}
+
+	return u, nil
+}
+
+// SetPassword replaces password without the old one, for operators. Sessions are revoked and lockout is lifted.
+func (s *userService) SetPassword(ctx context.Context, id, password string) (err error) {
+	ctx, span := tracer.Start(ctx, "userService.SetPassword")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	u, err := s.GetOneByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	u.Password = password
+	if err = u.GeneratePasswordHash(s.hasher); err != nil {
+		return fmt.Errorf("failed to set password, error %w", err)
+	}
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		chFields := map[string]string{"password": u.Password}
+		if err := s.storage.Update(ctx, id, chFields); err != nil {
+			s.logger.Error(err)
+			if errors.Is(err, apperror.ErrNotFound) {
+				return err
+			}
+			return fmt.Errorf("failed to set password, error: %w", err)
+		}
+		return s.audit.Record(ctx, entity.AuditUserPasswordReset, entity.AuditTargetUser, id,
+			entity.AuditDiff(nil, chFields))
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.Debug("revoke all sessions after password change")
+	if err = s.sessions.RevokeAllByUserID(ctx, id); err != nil {
+		return err
+	}
+
+	if err = s.guard.Unlock(ctx, entity.LoginAttemptEmail, u.Email); err != nil && !errors.Is(err, apperror.ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// EnsureAdmin creates admin account with verified email or grants admin role to existing user with the email.
+// Password of existing user is kept. Existing user with unverified email is not promoted, anyone could have
+// signed up with the address first.
+func (s *userService) EnsureAdmin(ctx context.Context, email, password string) (err error) {
+	ctx, span := tracer.Start(ctx, "userService.EnsureAdmin")
+	defer tracing.End(span, &err, apperror.ErrNotFound)
+
+	u, err := s.storage.FindOneByEmail(ctx, email)
+	if err != nil && !errors.Is(err, apperror.ErrNotFound) {
+		s.logger.Error(err)
+		return fmt.Errorf("failed to find user by email, error: %w", err)
+	}
+
+	if err == nil {
+		if u.IsAdmin() {
+			return nil
+		}
+		if !u.IsEmailVerified() {
+			s.logger.Errorf("admin role is not granted to user %s, email %s is not verified", u.ID, email)
+			return nil
+		}
+		s.logger.Infof("grant admin role to existing user %s", u.ID)
+		return s.SetRole(ctx, u.ID, entity.RoleAdmin)
+	}
+
+	if password == "" {
+		return fmt.Errorf("failed to create admin user %s, error: password is empty", email)
+	}
+	s.logger.Infof("create admin user %s", email)
+	u, err = s.Create(ctx, entity.User{Name: "Administrator", Email: email, Password: password, Role: entity.RoleAdmin})
+	if err != nil {
+		return err
+	}
+	if err = s.storage.VerifyEmail(ctx, u.ID); err != nil {
+		s.logger.Error(err)
+		return fmt.Errorf("failed to verify admin email, error: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,279 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+)
+
+const (
+	// deliveryBatch limits deliveries sent at once by one run of the job
+	deliveryBatch = 20
+	// deliveryErrorMaxLen limits stored error of the attempt
+	deliveryErrorMaxLen = 1000
+)
+
+type webhookService struct {
+	storage    interf.WebhookStorage
+	deliveries interf.WebhookDeliveryStorage
+	sender     interf.WebhookSender
+	retry      entity.WebhookRetryPolicy
+	lease      time.Duration
+	logger     *logging.Logger
+}
+
+// NewWebhookService creates service which queues events of links and sends them to webhooks.
+// Claimed deliveries are hidden from other instances for lease, it must be longer than the send timeout.
+func NewWebhookService(storage interf.WebhookStorage, deliveries interf.WebhookDeliveryStorage,
+	sender interf.WebhookSender, retry entity.WebhookRetryPolicy, lease time.Duration,
+	logger *logging.Logger) interf.WebhookService {
+	return &webhookService{
+		storage:    storage,
+		deliveries: deliveries,
+		sender:     sender,
+		retry:      retry,
+		lease:      lease,
+		logger:     logger,
+	}
+}
+
+func (s *webhookService) Create(ctx context.Context, w entity.Webhook) (entity.Webhook, error) {
+	if err := validateWebhookEvents(w.Events); err != nil {
+		return w, err
+	}
+	if err := s.sender.Validate(ctx, w.URL); err != nil {
+		return w, err
+	}
+	if err := w.GenerateSecret(); err != nil {
+		s.logger.Error(err)
+		return w, err
+	}
+	w.Active = true
+
+	w, err := s.storage.Create(ctx, w)
+	if err != nil {
+		s.logger.Error(err)
+		return w, fmt.Errorf("failed to create webhook, error: %w", err)
+	}
+
+	return w, nil
+}
+
+func (s *webhookService) GetAllByUserID(ctx context.Context, userID string) (webhooks []entity.Webhook, err error) {
+	webhooks, err = s.storage.FindAllByUserID(ctx, userID)
+	if err != nil {
+		s.logger.Error(err)
+		return webhooks, fmt.Errorf("failed to get webhooks by user id %s, error: %w", userID, err)
+	}
+
+	return webhooks, nil
+}
+
+func (s *webhookService) GetOne(ctx context.Context, id, userID string) (w entity.Webhook, err error) {
+	w, err = s.storage.FindOne(ctx, id, userID)
+	if err != nil {
+		s.logger.Error(err)
+		if errors.Is(err, apperror.ErrNotFound) {
+			return w, err
+		}
+		return w, fmt.Errorf("failed to find webhook, error: %w", err)
+	}
+
+	return w, nil
+}
+
+func (s *webhookService) Update(ctx context.Context, w entity.Webhook) error {
+	if err := validateWebhookEvents(w.Events); err != nil {
+		return err
+	}
+	if err := s.sender.Validate(ctx, w.URL); err != nil {
+		return err
+	}
+
+	if err := s.storage.Update(ctx, w); err != nil {
+		s.logger.Error(err)
+		if errors.Is(err, apperror.ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to update webhook, error: %w", err)
+	}
+
+	return nil
+}
+
+func (s *webhookService) Delete(ctx context.Context, id, userID string) error {
+	if err := s.storage.Delete(ctx, id, userID); err != nil {
+		s.logger.Error(err)
+		if errors.Is(err, apperror.ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to delete webhook, error: %w", err)
+	}
+
+	return nil
+}
+
+func (s *webhookService) GetDeliveries(ctx context.Context, id, userID string,
+	limit, offset int) (deliveries []entity.WebhookDelivery, err error) {
+	if _, err = s.GetOne(ctx, id, userID); err != nil {
+		return deliveries, err
+	}
+
+	deliveries, err = s.deliveries.FindAllByWebhookID(ctx, id, limit, offset)
+	if err != nil {
+		s.logger.Error(err)
+		return deliveries, fmt.Errorf("failed to find webhook deliveries, error: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// Ping is sent to inactive webhooks too and is never retried
+func (s *webhookService) Ping(ctx context.Context, id, userID string) (d entity.WebhookDelivery, err error) {
+	w, err := s.GetOne(ctx, id, userID)
+	if err != nil {
+		return d, err
+	}
+
+	payload, err := json.Marshal(struct {
+		WebhookID string `json:"webhook_id"`
+	}{w.ID})
+	if err != nil {
+		return d, err
+	}
+
+	// the delivery is leased, so that the job does not pick it up while it is sent here
+	d, err = s.deliveries.Create(ctx, entity.WebhookDelivery{
+		WebhookID:     w.ID,
+		Event:         entity.WebhookPing,
+		Payload:       payload,
+		Status:        entity.WebhookDeliveryPending,
+		NextAttemptAt: time.Now().UTC().Add(s.lease),
+	})
+	if err != nil {
+		s.logger.Error(err)
+		return d, fmt.Errorf("failed to create webhook delivery, error: %w", err)
+	}
+
+	return s.attempt(ctx, w, d, false), nil
+}
+
+func (s *webhookService) Publish(ctx context.Context, userID, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	n, err := s.deliveries.CreateForEvent(ctx, userID, event, payload)
+	if err != nil {
+		s.logger.Error(err)
+		return fmt.Errorf("failed to queue %s webhook deliveries, error: %w", event, err)
+	}
+	if n > 0 {
+		s.logger.Debugf("%s event is queued for %d webhooks", event, n)
+	}
+
+	return nil
+}
+
+// ProcessDeliveries sends due deliveries concurrently and returns their number.
+// Deliveries of webhooks disabled after the event was queued fail without sending.
+func (s *webhookService) ProcessDeliveries(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+	tasks, err := s.deliveries.Claim(ctx, now, now.Add(s.lease), deliveryBatch)
+	if err != nil {
+		s.logger.Error(err)
+		return 0, fmt.Errorf("failed to claim webhook deliveries, error: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, t := range tasks {
+		if !t.Webhook.Active {
+			t.Delivery.Status, t.Delivery.LastError = entity.WebhookDeliveryFailed, "webhook is disabled"
+			if err = s.deliveries.SaveAttempt(ctx, t.Delivery); err != nil {
+				s.logger.Errorf("failed to save webhook delivery %s due to error %v", t.Delivery.ID, err)
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func(t entity.WebhookTask) {
+			defer wg.Done()
+			s.attempt(ctx, t.Webhook, t.Delivery, true)
+		}(t)
+	}
+	wg.Wait()
+
+	return len(tasks), nil
+}
+
+// attempt sends the delivery and saves the result, failed delivery is rescheduled by retry policy if retry is set
+func (s *webhookService) attempt(ctx context.Context, w entity.Webhook, d entity.WebhookDelivery,
+	retry bool) entity.WebhookDelivery {
+	now := time.Now().UTC()
+	d.Attempts++
+	d.LastAttemptAt = &now
+
+	status, err := s.sender.Send(ctx, w, d)
+	d.ResponseStatus = nil
+	if status != 0 {
+		d.ResponseStatus = &status
+	}
+
+	if err == nil {
+		d.Status, d.LastError = entity.WebhookDeliverySucceeded, ""
+	} else {
+		s.logger.Infof("webhook delivery %s attempt %d failed due to error %v", d.ID, d.Attempts, err)
+		d.LastError = truncateError(err.Error())
+		delay, ok := s.retry.RetryDelay(d.Attempts)
+		if retry && ok {
+			d.NextAttemptAt = now.Add(delay)
+		} else {
+			d.Status = entity.WebhookDeliveryFailed
+		}
+	}
+
+	if err = s.deliveries.SaveAttempt(ctx, d); err != nil {
+		s.logger.Errorf("failed to save webhook delivery %s due to error %v", d.ID, err)
+	}
+
+	return d
+}
+
+// PurgeDeliveries deletes finished deliveries older than retention from the log
+func (s *webhookService) PurgeDeliveries(ctx context.Context, retention time.Duration) (int64, error) {
+	n, err := s.deliveries.PurgeFinished(ctx, time.Now().UTC().Add(-retention))
+	if err != nil {
+		s.logger.Error(err)
+		return n, fmt.Errorf("failed to purge webhook deliveries, error: %w", err)
+	}
+
+	return n, nil
+}
+
+func validateWebhookEvents(events []string) error {
+	if len(events) == 0 {
+		return apperror.BadRequestError("at least one event is required")
+	}
+	for _, e := range events {
+		if !entity.ValidWebhookEvent(e) {
+			return apperror.BadRequestError(fmt.Sprintf("unknown event %s", e))
+		}
+	}
+	return nil
+}
+
+func truncateError(s string) string {
+	if len(s) > deliveryErrorMaxLen {
+		return s[:deliveryErrorMaxLen]
+	}
+	return s
+}
@@ -2,6 +2,8 @@ package interf
 
 import (
 	"context"
+	"io"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/slava-911/URL-shortener/internal/domain/entity"
@@ -16,15 +18,25 @@ type UserStorage interface {
 	FindOneByEmail(ctx context.Context, email string) (entity.User, error)
 	FindOneByID(ctx context.Context, id string) (entity.User, error)
 	Update(ctx context.Context, id string, chFields map[string]string) error
+	VerifyEmail(ctx context.Context, id string) error
 	Delete(ctx context.Context, id string) error
+	FindAll(ctx context.Context, f entity.UserFilter) ([]entity.User, error)
+	SetRole(ctx context.Context, id, role string) error
+	SetDisabled(ctx context.Context, id string, disabled bool) error
 }
 
 type UserService interface {
 	Create(ctx context.Context, u entity.User) (entity.User, error)
-	GetOneByEmailAndPassword(ctx context.Context, email, password string) (entity.User, error)
+	GetOneByEmailAndPassword(ctx context.Context, email, password, ip string) (entity.User, error)
 	GetOneByID(ctx context.Context, id string) (entity.User, error)
 	Update(ctx context.Context, id string, chFields map[string]string, oldPass string) error
 	Delete(ctx context.Context, id string) error
+	GetAll(ctx context.Context, f entity.UserFilter) ([]entity.User, error)
+	SetRole(ctx context.Context, id, role string) error
+	SetDisabled(ctx context.Context, id string, disabled bool) error
+	EnsureAdmin(ctx context.Context, email, password string) error
+	GetOneByEmail(ctx context.Context, email string) (entity.User, error)
+	SetPassword(ctx context.Context, id, password string) error
 }
 
 type LinkStorage interface {
@@ -33,7 +45,14 @@ type LinkStorage interface {
 	FindOneByID(ctx context.Context, id string) (entity.Link, error)
 	Update(ctx context.Context, id string, chFields map[string]string) error
 	Delete(ctx context.Context, id string) error
-	FindFullVersionByShortVersion(ctx context.Context, shortVersion string) (string, error)
+	FindFullVersionByShortVersion(ctx context.Context, shortVersion string, click entity.ClickEvent) (entity.Link, error)
+	FindAll(ctx context.Context, f entity.LinkFilter) ([]entity.Link, error)
+	FindOneByShortVersion(ctx context.Context, shortVersion string) (entity.Link, error)
+	SetStatus(ctx context.Context, id, status string) error
+	FindAllDeletedByUserID(ctx context.Context, userID string) ([]entity.Link, error)
+	Restore(ctx context.Context, id, userID string) error
+	PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int64, error)
+	CreateImported(ctx context.Context, l entity.Link) (string, error)
 }
 
 type LinkService interface {
@@ -42,5 +61,248 @@ type LinkService interface {
 	GetOneByID(ctx context.Context, id string) (entity.Link, error)
 	Update(ctx context.Context, id string, chFields map[string]string) error
 	Delete(ctx context.Context, id string) error
-	GetFullVersionByShortVersion(ctx context.Context, shortVersion string) (string, error)
+	GetFullVersionByShortVersion(ctx context.Context, shortVersion string, click entity.ClickEvent) (string, error)
+	GetAll(ctx context.Context, f entity.LinkFilter) ([]entity.Link, error)
+	SetStatus(ctx context.Context, id, status string) error
+	GetTrashByUserID(ctx context.Context, userID string) ([]entity.Link, error)
+	Restore(ctx context.Context, id, userID string) error
+	PurgeTrash(ctx context.Context, retention time.Duration) (int64, error)
+	GetHistory(ctx context.Context, id string) ([]entity.LinkVersion, error)
+	RestoreVersion(ctx context.Context, id string, version int) error
+	CreateSchedule(ctx context.Context, ls entity.LinkSchedule) (entity.LinkSchedule, error)
+	GetSchedules(ctx context.Context, id string) ([]entity.LinkSchedule, error)
+	DeleteSchedule(ctx context.Context, scheduleID, id string) error
+	ApplyDueSchedules(ctx context.Context) (int, error)
+	Export(ctx context.Context, f entity.LinkFilter, write func(l entity.Link) error) error
+	Import(ctx context.Context, userID string, rows []entity.LinkImportRow, onConflict string) (entity.LinkImportResult, error)
+}
+
+type APIKeyStorage interface {
+	Create(ctx context.Context, k entity.APIKey) (entity.APIKey, error)
+	FindAllByUserID(ctx context.Context, userID string) ([]entity.APIKey, error)
+	FindOneByHash(ctx context.Context, keyHash string) (entity.APIKey, error)
+	Revoke(ctx context.Context, id, userID string) error
+	UpdateLastUsed(ctx context.Context, id string) error
+}
+
+type APIKeyService interface {
+	Create(ctx context.Context, k entity.APIKey) (string, entity.APIKey, error)
+	GetAllByUserID(ctx context.Context, userID string) ([]entity.APIKey, error)
+	Revoke(ctx context.Context, id, userID string) error
+	Authenticate(ctx context.Context, rawKey string) (entity.APIKey, error)
+}
+
+type SessionStorage interface {
+	Create(ctx context.Context, s entity.Session, t entity.RefreshToken) (entity.Session, error)
+	FindOneByID(ctx context.Context, id string) (entity.Session, error)
+	FindAllActiveByUserID(ctx context.Context, userID string) ([]entity.Session, error)
+	FindRefreshTokenByHash(ctx context.Context, tokenHash string) (entity.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, oldTokenID string, t entity.RefreshToken) error
+	Revoke(ctx context.Context, id, userID string) error
+	RevokeAllByUserID(ctx context.Context, userID string) error
+}
+
+type SessionService interface {
+	Create(ctx context.Context, userID, userAgent, ip string) (entity.Session, string, error)
+	Refresh(ctx context.Context, refreshToken string) (entity.Session, string, error)
+	GetAllByUserID(ctx context.Context, userID string) ([]entity.Session, error)
+	Revoke(ctx context.Context, id, userID string) error
+	RevokeAllByUserID(ctx context.Context, userID string) error
+	Logout(ctx context.Context, userID, sessionID, tokenID string, tokenExpiresAt time.Time) error
+}
+
+// TokenDenylist keeps revoked access tokens until they expire
+type TokenDenylist interface {
+	RevokeToken(tokenID string, expiresAt time.Time) error
+	RevokeSession(sessionID string) error
+	RevokeUser(ctx context.Context, userID string) error
+	IsRevoked(ctx context.Context, userID, sessionID, tokenID string, issuedAt time.Time) (bool, error)
+}
+
+// TokenRevocationStorage keeps the time all access tokens of the user issued before are revoked
+type TokenRevocationStorage interface {
+	RevokeUser(ctx context.Context, userID string, at time.Time) error
+	FindUserRevokedAt(ctx context.Context, userID string) (*time.Time, error)
+}
+
+type LoginAttemptStorage interface {
+	FindOne(ctx context.Context, kind, value string) (entity.LoginAttempt, error)
+	FindAllLocked(ctx context.Context) ([]entity.LoginAttempt, error)
+	IncrementFailures(ctx context.Context, kind, value string, resetBefore time.Time) (entity.LoginAttempt, error)
+	Lock(ctx context.Context, kind, value string, until time.Time) error
+	Delete(ctx context.Context, kind, value string) error
+	PurgeExpired(ctx context.Context, lastFailureBefore, now time.Time) (int64, error)
+}
+
+type LoginGuardService interface {
+	Check(ctx context.Context, email, ip string) error
+	RegisterFailure(ctx context.Context, email, ip string) error
+	RegisterSuccess(ctx context.Context, email string) error
+	RegisterTwoFactorFailure(ctx context.Context, email, ip string) error
+	RegisterTwoFactorSuccess(ctx context.Context, email string) error
+	GetLocked(ctx context.Context) ([]entity.LoginAttempt, error)
+	Unlock(ctx context.Context, kind, value string) error
+	Purge(ctx context.Context) (int64, error)
+}
+
+type LockoutNotifier interface {
+	NotifyLockout(ctx context.Context, a entity.LoginAttempt) error
+}
+
+type UserTokenStorage interface {
+	Create(ctx context.Context, t entity.UserToken) error
+	Use(ctx context.Context, tokenHash, purpose string) (entity.UserToken, error)
+}
+
+type AccountService interface {
+	SendVerification(ctx context.Context, u entity.User) error
+	VerifyEmail(ctx context.Context, token string) error
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, password string) error
+}
+
+type TwoFactorStorage interface {
+	SaveSecret(ctx context.Context, userID, secret string) error
+	FindOneByUserID(ctx context.Context, userID string) (entity.TOTP, error)
+	Enable(ctx context.Context, userID string, step int64, codeHashes []string) error
+	UseStep(ctx context.Context, userID string, step int64) error
+	UseRecoveryCode(ctx context.Context, userID, codeHash string) error
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error
+	Delete(ctx context.Context, userID string) error
+}
+
+type TwoFactorService interface {
+	Enroll(ctx context.Context, u entity.User) (entity.TOTPEnrollment, error)
+	Enable(ctx context.Context, userID, code string) ([]string, error)
+	Disable(ctx context.Context, userID, password, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error)
+	IsEnabled(ctx context.Context, userID string) (bool, error)
+	CreateChallenge(ctx context.Context, userID string) (string, error)
+	VerifyChallenge(ctx context.Context, challenge, code, ip string) (string, error)
+}
+
+type IdentityStorage interface {
+	Create(ctx context.Context, i entity.Identity) (entity.Identity, error)
+	FindOne(ctx context.Context, provider, subject string) (entity.Identity, error)
+}
+
+type IdentityService interface {
+	// Login returns user linked to the external identity, linking or creating user by verified email
+	Login(ctx context.Context, i entity.Identity, emailVerified bool, name string) (entity.User, error)
+}
+
+type StatsStorage interface {
+	Get(ctx context.Context) (entity.Stats, error)
+}
+
+type StatsService interface {
+	Get(ctx context.Context) (entity.Stats, error)
+}
+
+type LinkReportStorage interface {
+	Create(ctx context.Context, r entity.LinkReport) (entity.LinkReport, error)
+	FindOneByID(ctx context.Context, id string) (entity.LinkReport, error)
+	FindAll(ctx context.Context, f entity.LinkReportFilter) ([]entity.LinkReport, error)
+	ResolveAllByLinkID(ctx context.Context, linkID, resolution string) error
+}
+
+type LinkReportService interface {
+	Report(ctx context.Context, shortVersion, reason, details, ip string) (entity.LinkReport, error)
+	GetAll(ctx context.Context, f entity.LinkReportFilter) ([]entity.LinkReport, error)
+	Resolve(ctx context.Context, id, resolution string) error
+}
+
+// Transactor runs f in a database transaction, storages called with the context passed to f take part in it
+type Transactor interface {
+	WithinTx(ctx context.Context, f func(ctx context.Context) error) error
+}
+
+type AuditStorage interface {
+	Create(ctx context.Context, e entity.AuditEvent) error
+	FindAll(ctx context.Context, f entity.AuditFilter) ([]entity.AuditEvent, error)
+	AnonymizeUser(ctx context.Context, userID string) (int64, error)
+}
+
+type AuditService interface {
+	// Record writes the event of the change made with ctx, it must be called in the transaction of the change
+	Record(ctx context.Context, action, targetType, targetID string, changes map[string]entity.AuditChange) error
+	GetAll(ctx context.Context, f entity.AuditFilter) ([]entity.AuditEvent, error)
+	// AnonymizeUser clears personal data of the deleted user from events made by or about them
+	AnonymizeUser(ctx context.Context, userID string) error
+}
+
+type LinkVersionStorage interface {
+	Create(ctx context.Context, v entity.LinkVersion) (entity.LinkVersion, error)
+	FindAllByLinkID(ctx context.Context, linkID string) ([]entity.LinkVersion, error)
+	FindOne(ctx context.Context, linkID string, version int) (entity.LinkVersion, error)
+}
+
+type LinkScheduleStorage interface {
+	Create(ctx context.Context, ls entity.LinkSchedule) (entity.LinkSchedule, error)
+	FindAllByLinkID(ctx context.Context, linkID string) ([]entity.LinkSchedule, error)
+	FindDue(ctx context.Context, now time.Time, limit int) ([]entity.LinkSchedule, error)
+	MarkApplied(ctx context.Context, ids []string) error
+	DeleteStale(ctx context.Context, now time.Time) (int64, error)
+	Delete(ctx context.Context, id, linkID string) error
+}
+
+type ClickEventStorage interface {
+	FindAllByUserID(ctx context.Context, userID string, afterID int64, limit int) ([]entity.ClickEvent, error)
+}
+
+type AccountDeletionStorage interface {
+	Create(ctx context.Context, userID string) error
+	FindOnePending(ctx context.Context) (entity.AccountDeletion, error)
+	Complete(ctx context.Context, userID string) (entity.AccountDeletion, error)
+}
+
+type AccountDataService interface {
+	// Export writes zip archive with all data of the user
+	Export(ctx context.Context, userID string, w io.Writer) error
+	RequestDeletion(ctx context.Context, userID string) error
+	ProcessDeletions(ctx context.Context) (int, error)
+}
+
+type WebhookStorage interface {
+	Create(ctx context.Context, w entity.Webhook) (entity.Webhook, error)
+	FindAllByUserID(ctx context.Context, userID string) ([]entity.Webhook, error)
+	FindOne(ctx context.Context, id, userID string) (entity.Webhook, error)
+	Update(ctx context.Context, w entity.Webhook) error
+	Delete(ctx context.Context, id, userID string) error
+}
+
+type WebhookDeliveryStorage interface {
+	Create(ctx context.Context, d entity.WebhookDelivery) (entity.WebhookDelivery, error)
+	CreateForEvent(ctx context.Context, userID, event string, payload []byte) (int64, error)
+	FindAllByWebhookID(ctx context.Context, webhookID string, limit, offset int) ([]entity.WebhookDelivery, error)
+	Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]entity.WebhookTask, error)
+	SaveAttempt(ctx context.Context, d entity.WebhookDelivery) error
+	PurgeFinished(ctx context.Context, createdBefore time.Time) (int64, error)
+}
+
+// WebhookSender posts the delivery to the webhook and returns response status, non 2xx response is an error.
+// Validate checks that the URL can be used for webhooks.
+type WebhookSender interface {
+	Validate(ctx context.Context, rawURL string) error
+	Send(ctx context.Context, w entity.Webhook, d entity.WebhookDelivery) (int, error)
+}
+
+type WebhookService interface {
+	Create(ctx context.Context, w entity.Webhook) (entity.Webhook, error)
+	GetAllByUserID(ctx context.Context, userID string) ([]entity.Webhook, error)
+	GetOne(ctx context.Context, id, userID string) (entity.Webhook, error)
+	Update(ctx context.Context, w entity.Webhook) error
+	Delete(ctx context.Context, id, userID string) error
+	GetDeliveries(ctx context.Context, id, userID string, limit, offset int) ([]entity.WebhookDelivery, error)
+	// Ping sends ping event to the webhook at once and returns the logged delivery
+	Ping(ctx context.Context, id, userID string) (entity.WebhookDelivery, error)
+	// Publish queues the event for webhooks of the user, called in the transaction of the change it is durable with it
+	Publish(ctx context.Context, userID, event string, data interface{}) error
+	ProcessDeliveries(ctx context.Context) (int, error)
+	PurgeDeliveries(ctx context.Context, retention time.Duration) (int64, error)
+}
+
+// RedirectRecorder counts results of short link redirects
+type RedirectRecorder interface {
+	Redirect(result string)
 }
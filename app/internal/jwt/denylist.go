@@ -0,0 +1,77 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/slava-911/URL-shortener/internal/apperror"
+	"github.com/slava-911/URL-shortener/internal/interf"
+	"github.com/slava-911/URL-shortener/pkg/cache"
+)
+
+var _ interf.TokenDenylist = &denylist{}
+
+const (
+	tokenKeyPrefix   = "revoked_token:"
+	sessionKeyPrefix = "revoked_session:"
+)
+
+// denylist stores revoked token IDs and sessions in the cache.
+// Entries live no longer than access token TTL, after that every affected token has expired anyway.
+// Revocation of all tokens of the user is stored in the database, so that it is seen by every process,
+// ex: the user disabled with the command line while the server is running.
+type denylist struct {
+	cache          cache.Repository
+	users          interf.TokenRevocationStorage
+	accessTokenTTL time.Duration
+}
+
+func NewDenylist(cache cache.Repository, users interf.TokenRevocationStorage,
+	accessTokenTTL time.Duration) interf.TokenDenylist {
+	return &denylist{cache: cache, users: users, accessTokenTTL: accessTokenTTL}
+}
+
+func (d *denylist) RevokeToken(tokenID string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return d.cache.Set([]byte(tokenKeyPrefix+tokenID), []byte{1}, expireIn(ttl))
+}
+
+func (d *denylist) RevokeSession(sessionID string) error {
+	return d.cache.Set([]byte(sessionKeyPrefix+sessionID), []byte{1}, expireIn(d.accessTokenTTL))
+}
+
+// RevokeUser invalidates all access tokens of the user issued before now, the time is kept in microseconds
+// as the database and issue time of tokens do
+func (d *denylist) RevokeUser(ctx context.Context, userID string) error {
+	return d.users.RevokeUser(ctx, userID, time.Now().UTC().Truncate(time.Microsecond))
+}
+
+// IsRevoked returns error if revocation of the user can not be read, the token must be rejected then
+func (d *denylist) IsRevoked(ctx context.Context, userID, sessionID, tokenID string, issuedAt time.Time) (bool, error) {
+	if _, err := d.cache.Get([]byte(tokenKeyPrefix + tokenID)); err == nil {
+		return true, nil
+	}
+	if sessionID != "" {
+		if _, err := d.cache.Get([]byte(sessionKeyPrefix + sessionID)); err == nil {
+			return true, nil
+		}
+	}
+
+	revokedAt, err := d.users.FindUserRevokedAt(ctx, userID)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return true, nil
+		}
+		return true, err
+	}
+	return revokedAt != nil && issuedAt.Before(*revokedAt), nil
+}
+
+// expireIn converts ttl to cache expiration in seconds, rounding up so entry never expires earlier than ttl
+func expireIn(ttl time.Duration) int {
+	return int((ttl + time.Second - 1) / time.Second)
+}
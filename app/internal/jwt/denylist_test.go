@@ -0,0 +1,52 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/slava-911/URL-shortener/pkg/cache/freecache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// revocations keeps revocation times of users in memory
+type revocations map[string]*time.Time
+
+func (r revocations) RevokeUser(_ context.Context, userID string, at time.Time) error {
+	r[userID] = &at
+	return nil
+}
+
+func (r revocations) FindUserRevokedAt(_ context.Context, userID string) (*time.Time, error) {
+	return r[userID], nil
+}
+
+func TestDenylistRevokeUser(t *testing.T) {
+	ctx := context.Background()
+	d := NewDenylist(freecache.NewCacheRepo(1024*1024), revocations{}, time.Minute)
+	require.NoError(t, d.RevokeUser(ctx, "user"))
+	revokedAt := time.Now()
+
+	before, err := d.IsRevoked(ctx, "user", "", "token-1", revokedAt.Add(-time.Millisecond))
+	require.NoError(t, err)
+	assert.True(t, before, "token issued before revocation")
+
+	// the same second as revocation, iat alone can not tell it from the revoked tokens
+	after, err := d.IsRevoked(ctx, "user", "", "token-2", revokedAt.Add(time.Millisecond))
+	require.NoError(t, err)
+	assert.False(t, after, "token issued after revocation")
+}
+
+func TestUserClaimsIssuedAtTime(t *testing.T) {
+	issued := time.Date(2026, 1, 2, 3, 4, 5, 678901000, time.UTC)
+
+	var c UserClaims
+	require.NoError(t, json.Unmarshal([]byte(`{"iat":1767323045,"iat_us":1767323045678901}`), &c))
+	assert.True(t, issued.Equal(c.IssuedAtTime()))
+
+	var old UserClaims
+	require.NoError(t, json.Unmarshal([]byte(`{"iat":1767323045}`), &old))
+	assert.True(t, issued.Truncate(time.Second).Equal(old.IssuedAtTime()))
+}
@@ -1,14 +1,15 @@
 package jwt
 
 import (
+	"context"
 	"encoding/json"
 	"time"
 
 	"github.com/cristalhq/jwt/v4"
 	"github.com/google/uuid"
-	"github.com/slava-911/URL-shortener/internal/config"
+	"github.com/slava-911/URL-shortener/internal/apperror"
 	"github.com/slava-911/URL-shortener/internal/domain/entity"
-	"github.com/slava-911/URL-shortener/pkg/cache"
+	"github.com/slava-911/URL-shortener/internal/interf"
 	"github.com/slava-911/URL-shortener/pkg/logging"
 )
 
@@ -17,7 +18,19 @@ var _ Helper = &helper{}
 // UserClaims is an object that is hashed into a JWT
 type UserClaims struct {
 	jwt.RegisteredClaims
-	Email string `json:"email"`
+	Email     string `json:"email"`
+	SessionID string `json:"sid"`
+	// IssuedAtMicro is the issue time in microseconds, iat has whole seconds only, which does not tell tokens
+	// issued right after revocation of all tokens of the user from the revoked ones
+	IssuedAtMicro int64 `json:"iat_us,omitempty"`
+}
+
+// IssuedAtTime returns the precise issue time if the token has it, otherwise iat
+func (c UserClaims) IssuedAtTime() time.Time {
+	if c.IssuedAtMicro != 0 {
+		return time.UnixMicro(c.IssuedAtMicro)
+	}
+	return c.IssuedAt.Time
 }
 
 type RT struct {
@@ -25,67 +38,93 @@ type RT struct {
 }
 
 type helper struct {
-	Logger  *logging.Logger
-	RTCache cache.Repository
+	Logger         *logging.Logger
+	Keys           KeySet
+	SessionService interf.SessionService
+	UserService    interf.UserService
+	Issuer         string
+	AccessTokenTTL time.Duration
 }
 
-func NewHelper(RTCache cache.Repository, logger *logging.Logger) Helper {
-	return &helper{RTCache: RTCache, Logger: logger}
+func NewHelper(keys KeySet, ss interf.SessionService, us interf.UserService, issuer string, accessTokenTTL time.Duration,
+	logger *logging.Logger) Helper {
+	return &helper{
+		Keys:           keys,
+		SessionService: ss,
+		UserService:    us,
+		Issuer:         issuer,
+		AccessTokenTTL: accessTokenTTL,
+		Logger:         logger,
+	}
 }
 
 type Helper interface {
-	GenerateAccessToken(u entity.User) ([]byte, error)
-	UpdateRefreshToken(rt RT) ([]byte, error)
+	GenerateAccessToken(ctx context.Context, u entity.User, userAgent, ip string) ([]byte, error)
+	UpdateRefreshToken(ctx context.Context, rt RT) ([]byte, error)
 }
 
-func (h *helper) UpdateRefreshToken(rt RT) ([]byte, error) {
-	defer h.RTCache.Del([]byte(rt.RefreshToken))
+func (h *helper) UpdateRefreshToken(ctx context.Context, rt RT) ([]byte, error) {
+	h.Logger.Info("rotate refresh token")
+	sess, refreshToken, err := h.SessionService.Refresh(ctx, rt.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
 
-	userBytes, err := h.RTCache.Get([]byte(rt.RefreshToken))
+	u, err := h.UserService.GetOneByID(ctx, sess.UserID)
 	if err != nil {
 		return nil, err
 	}
-	var u entity.User
-	err = json.Unmarshal(userBytes, &u)
+	if u.IsDisabled() {
+		return nil, apperror.ErrForbidden
+	}
+
+	return h.buildTokens(u, sess.ID, refreshToken)
+}
+
+func (h *helper) GenerateAccessToken(ctx context.Context, u entity.User, userAgent, ip string) ([]byte, error) {
+	h.Logger.Info("create refresh token")
+	if u.IsDisabled() {
+		h.Logger.Warnf("disabled user %s can not get tokens", u.ID)
+		return nil, apperror.ErrForbidden
+	}
+	sess, refreshToken, err := h.SessionService.Create(ctx, u.ID, userAgent, ip)
 	if err != nil {
+		h.Logger.Error(err)
 		return nil, err
 	}
-	return h.GenerateAccessToken(u)
+
+	return h.buildTokens(u, sess.ID, refreshToken)
 }
 
-func (h *helper) GenerateAccessToken(u entity.User) ([]byte, error) {
-	key := []byte(config.GetConfig().JWT.Secret)
-	signer, err := jwt.NewSignerHS(jwt.HS256, key)
+func (h *helper) buildTokens(u entity.User, sessionID, refreshToken string) ([]byte, error) {
+	signer, kid, err := h.Keys.Signer()
 	if err != nil {
 		return nil, err
 	}
-	builder := jwt.NewBuilder(signer)
+	builder := jwt.NewBuilder(signer, jwt.WithKeyID(kid))
 
+	now := time.Now()
 	claims := UserClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
-			ID:        u.ID,
+			ID:        uuid.NewString(),
+			Issuer:    h.Issuer,
+			Subject:   u.ID,
 			Audience:  []string{"users"},
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * 60)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(h.AccessTokenTTL)),
 		},
-		Email: u.Email,
+		Email:         u.Email,
+		SessionID:     sessionID,
+		IssuedAtMicro: now.UnixMicro(),
 	}
 	token, err := builder.Build(claims)
 	if err != nil {
 		return nil, err
 	}
 
-	h.Logger.Info("create refresh token")
-	refreshTokenUuid := uuid.New()
-	userBytes, _ := json.Marshal(u)
-	err = h.RTCache.Set([]byte(refreshTokenUuid.String()), userBytes, 0)
-	if err != nil {
-		h.Logger.Error(err)
-		return nil, err
-	}
-
 	jsonBytes, err := json.Marshal(map[string]string{
 		"token":         token.String(),
-		"refresh_token": refreshTokenUuid.String(),
+		"refresh_token": refreshToken,
 	})
 	if err != nil {
 		return nil, err
@@ -0,0 +1,492 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cristalhq/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+)
+
+var _ KeySet = &keySet{}
+
+const (
+	rsaKeyBits   = 2048
+	keyFileExt   = ".pem"
+	keyFileMode  = 0o600
+	keysDirMode  = 0o700
+	pemBlockType = "PRIVATE KEY"
+	// missReloadInterval limits rereading of keys dir for unknown key IDs, so that forged IDs can not load the disk
+	missReloadInterval = time.Second
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// KeySet holds keys used to sign and verify access tokens
+type KeySet interface {
+	// Signer returns signer of the active key and its key ID
+	Signer() (jwt.Signer, string, error)
+	// Verifier returns verifier of the key with passed key ID
+	Verifier(alg jwt.Algorithm, kid string) (jwt.Verifier, error)
+	// JWKS returns public keys in JSON Web Key Set format
+	JWKS() JWKS
+	// Rotate generates new active key, previous keys are kept until tokens signed by them expire
+	Rotate() error
+	// StartRotation rotates keys every rotation interval until context is done
+	StartRotation(ctx context.Context)
+}
+
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+type signingKey struct {
+	id        string
+	private   crypto.Signer
+	createdAt time.Time
+	// retiredAt is the moment when the key stopped signing new tokens
+	retiredAt time.Time
+}
+
+type keySet struct {
+	sync.RWMutex
+	alg              jwt.Algorithm
+	secret           []byte
+	dir              string
+	rotationInterval time.Duration
+	// retention is how long retired key is still needed to verify tokens signed by it
+	retention time.Duration
+	keys      map[string]*signingKey
+	activeID  string
+	// missMu serializes reloads for unknown key IDs, missReloadedAt is the time of the last one
+	missMu         sync.Mutex
+	missReloadedAt time.Time
+	logger         *logging.Logger
+}
+
+// NewKeySet creates key set for passed algorithm. HS256 uses shared secret, RS256 and EdDSA load private keys
+// from PEM files in dir (key ID is a file name), the newest key signs tokens. If there are no keys, new one is generated.
+func NewKeySet(alg, secret, dir string, rotationInterval, retention time.Duration, logger *logging.Logger) (KeySet, error) {
+	ks := &keySet{
+		alg:              jwt.Algorithm(alg),
+		secret:           []byte(secret),
+		dir:              dir,
+		rotationInterval: rotationInterval,
+		retention:        retention,
+		keys:             make(map[string]*signingKey),
+		logger:           logger,
+	}
+
+	switch ks.alg {
+	case jwt.HS256:
+		if secret == "" {
+			return nil, errors.New("jwt secret is required for HS256")
+		}
+		return ks, nil
+	case jwt.RS256, jwt.EdDSA:
+	default:
+		return nil, fmt.Errorf("unsupported jwt algorithm %s", alg)
+	}
+
+	if err := ks.load(); err != nil {
+		return nil, err
+	}
+	if ks.activeID == "" {
+		if ks.dir == "" {
+			logger.Warn("jwt keys dir is not set, generated signing key will be lost on restart")
+		}
+		if err := ks.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return ks, nil
+}
+
+func (ks *keySet) Signer() (jwt.Signer, string, error) {
+	if ks.alg == jwt.HS256 {
+		signer, err := jwt.NewSignerHS(jwt.HS256, ks.secret)
+		return signer, "", err
+	}
+
+	ks.RLock()
+	key, ok := ks.keys[ks.activeID]
+	ks.RUnlock()
+	if !ok {
+		return nil, "", ErrUnknownKey
+	}
+
+	switch k := key.private.(type) {
+	case *rsa.PrivateKey:
+		signer, err := jwt.NewSignerRS(jwt.RS256, k)
+		return signer, key.id, err
+	case ed25519.PrivateKey:
+		signer, err := jwt.NewSignerEdDSA(k)
+		return signer, key.id, err
+	}
+	return nil, "", ErrUnknownKey
+}
+
+func (ks *keySet) Verifier(alg jwt.Algorithm, kid string) (jwt.Verifier, error) {
+	if alg != ks.alg {
+		return nil, fmt.Errorf("unexpected jwt algorithm %s", alg)
+	}
+	if ks.alg == jwt.HS256 {
+		return jwt.NewVerifierHS(jwt.HS256, ks.secret)
+	}
+
+	key, ok := ks.key(kid)
+	if !ok {
+		// key could be generated by other instance sharing keys dir after the last reload
+		if key, ok = ks.reloadFor(kid); !ok {
+			return nil, ErrUnknownKey
+		}
+	}
+
+	switch k := key.private.(type) {
+	case *rsa.PrivateKey:
+		return jwt.NewVerifierRS(jwt.RS256, &k.PublicKey)
+	case ed25519.PrivateKey:
+		return jwt.NewVerifierEdDSA(k.Public().(ed25519.PublicKey))
+	}
+	return nil, ErrUnknownKey
+}
+
+func (ks *keySet) key(kid string) (*signingKey, bool) {
+	ks.RLock()
+	defer ks.RUnlock()
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+// reloadFor rereads keys dir once per missReloadInterval and looks the key up again.
+// Concurrent misses wait for one reload instead of reading the dir each.
+func (ks *keySet) reloadFor(kid string) (*signingKey, bool) {
+	if ks.dir == "" {
+		return nil, false
+	}
+
+	ks.missMu.Lock()
+	defer ks.missMu.Unlock()
+
+	if key, ok := ks.key(kid); ok {
+		return key, true
+	}
+	if time.Since(ks.missReloadedAt) < missReloadInterval {
+		return nil, false
+	}
+	ks.missReloadedAt = time.Now()
+	if err := ks.load(); err != nil {
+		ks.logger.Errorf("failed to reload jwt keys due to error %v", err)
+		return nil, false
+	}
+	return ks.key(kid)
+}
+
+func (ks *keySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+
+	ks.RLock()
+	defer ks.RUnlock()
+
+	for _, key := range ks.sortedKeys() {
+		jwk := JWK{KeyID: key.id, Use: "sig", Algorithm: string(ks.alg)}
+		switch k := key.private.(type) {
+		case *rsa.PrivateKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+		case ed25519.PrivateKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(k.Public().(ed25519.PublicKey))
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+func (ks *keySet) Rotate() error {
+	if ks.alg == jwt.HS256 {
+		return nil
+	}
+
+	key, err := ks.generate()
+	if err != nil {
+		return err
+	}
+	if err = ks.save(key); err != nil {
+		return err
+	}
+
+	ks.Lock()
+	defer ks.Unlock()
+
+	if active, ok := ks.keys[ks.activeID]; ok {
+		active.retiredAt = key.createdAt
+	}
+	ks.keys[key.id] = key
+	ks.activeID = key.id
+	ks.prune(key.createdAt)
+
+	ks.logger.Infof("jwt signing key rotated, active key id: %s", key.id)
+	return nil
+}
+
+func (ks *keySet) StartRotation(ctx context.Context) {
+	if ks.alg == jwt.HS256 || ks.rotationInterval <= 0 {
+		return
+	}
+
+	check := ks.rotationInterval / 10
+	if check > time.Minute {
+		check = time.Minute
+	}
+	ticker := time.NewTicker(check)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// other instances sharing keys dir could have already rotated the key
+			if err := ks.load(); err != nil {
+				ks.logger.Errorf("failed to reload jwt keys due to error %v", err)
+			}
+
+			ks.RLock()
+			active := ks.keys[ks.activeID]
+			ks.RUnlock()
+			if active != nil && time.Since(active.createdAt) < ks.rotationInterval {
+				continue
+			}
+			if err := ks.Rotate(); err != nil {
+				ks.logger.Errorf("failed to rotate jwt signing key due to error %v", err)
+			}
+		}
+	}
+}
+
+// load reads keys from dir, keys which are already known are skipped.
+// Broken keys and keys of another algorithm are logged and skipped, so that one bad file does not drop the others.
+func (ks *keySet) load() error {
+	if ks.dir == "" {
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(ks.dir, "*"+keyFileExt))
+	if err != nil {
+		return err
+	}
+
+	loaded := make([]*signingKey, 0, len(files))
+	for _, file := range files {
+		id := strings.TrimSuffix(filepath.Base(file), keyFileExt)
+
+		ks.RLock()
+		_, known := ks.keys[id]
+		ks.RUnlock()
+		if known {
+			continue
+		}
+
+		key, err := readKey(file, id)
+		if err != nil {
+			ks.logger.Errorf("failed to read jwt key %s, it is skipped, error: %v", file, err)
+			continue
+		}
+		if !ks.supports(key.private) {
+			ks.logger.Errorf("jwt key %s does not match jwt algorithm %s, it is skipped", file, ks.alg)
+			continue
+		}
+		loaded = append(loaded, key)
+	}
+
+	ks.Lock()
+	defer ks.Unlock()
+
+	for _, key := range loaded {
+		ks.keys[key.id] = key
+	}
+	keys := ks.sortedKeys()
+	for i, key := range keys {
+		if i+1 < len(keys) {
+			key.retiredAt = keys[i+1].createdAt
+		}
+	}
+	if len(keys) > 0 {
+		ks.activeID = keys[len(keys)-1].id
+	}
+	ks.prune(time.Now())
+
+	return nil
+}
+
+// prune removes retired keys which can't have signed any unexpired token. Must be called under lock.
+func (ks *keySet) prune(now time.Time) {
+	for id, key := range ks.keys {
+		if id == ks.activeID || key.retiredAt.IsZero() || now.Sub(key.retiredAt) < ks.retention {
+			continue
+		}
+		delete(ks.keys, id)
+		if ks.dir != "" {
+			if err := os.Remove(filepath.Join(ks.dir, id+keyFileExt)); err != nil && !os.IsNotExist(err) {
+				ks.logger.Errorf("failed to remove expired jwt key due to error %v", err)
+			}
+		}
+		ks.logger.Infof("expired jwt key %s removed", id)
+	}
+}
+
+// sortedKeys returns keys from the oldest to the newest. Must be called under lock.
+func (ks *keySet) sortedKeys() []*signingKey {
+	keys := make([]*signingKey, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].createdAt.Equal(keys[j].createdAt) {
+			return keys[i].id < keys[j].id
+		}
+		return keys[i].createdAt.Before(keys[j].createdAt)
+	})
+	return keys
+}
+
+func (ks *keySet) supports(key crypto.Signer) bool {
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		return ks.alg == jwt.RS256
+	case ed25519.PrivateKey:
+		return ks.alg == jwt.EdDSA
+	}
+	return false
+}
+
+func (ks *keySet) generate() (*signingKey, error) {
+	var private crypto.Signer
+	var err error
+	switch ks.alg {
+	case jwt.RS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case jwt.EdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		err = fmt.Errorf("unsupported jwt algorithm %s", ks.alg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate jwt key due to error %w", err)
+	}
+
+	return &signingKey{id: uuid.NewString(), private: private, createdAt: time.Now()}, nil
+}
+
+func (ks *keySet) save(key *signingKey) error {
+	if ks.dir == "" {
+		return nil
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key.private)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(ks.dir, keysDirMode); err != nil {
+		return err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: pemBlockType, Bytes: der})
+
+	// the key is written to temporary file and renamed, so that other instances never read a partial key
+	tmp, err := os.CreateTemp(ks.dir, "."+key.id+"-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save jwt key due to error %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err = writeKeyFile(tmp, data); err != nil {
+		return fmt.Errorf("failed to save jwt key due to error %w", err)
+	}
+	if err = os.Rename(tmp.Name(), filepath.Join(ks.dir, key.id+keyFileExt)); err != nil {
+		return fmt.Errorf("failed to save jwt key due to error %w", err)
+	}
+	return nil
+}
+
+func writeKeyFile(f *os.File, data []byte) error {
+	if err := f.Chmod(keyFileMode); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readKey(file, id string) (*signingKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode PEM key %s", file)
+	}
+
+	var private interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key %s due to error %w", file, err)
+	}
+
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type in %s", file)
+	}
+	return &signingKey{id: id, private: signer, createdAt: info.ModTime()}, nil
+}
@@ -0,0 +1,148 @@
+package jwt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cristalhq/jwt/v4"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var logger = logging.GetLogger("panic")
+
+func buildToken(t *testing.T, ks KeySet) *jwt.Token {
+	signer, kid, err := ks.Signer()
+	require.NoError(t, err)
+	token, err := jwt.NewBuilder(signer, jwt.WithKeyID(kid)).Build(jwt.RegisteredClaims{Subject: "user"})
+	require.NoError(t, err)
+	return token
+}
+
+func verifyToken(ks KeySet, token *jwt.Token) error {
+	verifier, err := ks.Verifier(token.Header().Algorithm, token.Header().KeyID)
+	if err != nil {
+		return err
+	}
+	return verifier.Verify(token)
+}
+
+// Test scenario:
+// 1. Create key set with keys dir, key must be generated and saved
+// 2. Sign token with the active key
+// 3. Rotate key, token signed by retired key must still be verified
+// 4. JWKS must publish both keys
+// 5. Key set loaded from the same dir must verify tokens of both keys and sign with the newest key
+func TestKeySetRotation(t *testing.T) {
+	for _, alg := range []string{"RS256", "EdDSA"} {
+		t.Run(alg, func(t *testing.T) {
+			dir := t.TempDir()
+			ks, err := NewKeySet(alg, "", dir, time.Hour, time.Hour, logger)
+			require.NoError(t, err)
+
+			files, _ := filepath.Glob(filepath.Join(dir, "*.pem"))
+			assert.Len(t, files, 1)
+
+			oldToken := buildToken(t, ks)
+			assert.NoError(t, verifyToken(ks, oldToken))
+
+			require.NoError(t, ks.Rotate())
+			newToken := buildToken(t, ks)
+			assert.NotEqual(t, oldToken.Header().KeyID, newToken.Header().KeyID)
+			assert.NoError(t, verifyToken(ks, oldToken))
+			assert.NoError(t, verifyToken(ks, newToken))
+
+			jwks := ks.JWKS()
+			if assert.Len(t, jwks.Keys, 2) {
+				assert.Equal(t, alg, jwks.Keys[0].Algorithm)
+			}
+
+			// keep modification times ordered as creation times are
+			past := time.Now().Add(-time.Minute)
+			require.NoError(t, os.Chtimes(filepath.Join(dir, oldToken.Header().KeyID+".pem"), past, past))
+
+			loaded, err := NewKeySet(alg, "", dir, time.Hour, time.Hour, logger)
+			require.NoError(t, err)
+			assert.NoError(t, verifyToken(loaded, oldToken))
+			assert.NoError(t, verifyToken(loaded, newToken))
+			assert.Equal(t, newToken.Header().KeyID, buildToken(t, loaded).Header().KeyID)
+		})
+	}
+}
+
+// Test scenario:
+// 1. Two key sets share keys dir, the first one verifies tokens of the second one
+// 2. The second key set rotates the key, the first one must load the new key on the first token signed by it
+// 3. Unknown key ID is rejected and does not reread the dir again until missReloadInterval passes
+func TestKeySetSharedDir(t *testing.T) {
+	dir := t.TempDir()
+	first, err := NewKeySet("EdDSA", "", dir, time.Hour, time.Hour, logger)
+	require.NoError(t, err)
+	second, err := NewKeySet("EdDSA", "", dir, time.Hour, time.Hour, logger)
+	require.NoError(t, err)
+	assert.NoError(t, verifyToken(first, buildToken(t, second)))
+
+	require.NoError(t, second.Rotate())
+	assert.NoError(t, verifyToken(first, buildToken(t, second)))
+
+	_, err = first.Verifier(jwt.EdDSA, "unknown")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	reloadedAt := first.(*keySet).missReloadedAt
+	_, err = first.Verifier(jwt.EdDSA, "unknown")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	assert.Equal(t, reloadedAt, first.(*keySet).missReloadedAt)
+}
+
+func TestKeySetPrune(t *testing.T) {
+	ks, err := NewKeySet("EdDSA", "", "", time.Hour, 0, logger)
+	require.NoError(t, err)
+
+	oldToken := buildToken(t, ks)
+	require.NoError(t, ks.Rotate())
+
+	assert.ErrorIs(t, verifyToken(ks, oldToken), ErrUnknownKey)
+	assert.Len(t, ks.JWKS().Keys, 1)
+}
+
+func TestKeySetHS256(t *testing.T) {
+	_, err := NewKeySet("HS256", "", "", 0, time.Hour, logger)
+	assert.Error(t, err)
+
+	ks, err := NewKeySet("HS256", "secret", "", 0, time.Hour, logger)
+	require.NoError(t, err)
+
+	token := buildToken(t, ks)
+	assert.NoError(t, verifyToken(ks, token))
+	assert.Empty(t, ks.JWKS().Keys)
+
+	_, err = ks.Verifier(jwt.RS256, "")
+	assert.Error(t, err)
+}
+
+func TestKeySetSkipsBadKeys(t *testing.T) {
+	dir := t.TempDir()
+	ks, err := NewKeySet("EdDSA", "", dir, time.Hour, time.Hour, logger)
+	require.NoError(t, err)
+	token := buildToken(t, ks)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.pem"), []byte("not a key"), 0o600))
+	rsaDir := t.TempDir()
+	_, err = NewKeySet("RS256", "", rsaDir, time.Hour, time.Hour, logger)
+	require.NoError(t, err)
+	rsaFiles, _ := filepath.Glob(filepath.Join(rsaDir, "*.pem"))
+	require.Len(t, rsaFiles, 1)
+	rsaKey, err := os.ReadFile(rsaFiles[0])
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, filepath.Base(rsaFiles[0])), rsaKey, 0o600))
+
+	loaded, err := NewKeySet("EdDSA", "", dir, time.Hour, time.Hour, logger)
+	require.NoError(t, err)
+	assert.NoError(t, verifyToken(loaded, token))
+	assert.Len(t, loaded.JWKS().Keys, 1)
+
+	tmp, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	assert.Empty(t, tmp)
+}
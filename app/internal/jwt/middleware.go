@@ -3,59 +3,134 @@ package jwt
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/cristalhq/jwt/v4"
-	"github.com/slava-911/URL-shortener/internal/config"
+	"github.com/slava-911/URL-shortener/internal/domain/entity"
+	"github.com/slava-911/URL-shortener/internal/interf"
 	"github.com/slava-911/URL-shortener/pkg/logging"
 )
 
-func Middleware(h http.HandlerFunc, logger *logging.Logger) http.HandlerFunc {
+const APIKeyHeader = "X-API-Key"
+
+var _ Middleware = &middleware{}
+
+type middleware struct {
+	keys          KeySet
+	apiKeyService interf.APIKeyService
+	denylist      interf.TokenDenylist
+	logger        *logging.Logger
+}
+
+func NewMiddleware(keys KeySet, apiKeyService interf.APIKeyService, denylist interf.TokenDenylist,
+	logger *logging.Logger) Middleware {
+	return &middleware{keys: keys, apiKeyService: apiKeyService, denylist: denylist, logger: logger}
+}
+
+type Middleware interface {
+	// Handle authorizes request by JWT access token or personal API key.
+	// Access token grants every scope, API key must grant all passed scopes.
+	Handle(h http.HandlerFunc, scopes ...string) http.HandlerFunc
+}
+
+func (m *middleware) Handle(h http.HandlerFunc, scopes ...string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if apiKey := r.Header.Get(APIKeyHeader); apiKey != "" {
+			m.handleAPIKey(h, apiKey, scopes, w, r)
+			return
+		}
+
 		authHeader := strings.Split(r.Header.Get("Authorization"), "Bearer ")
 		if len(authHeader) != 2 {
-			logger.Error("Malformed token")
+			m.logger.Error("Malformed token")
 			w.WriteHeader(http.StatusUnauthorized)
 			w.Write([]byte("The correct token is required for authorization"))
 			return
 		}
-		logger.Debug("create jwt verifier")
+		if strings.HasPrefix(authHeader[1], entity.APIKeyPrefix) {
+			m.handleAPIKey(h, authHeader[1], scopes, w, r)
+			return
+		}
+
+		m.logger.Debug("parse token")
 		jwtToken := authHeader[1]
-		key := []byte(config.GetConfig().JWT.Secret)
-		verifier, err := jwt.NewVerifierHS(jwt.HS256, key)
+		newToken, err := jwt.ParseNoVerify([]byte(jwtToken))
 		if err != nil {
-			unauthorized(w, err, logger)
+			unauthorized(w, err, m.logger)
 			return
 		}
-		logger.Debug("parse and verify token")
-		newToken, err := jwt.Parse([]byte(jwtToken), verifier)
+		m.logger.Debug("create jwt verifier")
+		header := newToken.Header()
+		verifier, err := m.keys.Verifier(header.Algorithm, header.KeyID)
 		if err != nil {
-			unauthorized(w, err, logger)
+			unauthorized(w, err, m.logger)
+			return
+		}
+		m.logger.Debug("verify token")
+		if err = verifier.Verify(newToken); err != nil {
+			unauthorized(w, err, m.logger)
 			return
 		}
 
-		logger.Debug("parse user claims")
+		m.logger.Debug("parse user claims")
 		var uc UserClaims
 		err = json.Unmarshal(newToken.Claims(), &uc)
 		if err != nil {
-			unauthorized(w, err, logger)
+			unauthorized(w, err, m.logger)
 			return
 		}
 		if valid := uc.IsValidAt(time.Now()); !valid {
-			logger.Error("token has been expired")
-			unauthorized(w, err, logger)
+			unauthorized(w, errors.New("token has been expired"), m.logger)
+			return
+		}
+		if uc.Subject == "" || uc.IssuedAt == nil || uc.ExpiresAt == nil {
+			unauthorized(w, errors.New("token has no subject, issue or expiration time"), m.logger)
+			return
+		}
+		revoked, err := m.denylist.IsRevoked(r.Context(), uc.Subject, uc.SessionID, uc.ID, uc.IssuedAtTime())
+		if err != nil {
+			unauthorized(w, fmt.Errorf("failed to check token revocation, error: %w", err), m.logger)
+			return
+		}
+		if revoked {
+			unauthorized(w, errors.New("token has been revoked"), m.logger)
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), "user_id", uc.ID)
+		ctx := context.WithValue(r.Context(), "user_id", uc.Subject)
+		ctx = context.WithValue(ctx, "user_claims", uc)
 		h(w, r.WithContext(ctx))
 	}
 }
 
+func (m *middleware) handleAPIKey(h http.HandlerFunc, rawKey string, scopes []string, w http.ResponseWriter, r *http.Request) {
+	m.logger.Debug("authenticate api key")
+	k, err := m.apiKeyService.Authenticate(r.Context(), rawKey)
+	if err != nil {
+		unauthorized(w, err, m.logger)
+		return
+	}
+	if !k.HasScopes(scopes...) {
+		forbidden(w, errors.New("api key does not grant required scopes"), m.logger)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), "user_id", k.UserID)
+	h(w, r.WithContext(ctx))
+}
+
 func unauthorized(w http.ResponseWriter, err error, logger *logging.Logger) {
 	logger.Error(err)
 	w.WriteHeader(http.StatusUnauthorized)
 	w.Write([]byte("unauthorized"))
 }
+
+func forbidden(w http.ResponseWriter, err error, logger *logging.Logger) {
+	logger.Error(err)
+	w.WriteHeader(http.StatusForbidden)
+	w.Write([]byte("forbidden"))
+}
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS refresh_tokens CASCADE;
+DROP TABLE IF EXISTS sessions CASCADE;
+
+END;
@@ -0,0 +1,31 @@
+BEGIN;
+
+CREATE TABLE sessions
+(
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id       UUID NOT NULL,
+    user_agent    TEXT NOT NULL DEFAULT '',
+    ip            TEXT NOT NULL DEFAULT '',
+    created_at    TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+    last_used_at  TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+    expires_at    TIMESTAMP NOT NULL,
+    revoked_at    TIMESTAMP,
+    CONSTRAINT user_fk FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+
+-- Every refresh token belongs to a session (token family). A token can be exchanged only once,
+-- presenting an already used token means it was stolen, so the whole session gets revoked.
+CREATE TABLE refresh_tokens
+(
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    session_id    UUID NOT NULL,
+    token_hash    TEXT NOT NULL UNIQUE,
+    created_at    TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+    expires_at    TIMESTAMP NOT NULL,
+    used_at       TIMESTAMP,
+    CONSTRAINT session_fk FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
+
+COMMIT;
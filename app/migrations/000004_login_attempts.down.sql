@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS login_attempts CASCADE;
+
+END;
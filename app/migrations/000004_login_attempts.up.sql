@@ -0,0 +1,16 @@
+BEGIN;
+
+-- Failed login attempts counted per email and per client IP
+CREATE TABLE login_attempts
+(
+    kind            TEXT NOT NULL,
+    value           TEXT NOT NULL,
+    failures        INT NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+    locked_until    TIMESTAMP,
+    PRIMARY KEY (kind, value)
+);
+
+CREATE INDEX login_attempts_locked_until_idx ON login_attempts (locked_until);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS user_tokens CASCADE;
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
+
+END;
@@ -0,0 +1,20 @@
+BEGIN;
+
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP;
+
+-- One-time tokens sent to users by email: email verification and password reset
+CREATE TABLE user_tokens
+(
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id       UUID NOT NULL,
+    purpose       TEXT NOT NULL,
+    token_hash    TEXT NOT NULL UNIQUE,
+    created_at    TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+    expires_at    TIMESTAMP NOT NULL,
+    used_at       TIMESTAMP,
+    CONSTRAINT user_fk FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX user_tokens_user_id_idx ON user_tokens (user_id, purpose);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS recovery_codes CASCADE;
+DROP TABLE IF EXISTS user_totp CASCADE;
+
+END;
@@ -0,0 +1,25 @@
+BEGIN;
+
+-- TOTP secrets, enabled_at is NULL while enrollment is not confirmed with a code
+CREATE TABLE user_totp
+(
+    user_id        UUID PRIMARY KEY,
+    secret         TEXT NOT NULL,
+    created_at     TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+    enabled_at     TIMESTAMP,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    CONSTRAINT user_fk FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE recovery_codes
+(
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id       UUID NOT NULL,
+    code_hash     TEXT NOT NULL UNIQUE,
+    used_at       TIMESTAMP,
+    CONSTRAINT user_fk FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX recovery_codes_user_id_idx ON recovery_codes (user_id);
+
+COMMIT;
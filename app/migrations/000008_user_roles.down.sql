@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS users_role_idx;
+ALTER TABLE users DROP COLUMN IF EXISTS disabled_at;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
+
+END;
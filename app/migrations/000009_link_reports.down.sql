@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE IF EXISTS link_reports;
+ALTER TABLE links DROP CONSTRAINT IF EXISTS links_status_check;
+ALTER TABLE links DROP COLUMN IF EXISTS status;
+
+END;
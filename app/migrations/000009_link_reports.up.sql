@@ -0,0 +1,24 @@
+BEGIN;
+
+-- Links can be disabled or taken down by admins, such links are not redirected.
+ALTER TABLE links ADD COLUMN status TEXT NOT NULL DEFAULT 'active';
+ALTER TABLE links ADD CONSTRAINT links_status_check CHECK (status IN ('active', 'disabled', 'taken_down'));
+
+-- Abuse reports sent by visitors of short links, resolved by admins.
+CREATE TABLE link_reports
+(
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    link_id       UUID NOT NULL,
+    reason        TEXT NOT NULL,
+    details       TEXT NOT NULL DEFAULT '',
+    reporter_ip   TEXT NOT NULL,
+    created_at    TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+    resolved_at   TIMESTAMP,
+    resolution    TEXT,
+    CONSTRAINT link_fk FOREIGN KEY (link_id) REFERENCES links(id) ON DELETE CASCADE
+);
+
+CREATE INDEX link_reports_link_id_idx ON link_reports (link_id);
+CREATE INDEX link_reports_open_idx ON link_reports (created_at) WHERE resolved_at IS NULL;
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE IF EXISTS retired_short_versions;
+DROP INDEX IF EXISTS links_deleted_at_idx;
+ALTER TABLE links DROP COLUMN IF EXISTS deleted_at;
+
+END;
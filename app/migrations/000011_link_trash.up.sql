@@ -0,0 +1,15 @@
+BEGIN;
+
+-- Deleted links stay in trash until purged, their short versions keep pointing nowhere instead of being reused.
+ALTER TABLE links ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX links_deleted_at_idx ON links (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Short versions of purged links, they are never given to new links.
+CREATE TABLE retired_short_versions
+(
+    short_version TEXT PRIMARY KEY,
+    retired_at    TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc')
+);
+
+COMMIT;
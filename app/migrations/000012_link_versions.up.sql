@@ -0,0 +1,24 @@
+BEGIN;
+
+-- Every destination and description a link has had, version 1 is the link as created.
+CREATE TABLE link_versions
+(
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    link_id       UUID NOT NULL,
+    version       INT NOT NULL,
+    full_version  TEXT NOT NULL,
+    description   TEXT NOT NULL DEFAULT '',
+    created_by    UUID,
+    created_at    TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+    CONSTRAINT link_fk FOREIGN KEY (link_id) REFERENCES links(id) ON DELETE CASCADE,
+    UNIQUE (link_id, version)
+);
+
+INSERT INTO link_versions
+    (link_id, version, full_version, description, created_by, created_at)
+SELECT
+    id, 1, full_version, COALESCE(description, ''), user_id, created_at
+FROM
+    links;
+
+COMMIT;
@@ -0,0 +1,19 @@
+BEGIN;
+
+-- Destinations a link switches to at the given time, applied_at is set by the scheduler.
+CREATE TABLE link_schedules
+(
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    link_id       UUID NOT NULL,
+    full_version  TEXT NOT NULL,
+    apply_at      TIMESTAMP NOT NULL,
+    applied_at    TIMESTAMP,
+    created_by    UUID,
+    created_at    TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+    CONSTRAINT link_fk FOREIGN KEY (link_id) REFERENCES links(id) ON DELETE CASCADE
+);
+
+CREATE INDEX link_schedules_link_id_idx ON link_schedules (link_id, apply_at);
+CREATE INDEX link_schedules_pending_idx ON link_schedules (apply_at) WHERE applied_at IS NULL;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS account_deletions;
+DROP TABLE IF EXISTS click_events;
+
+END;
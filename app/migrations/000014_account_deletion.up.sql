@@ -0,0 +1,31 @@
+BEGIN;
+
+-- Single redirects of links. Events of deleted accounts are anonymized: link, referrer and user agent are cleared,
+-- only the time of the click is kept for aggregated stats.
+CREATE TABLE click_events
+(
+    id            BIGSERIAL PRIMARY KEY,
+    link_id       UUID,
+    referrer      TEXT NOT NULL DEFAULT '',
+    user_agent    TEXT NOT NULL DEFAULT '',
+    clicked_at    TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+    CONSTRAINT link_fk FOREIGN KEY (link_id) REFERENCES links(id) ON DELETE SET NULL
+);
+
+CREATE INDEX click_events_link_id_idx ON click_events (link_id, clicked_at);
+CREATE INDEX click_events_clicked_at_idx ON click_events (clicked_at);
+
+-- Requests of users to delete their accounts, processed by background job.
+-- Rows outlive users and keep totals of their links, so that global stats do not drop.
+CREATE TABLE account_deletions
+(
+    user_id       UUID PRIMARY KEY,
+    requested_at  TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+    completed_at  TIMESTAMP,
+    links         BIGINT NOT NULL DEFAULT 0,
+    clicks        BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX account_deletions_pending_idx ON account_deletions (requested_at) WHERE completed_at IS NULL;
+
+COMMIT;
@@ -0,0 +1,40 @@
+BEGIN;
+
+-- Subscriptions of users to events of their links, secret signs payloads with HMAC-SHA256.
+CREATE TABLE webhooks
+(
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id       UUID NOT NULL,
+    url           TEXT NOT NULL,
+    secret        TEXT NOT NULL,
+    events        TEXT[] NOT NULL,
+    description   TEXT NOT NULL DEFAULT '',
+    active        BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at    TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+    CONSTRAINT user_fk FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX webhooks_user_id_idx ON webhooks (user_id);
+
+-- Delivery queue and log. Pending deliveries are sent by background job at next_attempt_at,
+-- failed attempts are retried with exponential backoff until the delivery succeeds or fails for good.
+CREATE TABLE webhook_deliveries
+(
+    id               UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    webhook_id       UUID NOT NULL,
+    event            TEXT NOT NULL,
+    payload          JSONB NOT NULL,
+    status           TEXT NOT NULL DEFAULT 'pending',
+    attempts         INT NOT NULL DEFAULT 0,
+    next_attempt_at  TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+    last_attempt_at  TIMESTAMP,
+    response_status  INT,
+    last_error       TEXT NOT NULL DEFAULT '',
+    created_at       TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+    CONSTRAINT webhook_fk FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+);
+
+CREATE INDEX webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, created_at);
+CREATE INDEX webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+-- Access tokens of the user issued before this time are rejected, it is kept in the database
+-- so that revocation made by one process (server instance, command line) is seen by all of them
+ALTER TABLE users ADD COLUMN tokens_revoked_at TIMESTAMP;
+
+COMMIT;
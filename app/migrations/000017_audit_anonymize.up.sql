@@ -0,0 +1,21 @@
+BEGIN;
+
+-- Audit events stay append-only, except for anonymization of a deleted user:
+-- with audit.anonymize set for the transaction client data and personal fields of changes may be cleared,
+-- what happened, to what and when can not be changed
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND current_setting('audit.anonymize', true) = 'on'
+        AND NEW.id = OLD.id
+        AND NEW.actor_id IS NOT DISTINCT FROM OLD.actor_id
+        AND NEW.action = OLD.action
+        AND NEW.target_type = OLD.target_type
+        AND NEW.target_id = OLD.target_id
+        AND NEW.created_at = OLD.created_at THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+COMMIT;
@@ -0,0 +1,9 @@
+// Package migrations embeds sql migrations of the database schema into the binary
+package migrations
+
+import "embed"
+
+// FS holds NNNNNN_name.up.sql and NNNNNN_name.down.sql files
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/slava-911/URL-shortener/pkg/migrate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFS(t *testing.T) {
+	ms, err := migrate.Parse(FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, ms)
+
+	for i, m := range ms {
+		assert.Equal(t, int64(i+1), m.Version, "versions must have no gaps")
+		assert.NotEmpty(t, m.Down, "migration %d_%s has no down script", m.Version, m.Name)
+	}
+}
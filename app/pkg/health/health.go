@@ -0,0 +1,135 @@
+// Package health serves liveness and readiness probes with the state of every checked dependency
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	LivenessURL  = "/healthz"
+	ReadinessURL = "/readyz"
+
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// checkTimeout limits every check, so that a hung dependency does not hang the probe
+const checkTimeout = 2 * time.Second
+
+// CheckFunc checks a dependency, info is shown in the report either way, for example a version
+type CheckFunc func(ctx context.Context) (info string, err error)
+
+type CheckResult struct {
+	Status   string `json:"status"`
+	Info     string `json:"info,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Checker runs named checks, the report fails if any of them fails
+type Checker struct {
+	mu     sync.RWMutex
+	checks map[string]CheckFunc
+}
+
+func NewChecker() *Checker {
+	return &Checker{checks: make(map[string]CheckFunc)}
+}
+
+// Add registers the check under the name, the check with the same name is replaced
+func (c *Checker) Add(name string, check CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// Run runs all checks concurrently
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.RLock()
+	names := make([]string, 0, len(c.checks))
+	checks := make([]CheckFunc, 0, len(c.checks))
+	for name, check := range c.checks {
+		names = append(names, name)
+		checks = append(checks, check)
+	}
+	c.mu.RUnlock()
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check CheckFunc) {
+			defer wg.Done()
+			results[i] = run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(names))}
+	for i, name := range names {
+		report.Checks[name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+func run(ctx context.Context, check CheckFunc) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	info, err := check(ctx)
+	res := CheckResult{Status: StatusOK, Info: info, Duration: time.Since(start).String()}
+	if err != nil {
+		res.Status, res.Error = StatusFail, err.Error()
+	}
+	return res
+}
+
+type Handler struct {
+	Liveness  *Checker
+	Readiness *Checker
+}
+
+// A HandlerFunc is a type that implement of handling an HTTP request.
+type HandlerFunc interface {
+	HandlerFunc(method, path string, handler http.HandlerFunc)
+}
+
+// Register adds the routes for the probes to the passed router.
+func (h *Handler) Register(router HandlerFunc) {
+	router.HandlerFunc(http.MethodGet, LivenessURL, h.Live)
+	router.HandlerFunc(http.MethodGet, ReadinessURL, h.Ready)
+}
+
+// Live reports whether the process works and has to be kept running
+func (h *Handler) Live(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, h.Liveness.Run(r.Context()))
+}
+
+// Ready reports whether the instance can serve traffic
+func (h *Handler) Ready(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, h.Readiness.Run(r.Context()))
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status == StatusOK {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,81 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecker(t *testing.T) {
+	c := NewChecker()
+	c.Add("db", func(ctx context.Context) (string, error) { return "v1", nil })
+	report := c.Run(context.Background())
+	assert.Equal(t, StatusOK, report.Status)
+	assert.Equal(t, "v1", report.Checks["db"].Info)
+
+	c.Add("cache", func(ctx context.Context) (string, error) { return "", errors.New("broken") })
+	report = c.Run(context.Background())
+	assert.Equal(t, StatusFail, report.Status)
+	assert.Equal(t, StatusOK, report.Checks["db"].Status)
+	assert.Equal(t, StatusFail, report.Checks["cache"].Status)
+	assert.Equal(t, "broken", report.Checks["cache"].Error)
+}
+
+func TestCheckerTimeout(t *testing.T) {
+	c := NewChecker()
+	c.Add("hung", func(ctx context.Context) (string, error) {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(time.Minute):
+			return "", nil
+		}
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	report := c.Run(ctx)
+	assert.Equal(t, StatusFail, report.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["hung"].Error)
+}
+
+func TestHandler(t *testing.T) {
+	ready := true
+	live, readiness := NewChecker(), NewChecker()
+	readiness.Add("shutdown", func(ctx context.Context) (string, error) {
+		if !ready {
+			return "", errors.New("shutting down")
+		}
+		return "", nil
+	})
+	router := httprouter.New()
+	h := Handler{Liveness: live, Readiness: readiness}
+	h.Register(router)
+
+	probe := func(url string) (int, Report) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		var report Report
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+		return rec.Code, report
+	}
+
+	code, report := probe(ReadinessURL)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusOK, report.Status)
+
+	ready = false
+	code, report = probe(ReadinessURL)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "shutting down", report.Checks["shutdown"].Error)
+
+	code, _ = probe(LivenessURL)
+	assert.Equal(t, http.StatusOK, code)
+}
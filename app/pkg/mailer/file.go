@@ -0,0 +1,60 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/slava-911/URL-shortener/pkg/logging"
+)
+
+type fileMailer struct {
+	dir    string
+	from   string
+	logger *logging.Logger
+}
+
+// NewFileMailer creates mailer for local testing which saves every message to .eml file in dir
+func NewFileMailer(dir, from string, logger *logging.Logger) (Mailer, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create mail dir due to error %w", err)
+	}
+	return &fileMailer{
+		dir:    dir,
+		from:   from,
+		logger: logger,
+	}, nil
+}
+
+func (f *fileMailer) Send(ctx context.Context, m Message) error {
+	now := time.Now()
+	name := fmt.Sprintf("%d-%s.eml", now.UnixNano(), strings.NewReplacer("@", "_at_", "/", "_").Replace(m.To))
+	path := filepath.Join(f.dir, name)
+	if err := os.WriteFile(path, build(f.from, m, now), 0o600); err != nil {
+		return fmt.Errorf("failed to save message due to error %w", err)
+	}
+	f.logger.Infof("mail %q to %s saved to %s", m.Subject, m.To, path)
+	return nil
+}
+
+type logMailer struct {
+	logger *logging.Logger
+}
+
+// NewLogMailer creates mailer for local testing which writes every message to the log
+func NewLogMailer(logger *logging.Logger) Mailer {
+	return &logMailer{
+		logger: logger,
+	}
+}
+
+func (l *logMailer) Send(ctx context.Context, m Message) error {
+	l.logger.WithFields(map[string]interface{}{
+		"to":      m.To,
+		"subject": m.Subject,
+	}).Info(m.Body)
+	return nil
+}
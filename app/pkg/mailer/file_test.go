@@ -0,0 +1,30 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/slava-911/URL-shortener/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m, err := NewFileMailer(dir, "noreply@example.com", logging.GetLogger("panic"))
+	require.NoError(t, err)
+
+	err = m.Send(context.Background(), Message{To: "user@example.com", Subject: "Привет", Body: "body"})
+	require.NoError(t, err)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*user_at_example.com.eml"))
+	require.Len(t, files, 1)
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "From: noreply@example.com\r\n")
+	assert.Contains(t, string(data), "To: user@example.com\r\n")
+	assert.Contains(t, string(data), "Subject: =?utf-8?q?")
+	assert.Contains(t, string(data), "\r\n\r\nbody")
+}
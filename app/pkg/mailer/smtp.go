@@ -0,0 +1,78 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+type smtpMailer struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+}
+
+// NewSMTPMailer creates mailer sending messages through SMTP server, STARTTLS is used when server supports it.
+// Authentication is skipped if username is empty.
+func NewSMTPMailer(host string, port int, username, password, from string) Mailer {
+	return &smtpMailer{
+		addr:     net.JoinHostPort(host, fmt.Sprint(port)),
+		host:     host,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+func (s *smtpMailer) Send(ctx context.Context, m Message) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to smtp server due to error %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to create smtp client due to error %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err = c.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return fmt.Errorf("failed to start tls due to error %w", err)
+		}
+	}
+	if s.username != "" {
+		if err = c.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return fmt.Errorf("failed to authenticate on smtp server due to error %w", err)
+		}
+	}
+
+	if err = c.Mail(s.from); err != nil {
+		return fmt.Errorf("failed to set sender due to error %w", err)
+	}
+	if err = c.Rcpt(m.To); err != nil {
+		return fmt.Errorf("failed to set recipient due to error %w", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("failed to start message due to error %w", err)
+	}
+	if _, err = w.Write(build(s.from, m, time.Now())); err != nil {
+		return fmt.Errorf("failed to write message due to error %w", err)
+	}
+	if err = w.Close(); err != nil {
+		return fmt.Errorf("failed to send message due to error %w", err)
+	}
+
+	return c.Quit()
+}
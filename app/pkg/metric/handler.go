@@ -3,11 +3,11 @@ package metric
 import "net/http"
 
 const (
-	URL = "/heartbeat"
+	URL        = "/heartbeat"
+	MetricsURL = "/metrics"
 )
 
-type Handler struct {
-}
+type Handler struct{}
 
 // A HandlerFunc is a type that implement of handling an HTTP request.
 type HandlerFunc interface {
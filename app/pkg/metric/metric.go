@@ -0,0 +1,149 @@
+package metric
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/slava-911/URL-shortener/pkg/cache"
+)
+
+const namespace = "shortener"
+
+// Results of short link redirects
+const (
+	RedirectOK        = "redirected"
+	RedirectNotFound  = "not_found"
+	RedirectDisabled  = "disabled"
+	RedirectTakenDown = "taken_down"
+	RedirectError     = "error"
+)
+
+// Metrics keeps collectors of the application in its own registry, so that only them are exposed
+type Metrics struct {
+	registry  *prometheus.Registry
+	requests  *prometheus.CounterVec
+	duration  *prometheus.HistogramVec
+	redirects *prometheus.CounterVec
+}
+
+// New creates registry with HTTP, redirect, Go runtime and process metrics
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests by route and response status.",
+		}, []string{"method", "route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests by route and response status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		redirects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "redirects_total",
+			Help:      "Number of short link redirects by result.",
+		}, []string{"result"}),
+	}
+	m.registry.MustRegister(
+		m.requests,
+		m.duration,
+		m.redirects,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves metrics in Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Redirect counts a redirect with one of Redirect* results
+func (m *Metrics) Redirect(result string) {
+	m.redirects.WithLabelValues(result).Inc()
+}
+
+// Middleware counts requests and observes their latency, route returns the label of the request route.
+// Labelling by route instead of path keeps the number of series bounded.
+func (m *Metrics) Middleware(h http.Handler, route func(r *http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r)
+
+		labels := []string{method(r.Method), route(r), strconv.Itoa(rec.status)}
+		m.requests.WithLabelValues(labels...).Inc()
+		m.duration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+	})
+}
+
+// RegisterCache exposes entry, hit and miss counts of the cache labelled with its name
+func (m *Metrics) RegisterCache(name string, c cache.Repository) error {
+	labels := prometheus.Labels{"cache": name}
+	for _, col := range []prometheus.Collector{
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "cache_entries",
+			Help:        "Number of entries in the cache.",
+			ConstLabels: labels,
+		}, func() float64 { return float64(c.EntryCount()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "cache_hits_total",
+			Help:        "Number of keys found in the cache.",
+			ConstLabels: labels,
+		}, func() float64 { return float64(c.HitCount()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "cache_misses_total",
+			Help:        "Number of keys not found in the cache.",
+			ConstLabels: labels,
+		}, func() float64 { return float64(c.MissCount()) }),
+	} {
+		if err := m.registry.Register(col); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterPool exposes connection stats of the database pool
+func (m *Metrics) RegisterPool(stat func() *pgxpool.Stat) error {
+	return m.registry.Register(newPoolCollector(stat))
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush keeps streamed responses, ex: exports, flushing through the recorder
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// method keeps arbitrary methods of malformed requests out of labels
+func method(m string) string {
+	switch m {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions:
+		return m
+	}
+	return "OTHER"
+}
@@ -0,0 +1,90 @@
+package metric
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/slava-911/URL-shortener/pkg/cache/freecache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouterRoute(t *testing.T) {
+	router := httprouter.New()
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+	router.HandlerFunc(http.MethodGet, "/links", noop)
+	router.HandlerFunc(http.MethodGet, "/links/:id", noop)
+	router.HandlerFunc(http.MethodGet, "/links/:id/history/:version", noop)
+	router.HandlerFunc(http.MethodGet, "/s/:short_version", noop)
+	route := RouterRoute(router)
+
+	tests := []struct {
+		method, path, route string
+	}{
+		{http.MethodGet, "/links", "/links"},
+		{http.MethodGet, "/links/42", "/links/:id"},
+		// parameter values equal to static segments
+		{http.MethodGet, "/links/links", "/links/:id"},
+		{http.MethodGet, "/links/history/history/history", "/links/:id/history/:version"},
+		{http.MethodGet, "/s/s", "/s/:short_version"},
+		{http.MethodGet, "/unknown", UnmatchedRoute},
+		{http.MethodPost, "/links", UnmatchedRoute},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		assert.Equal(t, tt.route, route(r), tt.path)
+	}
+}
+
+// Test scenario:
+// 1. Requests go through the middleware and are counted by route and status
+// 2. Redirects and cache stats are exposed by the metrics handler
+func TestMetrics(t *testing.T) {
+	m := New()
+	router := httprouter.New()
+	router.HandlerFunc(http.MethodGet, "/links/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	h := m.Middleware(router, RouterRoute(router))
+
+	for _, path := range []string{"/links/1", "/links/2", "/missing"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.requests.WithLabelValues("GET", "/links/:id", "404")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues("GET", UnmatchedRoute, "404")))
+
+	m.Redirect(RedirectOK)
+	c := freecache.NewCacheRepo(1024 * 1024)
+	require.NoError(t, c.Set([]byte("key"), []byte("value"), 0))
+	require.NoError(t, m.RegisterCache("test", c))
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, line := range []string{
+		`shortener_redirects_total{result="redirected"} 1`,
+		`shortener_cache_entries{cache="test"} 1`,
+		`shortener_http_request_duration_seconds_count{method="GET",route="/links/:id",status="404"} 2`,
+		"go_goroutines",
+	} {
+		assert.True(t, strings.Contains(body, line), line)
+	}
+}
+
+func TestMiddlewareFlush(t *testing.T) {
+	m := New()
+	h := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := w.(http.Flusher)
+		require.True(t, ok)
+		_, _ = w.Write([]byte("part"))
+		f.Flush()
+	}), func(r *http.Request) string { return r.URL.Path })
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/export", nil))
+	assert.True(t, rec.Flushed)
+}
@@ -0,0 +1,62 @@
+package metric
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// UnmatchedRoute labels requests which match no route
+const UnmatchedRoute = "unmatched"
+
+// RouterRoute returns function which finds pattern of the router route matching the request, ex: /links/:id.
+// httprouter does not expose the matched pattern, so values of path parameters are put back as their names
+// and the result is checked to match the same route.
+func RouterRoute(router *httprouter.Router) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		handle, ps, _ := router.Lookup(r.Method, r.URL.Path)
+		if handle == nil {
+			return UnmatchedRoute
+		}
+		if len(ps) == 0 {
+			return r.URL.Path
+		}
+		if route, ok := pattern(router, r.Method, strings.Split(r.URL.Path, "/"), ps, len(ps), 0); ok {
+			return route
+		}
+		return UnmatchedRoute
+	}
+}
+
+// pattern replaces segments equal to values of parameters from ps[0] on. A static segment can have the same value
+// as the parameter, so the candidate is looked up and must match a route with all total parameters named after
+// themselves, otherwise other segments are tried.
+func pattern(router *httprouter.Router, method string, segments []string, ps httprouter.Params,
+	total, from int) (string, bool) {
+	if len(ps) == 0 {
+		route := strings.Join(segments, "/")
+		handle, got, _ := router.Lookup(method, route)
+		if handle == nil || len(got) != total {
+			return "", false
+		}
+		for _, p := range got {
+			if !strings.HasPrefix(p.Value, ":") || p.Value[1:] != p.Key {
+				return "", false
+			}
+		}
+		return route, true
+	}
+
+	for i := from; i < len(segments); i++ {
+		if segments[i] != ps[0].Value {
+			continue
+		}
+		segments[i] = ":" + ps[0].Key
+		if route, ok := pattern(router, method, segments, ps[1:], total, i+1); ok {
+			return route, true
+		}
+		segments[i] = ps[0].Value
+	}
+	return "", false
+}
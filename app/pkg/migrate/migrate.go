@@ -0,0 +1,447 @@
+// Package migrate applies numbered sql migrations and records them in schema_migrations table
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/slava-911/URL-shortener/pkg/logging"
+)
+
+// lockID is the key of advisory lock held while migrations run, so that instances started together do not race
+const lockID = 4311239578
+
+const createTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations
+	(
+	    version    BIGINT PRIMARY KEY,
+	    name       TEXT NOT NULL,
+	    dirty      BOOLEAN NOT NULL DEFAULT FALSE,
+	    applied_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc')
+	)
+`
+
+var fileRe = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// ErrDirty is returned while the last migration has failed halfway, the schema has to be fixed by hand and forced
+var ErrDirty = errors.New("database is dirty")
+
+// ErrUntracked is returned while tables exist but no migration is recorded, that is the schema was migrated
+// by hand or by another tool, its version has to be recorded with force
+var ErrUntracked = errors.New("schema is not tracked by migrations")
+
+// Migration is a pair of up and down scripts with the same version
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// State is a migration with its record in schema_migrations
+type State struct {
+	Migration
+	Applied   bool
+	Dirty     bool
+	AppliedAt *time.Time
+}
+
+type Migrator struct {
+	conn       *pgx.Conn
+	migrations []Migration
+	logger     *logging.Logger
+}
+
+// New reads migrations from fsys root, each version must have up script, down script is optional
+func New(conn *pgx.Conn, fsys fs.FS, logger *logging.Logger) (*Migrator, error) {
+	migrations, err := Parse(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		conn:       conn,
+		migrations: migrations,
+		logger:     logger,
+	}, nil
+}
+
+// Parse returns migrations of fsys root sorted by version
+func Parse(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != ".sql" {
+			continue
+		}
+		m := fileRe.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("unexpected migration file name %s", e.Name())
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid version of migration file %s", e.Name())
+		}
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mg, ok := byVersion[version]
+		if !ok {
+			mg = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mg
+		}
+		if mg.Name != m[2] {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", mg.Name, m[2])
+		}
+		if m[3] == "up" {
+			mg.Up = string(body)
+		} else {
+			mg.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mg := range byVersion {
+		if mg.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", mg.Version, mg.Name)
+		}
+		migrations = append(migrations, *mg)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Up applies n pending migrations in version order, all of them if n is not positive
+func (m *Migrator) Up(ctx context.Context, n int) error {
+	return m.locked(ctx, func(applied map[int64]State) error {
+		if n <= 0 {
+			n = len(m.migrations)
+		}
+		for _, mg := range m.migrations {
+			if n == 0 {
+				break
+			}
+			if applied[mg.Version].Applied {
+				continue
+			}
+			if err := m.apply(ctx, mg, true); err != nil {
+				return err
+			}
+			n--
+		}
+		return nil
+	})
+}
+
+// Down reverts n last applied migrations, all of them if n is not positive
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	return m.locked(ctx, func(applied map[int64]State) error {
+		if n <= 0 {
+			n = len(m.migrations)
+		}
+		for i := len(m.migrations) - 1; i >= 0 && n > 0; i-- {
+			mg := m.migrations[i]
+			if !applied[mg.Version].Applied {
+				continue
+			}
+			if err := m.apply(ctx, mg, false); err != nil {
+				return err
+			}
+			n--
+		}
+		return nil
+	})
+}
+
+// Goto applies or reverts migrations so that exactly versions up to the given one are applied, 0 reverts everything
+func (m *Migrator) Goto(ctx context.Context, version int64) error {
+	if !m.known(version) {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+	return m.locked(ctx, func(applied map[int64]State) error {
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mg := m.migrations[i]
+			if mg.Version > version && applied[mg.Version].Applied {
+				if err := m.apply(ctx, mg, false); err != nil {
+					return err
+				}
+			}
+		}
+		for _, mg := range m.migrations {
+			if mg.Version <= version && !applied[mg.Version].Applied {
+				if err := m.apply(ctx, mg, true); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Force records versions up to the given one as applied and the rest as not applied without running any scripts.
+// It clears dirty state after the schema was fixed by hand and marks databases migrated before the runner existed.
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	if !m.known(version) {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+	return m.withLock(ctx, func() error {
+		return m.conn.BeginFunc(ctx, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, `DELETE FROM schema_migrations`); err != nil {
+				return err
+			}
+			for _, mg := range m.migrations {
+				if mg.Version > version {
+					break
+				}
+				q := `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`
+				if _, err := tx.Exec(ctx, q, mg.Version, mg.Name); err != nil {
+					return err
+				}
+			}
+			m.logger.Infof("schema version is forced to %d", version)
+			return nil
+		})
+	})
+}
+
+// Status returns all known migrations with their state, applied versions missing in the binary are reported too
+func (m *Migrator) Status(ctx context.Context) (states []State, err error) {
+	err = m.withLock(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for _, mg := range m.migrations {
+			st := applied[mg.Version]
+			st.Migration = mg
+			states = append(states, st)
+			delete(applied, mg.Version)
+		}
+		for _, st := range applied {
+			states = append(states, st)
+		}
+		sort.Slice(states, func(i, j int) bool { return states[i].Version < states[j].Version })
+		return nil
+	})
+	return states, err
+}
+
+func (m *Migrator) known(version int64) bool {
+	if version == 0 {
+		return true
+	}
+	for _, mg := range m.migrations {
+		if mg.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// locked runs f under the lock if no migration is dirty and the schema is not migrated without the runner
+func (m *Migrator) locked(ctx context.Context, f func(applied map[int64]State) error) error {
+	return m.withLock(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for _, st := range applied {
+			if st.Dirty {
+				return fmt.Errorf("%w: migration %d_%s has failed, fix the schema and run force", ErrDirty, st.Version, st.Name)
+			}
+		}
+		if len(applied) == 0 {
+			exists, err := hasTables(ctx, m.conn)
+			if err != nil {
+				return err
+			}
+			if exists {
+				return fmt.Errorf("%w: tables exist but no migration is recorded, "+
+					"run force with the last version the schema matches", ErrUntracked)
+			}
+		}
+		return f(applied)
+	})
+}
+
+func (m *Migrator) withLock(ctx context.Context, f func() error) error {
+	if _, err := m.conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock, error: %w", err)
+	}
+	defer func() {
+		if _, err := m.conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID); err != nil {
+			m.logger.Errorf("failed to release migration lock due to error %v", err)
+		}
+	}()
+
+	if err := m.adopt(ctx); err != nil {
+		return fmt.Errorf("failed to convert schema_migrations table of golang-migrate, error: %w", err)
+	}
+	if _, err := m.conn.Exec(ctx, createTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table, error: %w", err)
+	}
+
+	return f()
+}
+
+// adopt converts schema_migrations of golang-migrate, which keeps the only row with the last version,
+// recording versions up to that one as applied, so that databases migrated with it keep their state
+func (m *Migrator) adopt(ctx context.Context) error {
+	var legacy bool
+	q := `
+		SELECT
+		    to_regclass('schema_migrations') IS NOT NULL
+		    AND NOT EXISTS (
+		        SELECT 1 FROM information_schema.columns
+		        WHERE table_schema = current_schema() AND table_name = 'schema_migrations' AND column_name = 'name'
+		    )
+	`
+	if err := m.conn.QueryRow(ctx, q).Scan(&legacy); err != nil || !legacy {
+		return err
+	}
+
+	return m.conn.BeginFunc(ctx, func(tx pgx.Tx) error {
+		var version int64
+		var dirty bool
+		err := tx.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+
+		if _, err = tx.Exec(ctx, `DROP TABLE schema_migrations`); err != nil {
+			return err
+		}
+		if _, err = tx.Exec(ctx, createTable); err != nil {
+			return err
+		}
+		for _, mg := range m.migrations {
+			if mg.Version > version {
+				break
+			}
+			// the last version stays dirty if golang-migrate has failed on it
+			q = `INSERT INTO schema_migrations (version, name, dirty) VALUES ($1, $2, $3)`
+			if _, err = tx.Exec(ctx, q, mg.Version, mg.Name, dirty && mg.Version == version); err != nil {
+				return err
+			}
+		}
+		m.logger.Infof("schema_migrations of golang-migrate is converted, schema version is %d", version)
+		return nil
+	})
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]State, error) {
+	rows, err := m.conn.Query(ctx, `SELECT version, name, dirty, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]State)
+	for rows.Next() {
+		st := State{Applied: true}
+		var appliedAt time.Time
+		if err = rows.Scan(&st.Version, &st.Name, &st.Dirty, &appliedAt); err != nil {
+			return nil, err
+		}
+		st.AppliedAt = &appliedAt
+		applied[st.Version] = st
+	}
+
+	return applied, rows.Err()
+}
+
+// apply runs the script outside of transaction because scripts manage their own ones.
+// The version is marked dirty before the script and is left so if the script fails.
+func (m *Migrator) apply(ctx context.Context, mg Migration, up bool) error {
+	direction, script := "up", mg.Up
+	if !up {
+		direction, script = "down", mg.Down
+		if script == "" {
+			return fmt.Errorf("migration %d_%s has no down script", mg.Version, mg.Name)
+		}
+	}
+	m.logger.Infof("migrate %s %d_%s", direction, mg.Version, mg.Name)
+
+	var q string
+	if up {
+		q = `INSERT INTO schema_migrations (version, name, dirty) VALUES ($1, $2, TRUE)`
+		if _, err := m.conn.Exec(ctx, q, mg.Version, mg.Name); err != nil {
+			return err
+		}
+	} else {
+		q = `UPDATE schema_migrations SET dirty = TRUE WHERE version = $1`
+		if _, err := m.conn.Exec(ctx, q, mg.Version); err != nil {
+			return err
+		}
+	}
+
+	// without arguments the script goes through simple protocol, which allows several statements
+	if _, err := m.conn.Exec(ctx, script); err != nil {
+		// leave failed transaction of the script, otherwise the connection refuses further queries
+		m.conn.Exec(context.Background(), `ROLLBACK`)
+		return fmt.Errorf("migration %d_%s %s failed, database is left dirty, error: %w", mg.Version, mg.Name, direction, err)
+	}
+
+	if up {
+		q = `UPDATE schema_migrations SET dirty = FALSE, applied_at = (now() AT TIME ZONE 'utc') WHERE version = $1`
+	} else {
+		q = `DELETE FROM schema_migrations WHERE version = $1`
+	}
+	_, err := m.conn.Exec(ctx, q, mg.Version)
+	return err
+}
+
+// RowQuerier is satisfied by both connection and pool
+type RowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// Current returns the last applied version and whether it is dirty, 0 if nothing is applied to empty database.
+// ErrUntracked is returned if schema_migrations is missing or empty while other tables exist.
+func Current(ctx context.Context, q RowQuerier) (version int64, dirty bool, err error) {
+	var tracked bool
+	if err = q.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&tracked); err != nil {
+		return 0, false, err
+	}
+	if tracked {
+		err = q.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations ORDER BY version DESC LIMIT 1`).
+			Scan(&version, &dirty)
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return version, dirty, err
+		}
+	}
+
+	untracked, err := hasTables(ctx, q)
+	if err != nil {
+		return 0, false, err
+	}
+	if untracked {
+		return 0, false, ErrUntracked
+	}
+	return 0, false, nil
+}
+
+// hasTables reports whether the current schema has tables other than schema_migrations
+func hasTables(ctx context.Context, q RowQuerier) (exists bool, err error) {
+	err = q.QueryRow(ctx, `
+		SELECT EXISTS (
+		    SELECT 1 FROM information_schema.tables
+		    WHERE table_schema = current_schema() AND table_name <> 'schema_migrations'
+		)
+	`).Scan(&exists)
+	return exists, err
+}
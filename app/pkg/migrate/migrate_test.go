@@ -0,0 +1,47 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000002_links.up.sql":   {Data: []byte("CREATE TABLE links ();")},
+		"000002_links.down.sql": {Data: []byte("DROP TABLE links;")},
+		"000001_init.up.sql":    {Data: []byte("CREATE TABLE users ();")},
+		"migrations.go":         {Data: []byte("package migrations")},
+	}
+
+	migrations, err := Parse(fsys)
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "init", migrations[0].Name)
+	assert.Empty(t, migrations[0].Down)
+	assert.Equal(t, int64(2), migrations[1].Version)
+	assert.Equal(t, "DROP TABLE links;", migrations[1].Down)
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"bad name":     {"init.up.sql": {}},
+		"zero version": {"000000_init.up.sql": {Data: []byte("SELECT 1;")}},
+		"no up":        {"000001_init.down.sql": {Data: []byte("SELECT 1;")}},
+		"same version": {
+			"000001_init.up.sql":  {Data: []byte("SELECT 1;")},
+			"000001_other.up.sql": {Data: []byte("SELECT 1;")},
+		},
+	}
+
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse(fsys)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var ErrNonceMismatch = errors.New("id token nonce does not match")
+
+// Claims are identity claims of the authenticated user
+type Claims struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+// Provider runs authorization code flow with PKCE against OpenID Connect provider
+type Provider interface {
+	// AuthCodeURL returns URL of the provider login page, verifier and nonce must be kept until Exchange
+	AuthCodeURL(state, verifier, nonce string) string
+	// Exchange trades authorization code for tokens and returns claims of verified ID token
+	Exchange(ctx context.Context, code, verifier, nonce string) (Claims, error)
+}
+
+type provider struct {
+	config   oauth2.Config
+	verifier *gooidc.IDTokenVerifier
+}
+
+// NewProvider discovers provider configuration at issuer/.well-known/openid-configuration
+func NewProvider(ctx context.Context, issuer, clientID, clientSecret, redirectURL string, scopes []string) (Provider, error) {
+	p, err := gooidc.NewProvider(ctx, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover oidc provider due to error %w", err)
+	}
+
+	return &provider{
+		config: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Endpoint:     p.Endpoint(),
+			Scopes:       append([]string{gooidc.ScopeOpenID}, scopes...),
+		},
+		verifier: p.Verifier(&gooidc.Config{ClientID: clientID}),
+	}, nil
+}
+
+func (p *provider) AuthCodeURL(state, verifier, nonce string) string {
+	return p.config.AuthCodeURL(state,
+		gooidc.Nonce(nonce),
+		oauth2.SetAuthURLParam("code_challenge", CodeChallenge(verifier)),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+}
+
+func (p *provider) Exchange(ctx context.Context, code, verifier, nonce string) (c Claims, err error) {
+	token, err := p.config.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", verifier))
+	if err != nil {
+		return c, fmt.Errorf("failed to exchange authorization code due to error %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return c, errors.New("there is no id_token in token response")
+	}
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return c, fmt.Errorf("failed to verify id token due to error %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(nonce)) != 1 {
+		return c, ErrNonceMismatch
+	}
+
+	if err = idToken.Claims(&c); err != nil {
+		return c, fmt.Errorf("failed to parse id token claims due to error %w", err)
+	}
+
+	return c, nil
+}
+
+// CodeChallenge returns S256 PKCE code challenge of the verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
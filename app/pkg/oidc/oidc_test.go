@@ -0,0 +1,56 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/slava-911/URL-shortener/pkg/oidc/oidctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var noRedirect = &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+	return http.ErrUseLastResponse
+}}
+
+// login follows provider login page and returns authorization code and state from the redirect
+func login(t *testing.T, authURL string) (string, string) {
+	resp, err := noRedirect.Get(authURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+// Test scenario:
+// 1. Discover mock provider and build login URL with PKCE challenge and nonce
+// 2. Provider redirects back with code and the same state
+// 3. Exchange with wrong verifier fails, with the right verifier returns claims of the user
+// 4. ID token with other nonce is rejected
+func TestProvider(t *testing.T) {
+	srv := oidctest.NewServer(oidctest.User{Subject: "42", Email: "user@example.com", EmailVerified: true, Name: "User"})
+	defer srv.Close()
+
+	ctx := context.Background()
+	p, err := NewProvider(ctx, srv.URL, "client", "secret", "http://localhost/callback", []string{"email", "profile"})
+	require.NoError(t, err)
+
+	code, state := login(t, p.AuthCodeURL("state", "verifier", "nonce"))
+	assert.Equal(t, "state", state)
+	_, err = p.Exchange(ctx, code, "other verifier", "nonce")
+	assert.Error(t, err)
+
+	code, _ = login(t, p.AuthCodeURL("state", "verifier", "nonce"))
+	claims, err := p.Exchange(ctx, code, "verifier", "nonce")
+	require.NoError(t, err)
+	assert.Equal(t, Claims{Subject: "42", Email: "user@example.com", EmailVerified: true, Name: "User"}, claims)
+
+	code, _ = login(t, p.AuthCodeURL("state", "verifier", "nonce"))
+	_, err = p.Exchange(ctx, code, "verifier", "other nonce")
+	assert.ErrorIs(t, err, ErrNonceMismatch)
+}
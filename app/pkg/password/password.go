@@ -0,0 +1,91 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Supported hashing algorithms
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+)
+
+var (
+	ErrMismatch      = errors.New("password does not match")
+	ErrUnknownFormat = errors.New("unknown password hash format")
+)
+
+// Hasher hashes passwords with configured algorithm and parameters and verifies hashes of any supported algorithm.
+// Hashes are self-describing: algorithm and parameters are stored in the hash string.
+type Hasher interface {
+	Hash(password string) (string, error)
+	Verify(hash, password string) error
+	// NeedsRehash reports whether hash was made by other algorithm or with other parameters than configured
+	NeedsRehash(hash string) bool
+}
+
+type hasher struct {
+	algorithm string
+	bcrypt    bcryptHasher
+	argon2    argon2Hasher
+}
+
+func NewHasher(algorithm string, bcryptCost int, argon2Params Argon2Params) (Hasher, error) {
+	h := &hasher{
+		algorithm: algorithm,
+		bcrypt:    bcryptHasher{cost: bcryptCost},
+		argon2:    argon2Hasher{params: argon2Params},
+	}
+	switch algorithm {
+	case Bcrypt:
+		if err := h.bcrypt.validate(); err != nil {
+			return nil, err
+		}
+	case Argon2id:
+		if err := h.argon2.validate(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported password hashing algorithm %s", algorithm)
+	}
+	return h, nil
+}
+
+func (h *hasher) Hash(password string) (string, error) {
+	if h.algorithm == Argon2id {
+		return h.argon2.hash(password)
+	}
+	return h.bcrypt.hash(password)
+}
+
+func (h *hasher) Verify(hash, password string) error {
+	switch algorithmOf(hash) {
+	case Bcrypt:
+		return h.bcrypt.verify(hash, password)
+	case Argon2id:
+		return h.argon2.verify(hash, password)
+	}
+	return ErrUnknownFormat
+}
+
+func (h *hasher) NeedsRehash(hash string) bool {
+	if algorithmOf(hash) != h.algorithm {
+		return true
+	}
+	if h.algorithm == Argon2id {
+		return h.argon2.needsRehash(hash)
+	}
+	return h.bcrypt.needsRehash(hash)
+}
+
+func algorithmOf(hash string) string {
+	switch {
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return Bcrypt
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return Argon2id
+	}
+	return ""
+}
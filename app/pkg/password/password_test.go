@@ -0,0 +1,70 @@
+package password
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var argon2Params = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestHasher(t *testing.T) {
+	for _, alg := range []string{Bcrypt, Argon2id} {
+		t.Run(alg, func(t *testing.T) {
+			h, err := NewHasher(alg, 4, argon2Params)
+			require.NoError(t, err)
+
+			hash, err := h.Hash("secret")
+			require.NoError(t, err)
+			assert.Equal(t, alg, algorithmOf(hash))
+
+			assert.NoError(t, h.Verify(hash, "secret"))
+			assert.ErrorIs(t, h.Verify(hash, "wrong"), ErrMismatch)
+			assert.False(t, h.NeedsRehash(hash))
+		})
+	}
+}
+
+// Test scenario:
+// 1. Hash password with weak bcrypt
+// 2. Hasher with stronger bcrypt or argon2id must verify the hash and report it needs rehash
+// 3. Argon2id hash with other parameters needs rehash as well
+func TestHasherNeedsRehash(t *testing.T) {
+	weak, err := NewHasher(Bcrypt, 4, argon2Params)
+	require.NoError(t, err)
+	hash, err := weak.Hash("secret")
+	require.NoError(t, err)
+
+	strong, err := NewHasher(Bcrypt, 5, argon2Params)
+	require.NoError(t, err)
+	assert.NoError(t, strong.Verify(hash, "secret"))
+	assert.True(t, strong.NeedsRehash(hash))
+
+	argon, err := NewHasher(Argon2id, 4, argon2Params)
+	require.NoError(t, err)
+	assert.NoError(t, argon.Verify(hash, "secret"))
+	assert.True(t, argon.NeedsRehash(hash))
+
+	hash, err = argon.Hash("secret")
+	require.NoError(t, err)
+	params := argon2Params
+	params.Iterations = 2
+	stronger, err := NewHasher(Argon2id, 4, params)
+	require.NoError(t, err)
+	assert.NoError(t, stronger.Verify(hash, "secret"))
+	assert.True(t, stronger.NeedsRehash(hash))
+}
+
+func TestNewHasher(t *testing.T) {
+	_, err := NewHasher("md5", 10, argon2Params)
+	assert.Error(t, err)
+	_, err = NewHasher(Bcrypt, 1, argon2Params)
+	assert.Error(t, err)
+	_, err = NewHasher(Argon2id, 10, Argon2Params{})
+	assert.Error(t, err)
+
+	h, err := NewHasher(Bcrypt, 4, Argon2Params{})
+	require.NoError(t, err)
+	assert.ErrorIs(t, h.Verify("plain", "plain"), ErrUnknownFormat)
+}
@@ -0,0 +1,205 @@
+package password
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+)
+
+// Validation tags registered by Policy.Register, Tag is an alias checking all rules
+const (
+	Tag         = "password"
+	TagLength   = "password_length"
+	TagClasses  = "password_classes"
+	TagPersonal = "password_personal"
+	TagCommon   = "password_common"
+)
+
+// minPersonalLength is the shortest part of name or email which is looked for in password
+const minPersonalLength = 3
+
+type Policy struct {
+	MinLength      int
+	MaxLength      int
+	RequireUpper   bool
+	RequireLower   bool
+	RequireDigit   bool
+	RequireSymbol  bool
+	RejectPersonal bool
+	common         map[string]struct{}
+}
+
+// commonPasswords is the default list of common passwords, it is built into the binary,
+// so that the check does not depend on working directory
+//
+//go:embed common_passwords.txt
+var commonPasswords string
+
+// LoadCommon reads list of breached or common passwords from file, one password per line.
+// Empty path loads the built-in list, empty file disables the check.
+func (p *Policy) LoadCommon(path string) error {
+	if path == "" {
+		return p.readCommon(strings.NewReader(commonPasswords))
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open common passwords file due to error %w", err)
+	}
+	defer f.Close()
+
+	if err = p.readCommon(f); err != nil {
+		return fmt.Errorf("failed to read common passwords file due to error %w", err)
+	}
+
+	return nil
+}
+
+func (p *Policy) readCommon(r io.Reader) error {
+	p.common = make(map[string]struct{})
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			p.common[strings.ToLower(line)] = struct{}{}
+		}
+	}
+	return scanner.Err()
+}
+
+func (p *Policy) CheckLength(password string) bool {
+	n := utf8.RuneCountInString(password)
+	return n >= p.MinLength && (p.MaxLength <= 0 || n <= p.MaxLength)
+}
+
+func (p *Policy) CheckClasses(password string) bool {
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	return (!p.RequireUpper || upper) && (!p.RequireLower || lower) &&
+		(!p.RequireDigit || digit) && (!p.RequireSymbol || symbol)
+}
+
+// CheckPersonal reports whether password does not contain user's name, email or its local part
+func (p *Policy) CheckPersonal(password string, personal ...string) bool {
+	if !p.RejectPersonal {
+		return true
+	}
+	password = strings.ToLower(password)
+	for _, v := range personal {
+		v = strings.ToLower(strings.TrimSpace(v))
+		candidates := []string{v}
+		if local, _, ok := strings.Cut(v, "@"); ok {
+			candidates = append(candidates, local)
+		}
+		for _, c := range candidates {
+			if utf8.RuneCountInString(c) >= minPersonalLength && strings.Contains(password, c) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (p *Policy) CheckCommon(password string) bool {
+	_, found := p.common[strings.ToLower(password)]
+	return !found
+}
+
+// Register adds policy rules to validator under the Tag alias and their descriptions to utils.TranslateValidationError.
+// Personal info is taken from Name and Email fields of the validated struct and from WithPersonalInfo context.
+func (p *Policy) Register(v *validator.Validate) error {
+	rules := map[string]validator.FuncCtx{
+		TagLength: func(ctx context.Context, fl validator.FieldLevel) bool {
+			return p.CheckLength(fl.Field().String())
+		},
+		TagClasses: func(ctx context.Context, fl validator.FieldLevel) bool {
+			return p.CheckClasses(fl.Field().String())
+		},
+		TagPersonal: func(ctx context.Context, fl validator.FieldLevel) bool {
+			return p.CheckPersonal(fl.Field().String(), personalInfo(ctx, fl)...)
+		},
+		TagCommon: func(ctx context.Context, fl validator.FieldLevel) bool {
+			return p.CheckCommon(fl.Field().String())
+		},
+	}
+	for tag, fn := range rules {
+		if err := v.RegisterValidationCtx(tag, fn); err != nil {
+			return err
+		}
+	}
+	v.RegisterAlias(Tag, strings.Join([]string{TagLength, TagClasses, TagPersonal, TagCommon}, ","))
+
+	utils.RegisterValidationMessage(TagLength, func(field, _ string) string {
+		if p.MaxLength > 0 {
+			return fmt.Sprintf("%s must be from %d to %d characters long.", field, p.MinLength, p.MaxLength)
+		}
+		return fmt.Sprintf("%s must be at least %d characters long.", field, p.MinLength)
+	})
+	utils.RegisterValidationMessage(TagClasses, func(field, _ string) string {
+		return fmt.Sprintf("%s must contain %s.", field, p.classesDescription())
+	})
+	utils.RegisterValidationMessage(TagPersonal, func(field, _ string) string {
+		return fmt.Sprintf("%s must not contain your name or email.", field)
+	})
+	utils.RegisterValidationMessage(TagCommon, func(field, _ string) string {
+		return fmt.Sprintf("%s is too common or has been leaked, choose another one.", field)
+	})
+
+	return nil
+}
+
+func (p *Policy) classesDescription() string {
+	var classes []string
+	if p.RequireUpper {
+		classes = append(classes, "an uppercase letter")
+	}
+	if p.RequireLower {
+		classes = append(classes, "a lowercase letter")
+	}
+	if p.RequireDigit {
+		classes = append(classes, "a digit")
+	}
+	if p.RequireSymbol {
+		classes = append(classes, "a special character")
+	}
+	return strings.Join(classes, ", ")
+}
+
+type personalInfoKey struct{}
+
+// WithPersonalInfo returns context with user's name, email and so on, which must not be a part of a password
+func WithPersonalInfo(ctx context.Context, values ...string) context.Context {
+	return context.WithValue(ctx, personalInfoKey{}, values)
+}
+
+func personalInfo(ctx context.Context, fl validator.FieldLevel) []string {
+	values, _ := ctx.Value(personalInfoKey{}).([]string)
+	if parent := fl.Parent(); parent.Kind() == reflect.Struct {
+		for _, name := range []string{"Name", "Email"} {
+			if f := parent.FieldByName(name); f.IsValid() && f.Kind() == reflect.String {
+				values = append(values, f.String())
+			}
+		}
+	}
+	return values
+}
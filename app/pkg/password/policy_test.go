@@ -0,0 +1,77 @@
+package password
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/slava-911/URL-shortener/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type signup struct {
+	Name     string
+	Email    string
+	Password string `validate:"password"`
+}
+
+func TestPolicy(t *testing.T) {
+	common := filepath.Join(t.TempDir(), "common.txt")
+	require.NoError(t, os.WriteFile(common, []byte("# comment\nQwerty123\n"), 0o600))
+
+	p := Policy{MinLength: 8, MaxLength: 16, RequireUpper: true, RequireLower: true, RequireDigit: true, RejectPersonal: true}
+	require.NoError(t, p.LoadCommon(common))
+	v := validator.New()
+	require.NoError(t, p.Register(v))
+
+	cases := []struct {
+		password string
+		tag      string
+	}{
+		{"Str0ngPassw", ""},
+		{"Sh0rt", TagLength},
+		{"TooL0ngPassword123", TagLength},
+		{"n0uppercase", TagClasses},
+		{"NoDigitsHere", TagClasses},
+		{"Ivan1234Pass", TagPersonal},
+		{"Xivan.p99Z", TagPersonal},
+		{"qwerty123", TagClasses},
+		{"QWERTY123", TagClasses},
+		{"qWERTY123", TagCommon},
+	}
+	for _, c := range cases {
+		err := v.Struct(signup{Name: "Ivan", Email: "ivan.p99@example.com", Password: c.password})
+		if c.tag == "" {
+			assert.NoError(t, err, c.password)
+			continue
+		}
+		if assert.Error(t, err, c.password) {
+			fe := err.(validator.ValidationErrors)[0]
+			assert.Equal(t, Tag, fe.Tag())
+			assert.Equal(t, c.tag, fe.ActualTag(), c.password)
+		}
+	}
+
+	ctx := WithPersonalInfo(context.Background(), "Petr")
+	err := v.VarCtx(ctx, "Petr2000xyz", "password")
+	if assert.Error(t, err) {
+		assert.Equal(t, "New password must not contain your name or email.",
+			strings.TrimSpace(utils.TranslateValidationError(err, "New password")))
+	}
+}
+
+func TestLoadCommonBuiltIn(t *testing.T) {
+	var p Policy
+	require.NoError(t, p.LoadCommon(""))
+	assert.False(t, p.CheckCommon("Password"))
+	assert.True(t, p.CheckCommon("Str0ngPassw"))
+
+	empty := filepath.Join(t.TempDir(), "empty.txt")
+	require.NoError(t, os.WriteFile(empty, nil, 0o600))
+	require.NoError(t, p.LoadCommon(empty))
+	assert.True(t, p.CheckCommon("Password"))
+}
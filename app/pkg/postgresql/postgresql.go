@@ -20,6 +20,10 @@ type Client interface {
 	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
 	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
 	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	// Ping acquires a connection and checks that the server responds
+	Ping(ctx context.Context) error
+	// Stat returns connection stats of the pool
+	Stat() *pgxpool.Stat
 	Close()
 }
 
@@ -42,9 +46,13 @@ func NewDBConfig(username string, password string, host string, port string, dat
 	}
 }
 
+func (c *dbConfig) dsn() string {
+	return fmt.Sprintf("postgresql://%s:%s@%s:%s/%s", c.Username, c.Password, c.Host, c.Port, c.Database)
+}
+
 // NewClient establishes a database connection and returns postgresql Client interface
 func NewClient(maxAttempts int, delay time.Duration, cfg *dbConfig, logger *logging.Logger) (p Client, err error) {
-	dsn := fmt.Sprintf("postgresql://%s:%s@%s:%s/%s", cfg.Username, cfg.Password, cfg.Host, cfg.Port, cfg.Database)
+	dsn := cfg.dsn()
 
 	err = utils.DoWithAttempts(func() error {
 		ctx, cancel := context.WithTimeout(context.Background(), delay)
@@ -55,12 +63,13 @@ func NewClient(maxAttempts int, delay time.Duration, cfg *dbConfig, logger *logg
 			logger.Fatalf("Unable to parse config: %v\n", err)
 		}
 
-		p, err = pgxpool.ConnectConfig(ctx, pgxCfg)
+		pool, err := pgxpool.ConnectConfig(ctx, pgxCfg)
 		if err != nil {
 			logger.Error("Failed to connect to postgres... Going to do the next attempt")
 
 			return err
 		}
+		p = &txClient{Client: pool}
 
 		return nil
 	}, maxAttempts, delay)
@@ -72,6 +81,11 @@ func NewClient(maxAttempts int, delay time.Duration, cfg *dbConfig, logger *logg
 	return p, nil
 }
 
+// NewConn opens single connection outside of the pool, for work that needs session state such as advisory locks
+func NewConn(ctx context.Context, cfg *dbConfig) (*pgx.Conn, error) {
+	return pgx.Connect(ctx, cfg.dsn())
+}
+
 func DetailedPgError(err error) error {
 	var pgErr *pgconn.PgError
 	if errors.As(err, &pgErr) {
@@ -0,0 +1,129 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/slava-911/URL-shortener/pkg/postgresql"
+
+// tracedClient starts a span for every query made within a recorded trace,
+// queries of background work outside of traces are not recorded
+type tracedClient struct {
+	Client
+	tracer trace.Tracer
+}
+
+// NewTracedClient wraps the client to trace Query, QueryRow and Exec with the statement
+func NewTracedClient(c Client) Client {
+	return &tracedClient{
+		Client: c,
+		tracer: otel.Tracer(tracerName),
+	}
+}
+
+func (c *tracedClient) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	ctx, span, ok := c.start(ctx, sql)
+	if !ok {
+		return c.Client.Query(ctx, sql, args...)
+	}
+	rows, err := c.Client.Query(ctx, sql, args...)
+	if err != nil {
+		end(span, err)
+		return rows, err
+	}
+	return &tracedRows{Rows: rows, span: span}, nil
+}
+
+func (c *tracedClient) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	ctx, span, ok := c.start(ctx, sql)
+	if !ok {
+		return c.Client.QueryRow(ctx, sql, args...)
+	}
+	return &tracedRow{Row: c.Client.QueryRow(ctx, sql, args...), span: span}
+}
+
+func (c *tracedClient) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	ctx, span, ok := c.start(ctx, sql)
+	if !ok {
+		return c.Client.Exec(ctx, sql, arguments...)
+	}
+	tag, err := c.Client.Exec(ctx, sql, arguments...)
+	end(span, err)
+	return tag, err
+}
+
+func (c *tracedClient) start(ctx context.Context, sql string) (context.Context, trace.Span, bool) {
+	if !trace.SpanFromContext(ctx).IsRecording() {
+		return ctx, nil, false
+	}
+	op := operation(sql)
+	ctx, span := c.tracer.Start(ctx, op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperation(op), semconv.DBStatement(sql)),
+	)
+	return ctx, span, true
+}
+
+// end records the error of the query, no rows is a result rather than a failure
+func end(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// operation is the first keyword of the statement, ex: SELECT
+func operation(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "QUERY"
+	}
+	return strings.ToUpper(fields[0])
+}
+
+// tracedRows ends the span when all rows are read or rows are closed, whichever comes first
+type tracedRows struct {
+	pgx.Rows
+	span trace.Span
+}
+
+func (r *tracedRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.finish()
+	return false
+}
+
+func (r *tracedRows) Close() {
+	r.Rows.Close()
+	r.finish()
+}
+
+func (r *tracedRows) finish() {
+	if r.span != nil {
+		end(r.span, r.Rows.Err())
+		r.span = nil
+	}
+}
+
+type tracedRow struct {
+	pgx.Row
+	span trace.Span
+}
+
+func (r *tracedRow) Scan(dest ...interface{}) error {
+	err := r.Row.Scan(dest...)
+	end(r.span, err)
+	return err
+}
@@ -0,0 +1,74 @@
+package postgresql
+
+import (
+	"context"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+type txKey struct{}
+
+// txClient runs queries in the transaction started by WithinTx if the context carries one, otherwise in the pool
+type txClient struct {
+	Client
+}
+
+func (c *txClient) Begin(ctx context.Context) (pgx.Tx, error) {
+	if tx, ok := txFromContext(ctx); ok {
+		return tx.Begin(ctx)
+	}
+	return c.Client.Begin(ctx)
+}
+
+func (c *txClient) BeginFunc(ctx context.Context, f func(pgx.Tx) error) error {
+	if tx, ok := txFromContext(ctx); ok {
+		return tx.BeginFunc(ctx, f)
+	}
+	return c.Client.BeginFunc(ctx, f)
+}
+
+// BeginTxFunc ignores txOptions inside of outer transaction, nested transaction is a savepoint
+func (c *txClient) BeginTxFunc(ctx context.Context, txOptions pgx.TxOptions, f func(pgx.Tx) error) error {
+	if tx, ok := txFromContext(ctx); ok {
+		return tx.BeginFunc(ctx, f)
+	}
+	return c.Client.BeginTxFunc(ctx, txOptions, f)
+}
+
+func (c *txClient) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	if tx, ok := txFromContext(ctx); ok {
+		return tx.Query(ctx, sql, args...)
+	}
+	return c.Client.Query(ctx, sql, args...)
+}
+
+func (c *txClient) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	if tx, ok := txFromContext(ctx); ok {
+		return tx.QueryRow(ctx, sql, args...)
+	}
+	return c.Client.QueryRow(ctx, sql, args...)
+}
+
+func (c *txClient) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	if tx, ok := txFromContext(ctx); ok {
+		return tx.Exec(ctx, sql, arguments...)
+	}
+	return c.Client.Exec(ctx, sql, arguments...)
+}
+
+// WithinTx runs f in a transaction, queries of the client made with the context passed to f are part of it.
+// Nested calls join the outer transaction.
+func WithinTx(ctx context.Context, c Client, f func(ctx context.Context) error) error {
+	if _, ok := txFromContext(ctx); ok {
+		return f(ctx)
+	}
+	return c.BeginFunc(ctx, func(tx pgx.Tx) error {
+		return f(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+func txFromContext(ctx context.Context) (pgx.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(pgx.Tx)
+	return tx, ok
+}
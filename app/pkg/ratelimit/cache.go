@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/slava-911/URL-shortener/pkg/cache"
+)
+
+const bucketSize = 16
+
+type cacheStore struct {
+	sync.Mutex
+	cache cache.Repository
+	now   func() time.Time
+}
+
+// NewCacheStore creates in-memory store which keeps buckets in the cache repository.
+// Buckets expire as soon as they are full again, so idle keys don't occupy the cache.
+func NewCacheStore(cache cache.Repository) Store {
+	return &cacheStore{cache: cache, now: time.Now}
+}
+
+func (s *cacheStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	now := s.now()
+	b := newBucket(now, limit)
+	if val, err := s.cache.Get([]byte(key)); err == nil && len(val) == bucketSize {
+		b = decodeBucket(val)
+	}
+
+	res := b.take(now, limit)
+
+	expireIn := int(math.Ceil(res.ResetAfter.Seconds())) + 1
+	if err := s.cache.Set([]byte(key), encodeBucket(b), expireIn); err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
+
+func encodeBucket(b *bucket) []byte {
+	buf := make([]byte, bucketSize)
+	binary.BigEndian.PutUint64(buf[:8], math.Float64bits(b.tokens))
+	binary.BigEndian.PutUint64(buf[8:], uint64(b.last.UnixNano()))
+	return buf
+}
+
+func decodeBucket(buf []byte) *bucket {
+	return &bucket{
+		tokens: math.Float64frombits(binary.BigEndian.Uint64(buf[:8])),
+		last:   time.Unix(0, int64(binary.BigEndian.Uint64(buf[8:]))),
+	}
+}
@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/slava-911/URL-shortener/pkg/cache/freecache"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test scenario:
+// 1. Take whole burst, every request must be allowed and remaining must decrease
+// 2. Next request must be denied with retry after one token refill period
+// 3. Other key must have its own bucket
+// 4. After one token refill period one more request must be allowed
+// 5. After full refill bucket must be full again
+func TestCacheStore(t *testing.T) {
+	now := time.Unix(1_000_000, 0)
+	store := &cacheStore{cache: freecache.NewCacheRepo(1024 * 1024), now: func() time.Time { return now }}
+	limit := Limit{Rate: 1, Period: time.Second, Burst: 3}
+	ctx := context.Background()
+
+	for i := 2; i >= 0; i-- {
+		res, err := store.Take(ctx, "a", limit)
+		assert.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, i, res.Remaining)
+		assert.Equal(t, 3, res.Limit)
+	}
+
+	res, err := store.Take(ctx, "a", limit)
+	assert.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, time.Second, res.RetryAfter)
+	assert.Equal(t, 3*time.Second, res.ResetAfter)
+
+	res, err = store.Take(ctx, "b", limit)
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+
+	now = now.Add(time.Second)
+	res, err = store.Take(ctx, "a", limit)
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+
+	now = now.Add(10 * time.Second)
+	res, err = store.Take(ctx, "a", limit)
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 2, res.Remaining)
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit describes token bucket: Rate tokens are added every Period, bucket holds at most Burst tokens
+type Limit struct {
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+// perSecond returns token refill speed
+func (l Limit) perSecond() float64 {
+	return float64(l.Rate) / l.Period.Seconds()
+}
+
+// Result is the state of the bucket after taking a token
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// ResetAfter is the time until the bucket is full again
+	ResetAfter time.Duration
+	// RetryAfter is the time until the next token is available, it is zero if request is allowed
+	RetryAfter time.Duration
+}
+
+// Store keeps buckets state, implementations must make Take atomic for a key
+type Store interface {
+	// Take takes one token from the bucket identified by key
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills bucket according to the elapsed time and takes one token if there is any
+func (b *bucket) take(now time.Time, limit Limit) Result {
+	rate := limit.perSecond()
+	burst := float64(limit.Burst)
+
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+elapsed*rate)
+	}
+	b.last = now
+
+	res := Result{Limit: limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	res.Remaining = int(math.Floor(b.tokens))
+	res.ResetAfter = seconds((burst - b.tokens) / rate)
+
+	return res
+}
+
+func newBucket(now time.Time, limit Limit) *bucket {
+	return &bucket{tokens: float64(limit.Burst), last: now}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,145 @@
+// Package tracing sets up OpenTelemetry tracer provider, W3C trace context propagation and server spans
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/semconv/v1.17.0/httpconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation name of spans started by this package
+const tracerName = "github.com/slava-911/URL-shortener/pkg/tracing"
+
+// TraceIDHeader gives the trace id of the request to the client, to look a slow response up in the backend
+const TraceIDHeader = "X-Trace-Id"
+
+// NewOTLPExporter sends spans to OTLP/HTTP collector at endpoint, ex: localhost:4318
+func NewOTLPExporter(ctx context.Context, endpoint string, insecure bool) (sdktrace.SpanExporter, error) {
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+	if insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exp, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create otlp exporter due to error %w", err)
+	}
+	return exp, nil
+}
+
+// NewStdoutExporter writes spans as indented json to w, for local use
+func NewStdoutExporter(w io.Writer) (sdktrace.SpanExporter, error) {
+	exp, err := stdouttrace.New(stdouttrace.WithWriter(w), stdouttrace.WithPrettyPrint())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout exporter due to error %w", err)
+	}
+	return exp, nil
+}
+
+// Setup installs global tracer provider batching spans to exp and W3C trace context propagator.
+// Traces are sampled with the ratio. Sampled flag of the caller is followed only with trustParent,
+// for callers behind a trusted proxy, otherwise the caller's trace is continued but sampled with the ratio too.
+// The returned function flushes pending spans and stops the provider.
+func Setup(exp sdktrace.SpanExporter, serviceName string, ratio float64,
+	trustParent bool) func(ctx context.Context) error {
+	root := sdktrace.TraceIDRatioBased(ratio)
+	sampler := sdktrace.ParentBased(root)
+	if !trustParent {
+		sampler = sdktrace.ParentBased(root,
+			sdktrace.WithRemoteParentSampled(root),
+			sdktrace.WithRemoteParentNotSampled(root),
+		)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	return tp.Shutdown
+}
+
+// Middleware starts server span of the request continuing trace of the caller, route returns the span name,
+// ex: /links/:id. Requests of skipped routes are not traced.
+func Middleware(h http.Handler, route func(r *http.Request) string, skip ...string) http.Handler {
+	tracer := otel.Tracer(tracerName)
+	skipped := make(map[string]bool, len(skip))
+	for _, s := range skip {
+		skipped[s] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rt := route(r)
+		if skipped[rt] {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method+" "+rt,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(httpconv.ServerRequest("", r)...),
+			trace.WithAttributes(semconv.HTTPRoute(rt)),
+		)
+		defer span.End()
+
+		if sc := span.SpanContext(); sc.IsSampled() {
+			w.Header().Set(TraceIDHeader, sc.TraceID().String())
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPStatusCode(rec.status))
+		span.SetStatus(httpconv.ServerStatus(rec.status))
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush keeps streamed responses flushing through the recorder
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// End records the error the operation returned through err in the span and ends it, to be deferred with
+// the named error result. Expected errors, ex: not found, are results rather than failures.
+func End(span trace.Span, err *error, expected ...error) {
+	defer span.End()
+	if err == nil || *err == nil {
+		return
+	}
+	for _, e := range expected {
+		if errors.Is(*err, e) {
+			return
+		}
+	}
+	span.RecordError(*err)
+	span.SetStatus(codes.Error, (*err).Error())
+}
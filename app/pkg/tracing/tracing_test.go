@@ -0,0 +1,126 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// keptExporter keeps spans on shutdown, so that they can be checked after the provider is flushed
+type keptExporter struct {
+	*tracetest.InMemoryExporter
+}
+
+func (e keptExporter) Shutdown(context.Context) error { return nil }
+
+func TestMiddleware(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	shutdown := Setup(keptExporter{exp}, "test", 1, false)
+
+	var inner trace.SpanContext
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inner = trace.SpanContextFromContext(r.Context())
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}), func(r *http.Request) string { return r.URL.Path }, "/metrics")
+
+	req := httptest.NewRequest(http.MethodGet, "/links", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", inner.TraceID().String())
+	assert.Equal(t, inner.TraceID().String(), rec.Header().Get(TraceIDHeader))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/fail", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.NoError(t, shutdown(context.Background()))
+	spans := exp.GetSpans()
+	require.Len(t, spans, 2)
+
+	assert.Equal(t, "GET /links", spans[0].Name)
+	assert.Equal(t, trace.SpanKindServer, spans[0].SpanKind)
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent.SpanID().String())
+	assert.Contains(t, spans[0].Attributes, semconv.HTTPRoute("/links"))
+	assert.Equal(t, codes.Unset, spans[0].Status.Code)
+
+	assert.Equal(t, "POST /fail", spans[1].Name)
+	assert.False(t, spans[1].Parent.IsValid())
+	assert.Contains(t, spans[1].Attributes, semconv.HTTPStatusCode(http.StatusInternalServerError))
+	assert.Equal(t, codes.Error, spans[1].Status.Code)
+}
+
+func TestSetupSampledParent(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		trustParent bool
+		sampled     bool
+	}{
+		{name: "untrusted parent is sampled with the ratio", trustParent: false, sampled: false},
+		{name: "trusted parent is followed", trustParent: true, sampled: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			shutdown := Setup(tracetest.NewInMemoryExporter(), "test", 0, tc.trustParent)
+			defer shutdown(context.Background())
+
+			h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+				func(r *http.Request) string { return r.URL.Path })
+			req := httptest.NewRequest(http.MethodGet, "/links", nil)
+			req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.sampled, rec.Header().Get(TraceIDHeader) != "")
+		})
+	}
+}
+
+func TestEnd(t *testing.T) {
+	errExpected := errors.New("not found")
+	exp := tracetest.NewInMemoryExporter()
+	shutdown := Setup(keptExporter{exp}, "test", 1, false)
+	tracer := otel.Tracer("test")
+
+	op := func(name string, err error) {
+		_, span := tracer.Start(context.Background(), name)
+		End(span, &err, errExpected)
+	}
+	op("ok", nil)
+	op("expected", fmt.Errorf("failed to find link, error: %w", errExpected))
+	op("failed", errors.New("connection refused"))
+
+	require.NoError(t, shutdown(context.Background()))
+	spans := exp.GetSpans()
+	require.Len(t, spans, 3)
+	assert.Equal(t, codes.Unset, spans[0].Status.Code)
+	assert.Equal(t, codes.Unset, spans[1].Status.Code)
+	assert.Empty(t, spans[1].Events)
+	assert.Equal(t, codes.Error, spans[2].Status.Code)
+	assert.Equal(t, "connection refused", spans[2].Status.Description)
+	assert.Len(t, spans[2].Events, 1)
+}
+
+func TestMiddlewareFlush(t *testing.T) {
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := w.(http.Flusher)
+		require.True(t, ok)
+		_, _ = w.Write([]byte("part"))
+		f.Flush()
+	}), func(r *http.Request) string { return r.URL.Path })
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/export", nil))
+	assert.True(t, rec.Flushed)
+}
@@ -26,12 +26,26 @@ func DoWithAttempts(fn func() error, maxAttempts int, delay time.Duration) error
 	return err
 }
 
+// validationMessages describe failed custom validation tags, such as password rules, messages take field name
+// and tag param. Built-in tags keep the generic message.
+var validationMessages = map[string]func(field, param string) string{}
+
+// RegisterValidationMessage sets description of custom validation tag used by TranslateValidationError.
+// It must be called on startup before validation is used.
+func RegisterValidationMessage(tag string, msg func(field, param string) string) {
+	validationMessages[tag] = msg
+}
+
 func TranslateValidationError(err error, fieldName string) string {
 	buffer := bytes.Buffer{}
 	for _, v := range err.(validator.ValidationErrors) {
 		if v.Field() != "" {
 			fieldName = v.Field()
 		}
+		if msg, ok := validationMessages[v.ActualTag()]; ok {
+			buffer.WriteString(msg(fieldName, v.Param()) + " ")
+			continue
+		}
 		buffer.WriteString(fmt.Sprintf("Field validation for '%s' failed on the '%s' tag. ", fieldName, v.Tag()))
 	}
 	return buffer.String()